@@ -0,0 +1,1319 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"gopherDigest/pkg/capture"
+	"gopherDigest/pkg/config"
+	"gopherDigest/pkg/digest"
+	"gopherDigest/pkg/health"
+	"gopherDigest/pkg/jsonl"
+	"gopherDigest/pkg/mysql"
+	"gopherDigest/pkg/ptdigest"
+	"gopherDigest/pkg/rethinkdb"
+	"gopherDigest/pkg/slowlog"
+	"gopherDigest/pkg/sqlite"
+	"gopherDigest/pkg/store"
+	"gopherDigest/pkg/types"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// defaultQuery is the employees schema join used when no query is given
+const defaultQuery = "SELECT * FROM salaries s LEFT JOIN employees e USING(emp_no) LEFT JOIN dept_emp d USING(emp_no)"
+
+// mysqlConfig creates a MySQL configuration for a database of the selected MySQL target
+func mysqlConfig(s *config.Settings, dbname string) mysql.MySQL {
+	return mysql.New(dbname, *s.Target())
+}
+
+// rethinkConfig creates a RethinkDB configuration from the settings
+func rethinkConfig(s *config.Settings) *rethinkdb.RethinkDB {
+	return rethinkdb.New(s.RethinkDB)
+}
+
+// secretsDir is where Docker mounts secrets
+const secretsDir = "/run/secrets"
+
+// secretProviders chains the providers settings and secrets are read from, in order of precedence:
+// the environment, files named by <KEY>_FILE, Docker secrets, the .env file and the encrypted
+// secrets file named by GOPHERDIGEST_SECRETS_FILE. Without an envFile, .env is read if it exists.
+func secretProviders(envFile string) (config.Provider, error) {
+	providers := config.Chain{
+		config.Environment(os.LookupEnv),
+		config.FileReferences(os.LookupEnv),
+		config.SecretsDir(secretsDir),
+	}
+
+	optional := envFile == ""
+
+	if optional {
+		envFile = ".env"
+	}
+
+	dotEnv, err := config.DotEnv(envFile)
+
+	if err == nil {
+		providers = append(providers, dotEnv)
+	} else if !optional || !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not read the .env file %s\n%s", envFile, err)
+	}
+
+	path, _, err := providers.Lookup("GOPHERDIGEST_SECRETS_FILE")
+
+	if err != nil || path == "" {
+		return providers, err
+	}
+
+	key, err := secretsKey(providers)
+
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := config.EncryptedFile(path, key)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read the encrypted secrets file\n%s", err)
+	}
+
+	return append(providers, encrypted), nil
+}
+
+// secretsKey reads the key of the encrypted secrets file from GOPHERDIGEST_SECRETS_KEY
+func secretsKey(p config.Provider) ([]byte, error) {
+	encoded, ok, err := p.Lookup("GOPHERDIGEST_SECRETS_KEY")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("GOPHERDIGEST_SECRETS_KEY or GOPHERDIGEST_SECRETS_KEY_FILE must hold the key of the encrypted secrets file")
+	}
+
+	return config.ParseSecretsKey(encoded)
+}
+
+// interruptContext creates a context that is cancelled when the process receives SIGINT or SIGTERM
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-sig:
+		case <-ctx.Done():
+		}
+
+		signal.Stop(sig)
+		cancel()
+	}()
+
+	return ctx, cancel
+}
+
+// openStore connects to the configured store, retrying until it is reachable or gopherDigest is
+// interrupted
+func openStore(s *config.Settings) (store.Store, error) {
+	switch s.Store.Driver {
+	case config.StoreSQLite:
+		return sqlite.Open(s.Store.Path)
+	case config.StoreJSONL:
+		return jsonl.Open(s.Store.Path, jsonl.Rotation{MaxSize: s.Store.MaxSize, Daily: s.Store.RotateDaily})
+	case config.StoreMemory:
+		return store.NewMemory(), nil
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	return rethinkdb.Open(ctx, *rethinkConfig(s), config.DefaultBackoff)
+}
+
+// checkStore reports the health of the configured store
+func checkStore(ctx context.Context, s *config.Settings, b config.Backoff) (*config.Health, error) {
+	switch s.Store.Driver {
+	case config.StoreSQLite:
+		return sqlite.CheckConnection(ctx, s.Store.Path)
+	case config.StoreJSONL:
+		return jsonl.CheckConnection(s.Store.Path)
+	case config.StoreMemory:
+		return &config.Health{Service: "memory", Endpoint: "memory", TLS: "disabled", Connected: true}, nil
+	}
+
+	return rethinkdb.CheckConnection(ctx, *rethinkConfig(s), b)
+}
+
+// probeBackoff checks a connection once per health check request, as the caller polls
+var probeBackoff = config.Backoff{MaxAttempts: 1}
+
+// connectMySQL opens the connection the MySQL readiness probe checks
+var connectMySQL = mysql.Connect
+
+// healthServer creates the health check server, where liveness only depends on gopherDigest's own
+// runtime dependencies and readiness also needs MySQL and the store to be reachable
+func healthServer(s *config.Settings) *health.Server {
+	return &health.Server{
+		Live: map[string]health.Probe{
+			"dependencies": func(ctx context.Context) (interface{}, error) {
+				return config.Defaults().Locate()
+			},
+		},
+		Ready: map[string]health.Probe{
+			"mysql": func(ctx context.Context) (interface{}, error) {
+				m := mysqlConfig(s, "")
+				db, err := connectMySQL(m)
+
+				if err != nil {
+					return nil, err
+				}
+
+				defer db.Close()
+
+				return mysql.CheckConnection(ctx, m, db, probeBackoff)
+			},
+			s.Store.Driver: func(ctx context.Context) (interface{}, error) {
+				return checkStore(ctx, s, probeBackoff)
+			},
+		},
+	}
+}
+
+// serveHealth serves the health checks on the configured address in the background, returning a
+// function that stops the server
+func serveHealth(s *config.Settings) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		if err := healthServer(s).ListenAndServe(ctx, s.Health.Address); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// preflight verifies the MySQL account holds the privileges of the features a command uses,
+// before the command runs any of them, and writes the privileges of each feature to a writer
+func preflight(w io.Writer, db *sql.DB, features ...mysql.Feature) error {
+	color.New(color.Bold).Fprintln(w, "Checking MySQL Account Privileges")
+	privs, err := mysql.CheckPrivileges(db, features...)
+
+	if privs != nil {
+		privs.PrintStatus(w)
+	}
+
+	return err
+}
+
+// newInitCommand creates the command that bootstraps storage and reconfigures the MySQL server
+func newInitCommand(out io.Writer, s *config.Settings) *command {
+	cmd := newCommand("init", "Bootstrap the result store and enable slow query logging on the MySQL server until interrupted", out)
+	path := cmd.flags.String("snapshot", mysql.DefaultSnapshotFile, "file recording the original values of the changed MySQL globals")
+	duration := cmd.flags.Duration("duration", 0, "keep slow query logging enabled for this long, 0 waits until gopherDigest is interrupted")
+	keep := cmd.flags.Bool("keep", false, "exit without restoring the MySQL globals, leaving them to the restore command")
+
+	cmd.run = func() error {
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		// only RethinkDB needs an administrator to create its database, tables and user
+		if s.Store.Driver != config.StoreRethinkDB {
+			results, err := openStore(s)
+
+			if err != nil {
+				return err
+			}
+
+			results.Close()
+		} else {
+			RDBsession, err := rethinkdb.Init(ctx, *rethinkConfig(s), config.DefaultBackoff)
+
+			if err != nil {
+				return err
+			}
+
+			defer RDBsession.Close()
+		}
+
+		m := mysqlConfig(s, "")
+
+		if m.ReadOnly() {
+			db, err := mysql.Init(ctx, m, nil)
+
+			if err != nil {
+				return err
+			}
+
+			fmt.Println("MySQL is read-only, profile it from performance_schema with 'collect' or from the processlist with 'profile -sample'")
+
+			return db.Close()
+		}
+
+		snap, err := mysql.OpenSnapshot(*path, m.Address())
+
+		if err != nil {
+			return err
+		}
+
+		// TODO: only init if the database isn't initialized
+		db, err := mysql.Init(ctx, m, snap)
+
+		if err != nil {
+			return err
+		}
+
+		defer db.Close()
+
+		if *keep {
+			fmt.Printf("Run 'gopherDigest restore -snapshot %s' to restore the MySQL globals\n", *path)
+			return nil
+		}
+
+		if *duration > 0 {
+			var stop context.CancelFunc
+			ctx, stop = context.WithTimeout(ctx, *duration)
+			defer stop()
+		}
+
+		fmt.Println("Slow query logging is enabled, interrupt gopherDigest to restore the MySQL globals")
+		<-ctx.Done()
+
+		return snap.Restore(db)
+	}
+
+	return cmd
+}
+
+// newRestoreCommand creates the command that restores the MySQL globals recorded in a snapshot
+func newRestoreCommand(out io.Writer, s *config.Settings) *command {
+	cmd := newCommand("restore", "Restore the MySQL globals changed by an init that exited without restoring them", out)
+	path := cmd.flags.String("snapshot", mysql.DefaultSnapshotFile, "file recording the original values of the changed MySQL globals")
+
+	cmd.run = func() error {
+		m := mysqlConfig(s, "")
+		snap, err := mysql.LoadSnapshot(*path)
+
+		if os.IsNotExist(err) {
+			return fmt.Errorf("there is no snapshot at %s to restore", *path)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if snap.Server != m.Address() {
+			return fmt.Errorf("the snapshot %s holds the globals of %s, but MySQL is configured for %s", *path, snap.Server, m.Address())
+		}
+
+		if m.ReadOnly() {
+			for _, stmt := range snap.Statements() {
+				mysql.PrintSkipped(stmt)
+			}
+
+			return nil
+		}
+
+		db, err := mysql.Connect(m)
+
+		if err != nil {
+			return err
+		}
+
+		defer db.Close()
+
+		return snap.Restore(db)
+	}
+
+	return cmd
+}
+
+// newSecretsCommand creates the command that generates keys for and encrypts secrets files
+func newSecretsCommand(out io.Writer, s *config.Settings) *command {
+	cmd := newCommand("secrets", "Generate a secrets key, or encrypt a .env file with GOPHERDIGEST_SECRETS_KEY", out)
+	keygen := cmd.flags.Bool("keygen", false, "print a new base64 encoded secrets key")
+	encrypt := cmd.flags.String("encrypt", "", "`.env` file to encrypt")
+	output := cmd.flags.String("out", "secrets.enc", "file the encrypted secrets are written to")
+
+	cmd.run = func() error {
+		if *keygen {
+			key, err := config.NewSecretsKey()
+
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintln(out, base64.StdEncoding.EncodeToString(key))
+			return nil
+		}
+
+		if *encrypt == "" {
+			cmd.flags.Usage()
+			return fmt.Errorf("either -keygen or -encrypt is required")
+		}
+
+		key, err := secretsKey(config.Chain{config.Environment(os.LookupEnv), config.FileReferences(os.LookupEnv)})
+
+		if err != nil {
+			return err
+		}
+
+		plaintext, err := ioutil.ReadFile(*encrypt)
+
+		if err != nil {
+			return err
+		}
+
+		encrypted, err := config.EncryptSecrets(plaintext, key)
+
+		if err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile(*output, encrypted, 0600); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "Encrypted %s to %s, set GOPHERDIGEST_SECRETS_FILE=%s to use it\n", *encrypt, *output, *output)
+		return nil
+	}
+
+	return cmd
+}
+
+// newCheckCommand creates the command that verifies dependencies and connectivity
+func newCheckCommand(out io.Writer, s *config.Settings) *command {
+	cmd := newCommand("check", "Verify runtime dependencies, MySQL account privileges and MySQL and result store connectivity", out)
+	retries := cmd.flags.Int("retries", config.DefaultBackoff.MaxAttempts, "number of connection attempts before giving up, 0 retries until -retry-timeout")
+	timeout := cmd.flags.Duration("retry-timeout", config.DefaultBackoff.MaxElapsed, "time to keep retrying a connection, 0 retries until -retries attempts")
+	database := cmd.flags.String("database", s.Workload.Database, "MySQL database queries run against")
+	format := cmd.flags.String("format", config.FormatTerminal, "connection health output format: terminal, plain or json")
+
+	cmd.run = func() error {
+		if err := config.CheckFormat(*format); err != nil {
+			return err
+		}
+
+		// only the health report is written to the output in the plain and json formats, so it can
+		// be parsed, and the progress of the checks goes to stderr
+		progress := out
+
+		if *format != config.FormatTerminal {
+			progress = os.Stderr
+		}
+
+		if _, err := config.New(progress); err != nil {
+			return err
+		}
+
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		backoff := config.DefaultBackoff
+		backoff.MaxAttempts = *retries
+		backoff.MaxElapsed = *timeout
+		backoff.Output = progress
+
+		m := mysqlConfig(s, "")
+		db, err := mysql.Connect(m)
+
+		if err != nil {
+			return err
+		}
+
+		defer db.Close()
+
+		mysqlHealth, mysqlErr := mysql.CheckConnection(ctx, m, db, backoff)
+
+		if mysqlErr == nil {
+			features := []mysql.Feature{mysql.PerformanceSchema, mysql.Processlist, mysql.TargetSchema(*database)}
+
+			if !m.ReadOnly() {
+				features = append([]mysql.Feature{mysql.SlowQueryLog}, features...)
+			}
+
+			mysqlErr = preflight(progress, db, features...)
+		}
+
+		storeHealth, storeErr := checkStore(ctx, s, backoff)
+
+		if err := config.Render(out, *format, mysqlHealth, storeHealth); err != nil {
+			return err
+		}
+
+		if mysqlErr != nil {
+			return mysqlErr
+		}
+
+		return storeErr
+	}
+
+	return cmd
+}
+
+// newExplainCommand creates the command that prints the execution plan of a query
+func newExplainCommand(out io.Writer, s *config.Settings) *command {
+	cmd := newCommand("explain", "Print the MySQL execution plan of a query without storing it", out)
+	database := cmd.flags.String("database", s.Workload.Database, "MySQL database the query runs against")
+	queries := addQueryFlags(cmd.flags, s.Workload)
+	analyze := addAnalyzeFlags(cmd.flags)
+
+	cmd.run = func() error {
+		statements, err := queries.statements(os.Stdin)
+
+		if err != nil {
+			return err
+		}
+
+		cfg := mysqlConfig(s, *database)
+		db, err := mysql.Connect(cfg)
+
+		if err != nil {
+			return err
+		}
+
+		defer db.Close()
+
+		analyze.readOnly = cfg.ReadOnly()
+
+		if err := preflight(os.Stdout, db, mysql.TargetSchema(*database)); err != nil {
+			return err
+		}
+
+		for _, query := range statements {
+			seq, err := mysql.ExplainScanRows(db, query)
+
+			if err != nil {
+				return fmt.Errorf("could not explain the query %s\n%s", query, err)
+			}
+
+			plan, err := mysql.ExplainJSON(db, query)
+
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("mysql> EXPLAIN %s;\n", query)
+
+			if err := printExplain(os.Stdout, seq); err != nil {
+				return err
+			}
+
+			if err := printPlan(os.Stdout, plan); err != nil {
+				return err
+			}
+
+			tree, err := analyze.run(db, query)
+
+			if err != nil {
+				return err
+			}
+
+			if err := printAnalyze(os.Stdout, tree); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// newDigestCommand creates the command that captures and stores a query's digest
+func newDigestCommand(out io.Writer, s *config.Settings) *command {
+	cmd := newCommand("digest", "Capture the performance_schema digest and execution plan of a query and save it in the result store", out)
+	database := cmd.flags.String("database", s.Workload.Database, "MySQL database the query runs against")
+	queries := addQueryFlags(cmd.flags, s.Workload)
+	analyze := addAnalyzeFlags(cmd.flags)
+
+	cmd.run = func() error {
+		statements, err := queries.statements(os.Stdin)
+
+		if err != nil {
+			return err
+		}
+
+		results, err := openStore(s)
+
+		if err != nil {
+			return err
+		}
+
+		defer results.Close()
+
+		cfg := mysqlConfig(s, *database)
+		db, err := mysql.Connect(cfg)
+
+		if err != nil {
+			return err
+		}
+
+		defer db.Close()
+
+		analyze.readOnly = cfg.ReadOnly()
+
+		if err := preflight(os.Stdout, db, mysql.PerformanceSchema, mysql.TargetSchema(*database)); err != nil {
+			return err
+		}
+
+		for _, query := range statements {
+			if err := captureDigest(results, db, *database, query, analyze); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// newBenchCommand creates the command that repeatedly runs a query and stores each digest
+func newBenchCommand(out io.Writer, s *config.Settings) *command {
+	cmd := newCommand("bench", "Repeatedly run a query, storing the digest and execution plan of every run", out)
+	database := cmd.flags.String("database", s.Workload.Database, "MySQL database the query runs against")
+	queries := addQueryFlags(cmd.flags, s.Workload)
+	analyze := addAnalyzeFlags(cmd.flags)
+	iterations := cmd.flags.Int("n", 0, "number of runs of each statement (defaults to MYSQL_MAX_CONNECTIONS)")
+
+	cmd.run = func() error {
+		statements, err := queries.statements(os.Stdin)
+
+		if err != nil {
+			return err
+		}
+
+		results, err := openStore(s)
+
+		if err != nil {
+			return err
+		}
+
+		defer results.Close()
+
+		cfg := mysqlConfig(s, *database)
+		db, err := mysql.Connect(cfg)
+
+		if err != nil {
+			return err
+		}
+
+		defer db.Close()
+
+		analyze.readOnly = cfg.ReadOnly()
+
+		if err := preflight(os.Stdout, db, mysql.PerformanceSchema, mysql.TargetSchema(*database)); err != nil {
+			return err
+		}
+
+		n := *iterations
+
+		// TODO: set MySQL max connections as a configurable based on available ram and buffers
+		if n <= 0 {
+			n = cfg.GetMaxConns()
+		}
+
+		return bench(results, db, *database, statements, n, analyze)
+	}
+
+	return cmd
+}
+
+// bench runs every statement n times against a schema, saving the digest of each run in a store.
+// Statements that write are skipped on a read-only target.
+func bench(results store.Store, db *sql.DB, schema string, statements []string, n int, analyze *analyzeOptions) error {
+	for i := 0; i < n; i++ {
+		for _, query := range statements {
+			if analyze.readOnly && !mysql.IsReadOnly(query) {
+				mysql.PrintSkipped(query)
+				continue
+			}
+
+			rows, err := db.Query(query)
+
+			if err != nil {
+				return fmt.Errorf("could not run the benchmark query %s\n%s", query, err)
+			}
+
+			rows.Close()
+
+			if err := captureDigest(results, db, schema, query, analyze); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// newReportCommand creates the command that prints previously stored digests
+func newReportCommand(out io.Writer, s *config.Settings) *command {
+	cmd := newCommand("report", "Print the most recently stored query digests from the result store", out)
+	limit := cmd.flags.Int("limit", 10, "maximum number of stored queries to print")
+	checksum := cmd.flags.String("checksum", "", "only print queries whose fingerprint has this checksum")
+	baseline := cmd.flags.String("baseline", "", "print the digests of the baseline with this name saved by collect instead")
+	schema := cmd.flags.String("schema", "", "only print the baseline digests of this schema")
+
+	cmd.run = func() error {
+		results, err := openStore(s)
+
+		if err != nil {
+			return err
+		}
+
+		defer results.Close()
+
+		if *baseline != "" {
+			return printBaseline(os.Stdout, results, *baseline, *schema)
+		}
+
+		return printHistory(os.Stdout, results, *checksum, *limit)
+	}
+
+	return cmd
+}
+
+// printBaseline writes the load of every statement digest of a named baseline as an aligned table,
+// limited to a single schema when one is given
+func printBaseline(w io.Writer, results store.Store, name, schema string) error {
+	baselines, ok := results.(store.BaselineStore)
+
+	if !ok {
+		return fmt.Errorf("the result store does not keep baselines, use the rethinkdb or memory store")
+	}
+
+	digests, err := baselines.Baseline(name, schema)
+
+	if err != nil {
+		return err
+	}
+
+	if len(digests) == 0 {
+		return fmt.Errorf("there is no baseline named %s", name)
+	}
+
+	start, end := digests[0].Start, digests[0].End
+
+	for _, d := range digests {
+		if d.Start.Before(start) {
+			start = d.Start
+		}
+
+		if d.End.After(end) {
+			end = d.End
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "# Baseline %s from %s to %s\n", name, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	fmt.Fprintln(tw, "schema\tdigest\tcalls\ttotal time\tavg time\trows examined/call\tno index used\tquery")
+
+	for _, d := range digests {
+		// the timers of performance_schema count picoseconds
+		total := time.Duration(d.SumTimerWait / 1000)
+		calls := d.CountStar
+
+		if calls == 0 {
+			calls = 1
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%d\t%d\t%s\n",
+			d.Schema, d.Digest, d.CountStar, total, total/time.Duration(calls),
+			d.SumRowsExamined/calls, d.SumNoIndexUsed, d.DigestText)
+	}
+
+	fmt.Fprintln(tw)
+
+	return tw.Flush()
+}
+
+// printHistory writes the most recently stored queries of a store with their execution plans,
+// limited to a single query fingerprint when a checksum such as 0x3A2B... is given
+func printHistory(w io.Writer, results store.Store, checksum string, limit int) error {
+	records, err := results.QueryHistory(strings.TrimPrefix(strings.ToUpper(checksum), "0X"), limit)
+
+	if err != nil {
+		return err
+	}
+
+	for _, rec := range records {
+		fmt.Fprintf(w, "# %s  Query ID 0x%s\n# %s\n%s\n",
+			time.Unix(rec.Timestamp, 0).Format(time.RFC3339), rec.Checksum, rec.Fingerprint, rec.Search)
+
+		if m := rec.Metrics; m != nil {
+			fmt.Fprintf(w, "# Query_time: %.6f  Lock_time: %.6f  Rows_sent: %d  Rows_examined: %d  Executions: %d\n\n",
+				m.QueryTime, m.LockTime, m.RowsSent, m.RowsExamined, m.CountStar)
+		}
+
+		if err := printExplain(w, rec.SQLExplainRows); err != nil {
+			return err
+		}
+
+		if err := printPlan(w, rec.ExplainPlan); err != nil {
+			return err
+		}
+
+		if err := printAnalyze(w, rec.ExplainAnalyze); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newProfileCommand creates the command that aggregates a slow query log into a ranked profile
+func newProfileCommand(out io.Writer, s *config.Settings) *command {
+	cmd := newCommand("profile", "Aggregate a slow query log, packet capture or processlist samples by fingerprint and print a ranked profile like pt-query-digest", out)
+	path := cmd.flags.String("slowlog", "-", "slow query log to aggregate ('-' reads stdin)")
+	orderBy := cmd.flags.String("order-by", "Query_time:sum", "attribute:statistic to rank by, where the statistic is one of "+strings.Join(digest.Stats, ", "))
+	limit := cmd.flags.Int("limit", 20, "maximum number of ranked queries to print, 0 prints every query")
+	sample := cmd.flags.Duration("sample", 0, "sample the MySQL processlist for this long instead of reading a slow query log")
+	every := cmd.flags.Duration("sample-interval", 100*time.Millisecond, "time between processlist samples")
+	table := cmd.flags.String("sample-table", mysql.ProcesslistTable, "table to sample: processlist (information_schema) or threads (performance_schema)")
+	pcap := cmd.flags.String("pcap", "", "pcap or pcapng capture of MySQL traffic to decode instead of reading a slow query log")
+	port := cmd.flags.Int("port", capture.DefaultPort, "MySQL server port in the -pcap capture")
+
+	cmd.run = func() error {
+		o, err := digest.ParseOrderBy(*orderBy)
+
+		if err != nil {
+			return err
+		}
+
+		agg := digest.NewAggregator()
+
+		switch {
+		case *sample > 0:
+			err = sampleProcesslist(s, agg, *table, *sample, *every)
+		case *pcap != "":
+			err = readCapture(agg, *pcap, *port)
+		default:
+			err = readSlowLog(agg, *path)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		classes := agg.Profile(o)
+
+		if *limit > 0 && len(classes) > *limit {
+			classes = classes[:*limit]
+		}
+
+		return printProfile(os.Stdout, classes, o)
+	}
+
+	return cmd
+}
+
+// readSlowLog aggregates every event of a slow query log, where '-' reads stdin
+func readSlowLog(agg *digest.Aggregator, path string) error {
+	var src io.Reader = os.Stdin
+
+	if path != "-" {
+		f, err := os.Open(path)
+
+		if err != nil {
+			return fmt.Errorf("could not open the slow query log %s\n%s", path, err)
+		}
+
+		defer f.Close()
+		src = f
+	}
+
+	p := slowlog.NewParser(src)
+
+	for {
+		e, err := p.Next()
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		agg.Add(*e)
+	}
+}
+
+// readCapture aggregates the queries decoded from a pcap or pcapng capture of MySQL traffic
+func readCapture(agg *digest.Aggregator, path string, port int) error {
+	events, err := capture.ReadFile(path, port)
+
+	if err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		agg.Add(e)
+	}
+
+	return nil
+}
+
+// sampleProcesslist aggregates the statements seen finishing on the MySQL processlist for a
+// duration, or until gopherDigest is interrupted
+func sampleProcesslist(s *config.Settings, agg *digest.Aggregator, table string, duration, every time.Duration) error {
+	db, err := mysql.Connect(mysqlConfig(s, ""))
+
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	if err := preflight(os.Stdout, db, mysql.Processlist); err != nil {
+		return err
+	}
+
+	sampler, err := mysql.NewProcesslistSampler(db, table)
+
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	ctx, stop := context.WithTimeout(ctx, duration)
+	defer stop()
+
+	return sampler.Run(ctx, every, func(e slowlog.Event) error {
+		agg.Add(e)
+		return nil
+	})
+}
+
+// newPTDigestCommand creates the command that runs pt-query-digest and stores its JSON report
+func newPTDigestCommand(out io.Writer, s *config.Settings) *command {
+	cmd := newCommand("ptdigest", "Run pt-query-digest on a slow log, tcpdump capture or the processlist and save its report in the result store", out)
+	opts := ptdigest.Options{}
+	files := stringList{}
+
+	cmd.flags.StringVar(&opts.Type, "type", ptdigest.SlowLog, "pt-query-digest input: slowlog, tcpdump or processlist")
+	cmd.flags.Var(&files, "file", "slow log or tcpdump output to read, may be repeated (defaults to stdin)")
+	cmd.flags.StringVar(&opts.Binary, "binary", "pt-query-digest", "pt-query-digest executable")
+	cmd.flags.DurationVar(&opts.RunTime, "run-time", time.Minute, "how long to poll the processlist")
+	cmd.flags.DurationVar(&opts.Timeout, "timeout", 10*time.Minute, "stop pt-query-digest after this long, 0 never stops it")
+	database := cmd.flags.String("database", "", "MySQL database of the processlist connection")
+
+	cmd.run = func() error {
+		opts.Files = files
+		opts.Stdin = os.Stdin
+
+		if opts.Type == ptdigest.Processlist {
+			target := mysqlConfig(s, *database)
+			opts.DSN = target.PerconaDSN()
+			opts.Password = target.Password()
+		}
+
+		// stop pt-query-digest when gopherDigest is interrupted
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		report, err := ptdigest.Run(ctx, opts)
+
+		if err != nil {
+			return err
+		}
+
+		results, err := openStore(s)
+
+		if err != nil {
+			return err
+		}
+
+		defer results.Close()
+
+		if err := results.SaveRun(*report); err != nil {
+			return err
+		}
+
+		return printDigestReport(os.Stdout, report)
+	}
+
+	return cmd
+}
+
+// newCollectCommand creates the command that periodically stores the load of every statement digest
+func newCollectCommand(out io.Writer, s *config.Settings) *command {
+	cmd := newCommand("collect", "Periodically snapshot the performance_schema digest summary and save each digest's per-interval load in the result store", out)
+	every := cmd.flags.Duration("interval", s.Collector.Interval, "time between digest summary snapshots")
+	count := cmd.flags.Int("count", s.Collector.Count, "number of intervals to collect, 0 collects until interrupted")
+	baseline := cmd.flags.String("baseline", "", "also save the load of the collected intervals as a baseline with this name")
+
+	cmd.run = func() error {
+		if *every <= 0 {
+			return fmt.Errorf("the collection interval must be positive, but got %s", *every)
+		}
+
+		results, err := openStore(s)
+
+		if err != nil {
+			return err
+		}
+
+		defer results.Close()
+
+		db, err := mysql.Connect(mysqlConfig(s, ""))
+
+		if err != nil {
+			return err
+		}
+
+		defer db.Close()
+
+		if err := preflight(os.Stdout, db, mysql.PerformanceSchema); err != nil {
+			return err
+		}
+
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		return collectDigests(ctx, os.Stdout, results, db, *every, *count, *baseline)
+	}
+
+	return cmd
+}
+
+// collectDigests saves the load of every statement digest in a store each interval, until count
+// intervals were collected when count is positive, or until the context is done. When a baseline
+// is named, the load of each digest over every collected interval is then saved as that baseline.
+func collectDigests(ctx context.Context, w io.Writer, results store.Store, db *sql.DB, every time.Duration, count int, baseline string) error {
+	baselines, ok := results.(store.BaselineStore)
+
+	if baseline != "" && !ok {
+		return fmt.Errorf("the result store does not keep baselines, use the rethinkdb or memory store")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	collected := 0
+	all := []types.DigestInterval{}
+
+	err := mysql.NewDigestCollector(db).Run(ctx, every, func(intervals []types.DigestInterval) error {
+		if err := results.SaveDigestSnapshot(intervals); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "%s  stored the load of %d statement digests\n", time.Now().Format(time.RFC3339), len(intervals))
+
+		if baseline != "" {
+			all = append(all, intervals...)
+		}
+
+		if collected++; count > 0 && collected >= count {
+			cancel()
+		}
+
+		return nil
+	})
+
+	if err != nil || baseline == "" {
+		return err
+	}
+
+	digests := mysql.DigestBaselines(baseline, all)
+
+	if err := baselines.SaveBaseline(digests); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%s  stored the baseline %s of %d statement digests\n", time.Now().Format(time.RFC3339), baseline, len(digests))
+
+	return nil
+}
+
+// analyzeOptions configures the optional EXPLAIN ANALYZE capture of a query
+type analyzeOptions struct {
+	enabled  bool
+	factor   float64
+	readOnly bool
+}
+
+// addAnalyzeFlags registers the -analyze and -misestimate-factor flags on a subcommand's flag set
+func addAnalyzeFlags(fs *flag.FlagSet) *analyzeOptions {
+	opts := &analyzeOptions{}
+
+	fs.BoolVar(&opts.enabled, "analyze", false, "also run EXPLAIN ANALYZE, which executes the query (requires MySQL 8.0.18+)")
+	fs.Float64Var(&opts.factor, "misestimate-factor", 10, "flag EXPLAIN ANALYZE iterators whose estimated and actual rows differ by more than this factor")
+
+	return opts
+}
+
+// run runs EXPLAIN ANALYZE on a query when the capture is enabled
+func (a *analyzeOptions) run(db *sql.DB, query string) (*types.AnalyzeNode, error) {
+	if !a.enabled {
+		return nil, nil
+	}
+
+	// EXPLAIN ANALYZE executes the statement it explains
+	if a.readOnly && !mysql.IsReadOnly(query) {
+		mysql.PrintSkipped("EXPLAIN ANALYZE " + query)
+		return nil, nil
+	}
+
+	return mysql.ExplainAnalyze(db, query, a.factor)
+}
+
+// captureDigest fetches the execution plans and metrics of a query run against a schema and saves
+// them in a store
+func captureDigest(results store.Store, db *sql.DB, schema, query string, analyze *analyzeOptions) error {
+	seq, err := mysql.ExplainScanRows(db, query)
+
+	if err != nil {
+		return fmt.Errorf("could not explain the query %s\n%s", query, err)
+	}
+
+	plan, err := mysql.ExplainJSON(db, query)
+
+	if err != nil {
+		return err
+	}
+
+	tree, err := analyze.run(db, query)
+
+	if err != nil {
+		return err
+	}
+
+	metrics, err := mysql.FetchQueryMetrics(db, query)
+
+	if err != nil {
+		return err
+	}
+
+	fingerprint := mysql.Fingerprint(query)
+
+	return results.SavePlan(types.QueryRecord{
+		Search:         query,
+		Fingerprint:    fingerprint,
+		Checksum:       mysql.Checksum(fingerprint),
+		Schema:         schema,
+		SQLExplainRows: seq,
+		ExplainPlan:    plan,
+		ExplainAnalyze: tree,
+		Metrics:        metrics,
+	})
+}
+
+// printExplain writes execution plan rows to a writer as an aligned table
+func printExplain(w io.Writer, rows []types.SQLExplainRow) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "id\tselect_type\ttable\tpartitions\ttype\tpossible_keys\tkey\tkey_len\tref\trows\tfiltered\tExtra")
+
+	for _, row := range rows {
+		filtered := ""
+
+		if row.Filtered != nil {
+			filtered = fmt.Sprintf("%.2f", *row.Filtered)
+		}
+
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			row.ID, nullString(row.SelectType), nullString(row.Table), nullString(row.Partitions),
+			nullString(row.Ztype), nullString(row.PossibleKeys), nullString(row.Key), nullString(row.KeyLen),
+			nullString(row.Ref), row.Rows, nullString(&filtered), nullString(row.Extra))
+	}
+
+	fmt.Fprintln(tw)
+
+	return tw.Flush()
+}
+
+// printPlan writes the per-table costs of a JSON execution plan to a writer as an aligned table
+func printPlan(w io.Writer, plan *types.ExplainPlan) error {
+	if plan == nil {
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	if plan.QueryBlock.CostInfo != nil {
+		fmt.Fprintf(tw, "query_cost: %.2f\n", plan.QueryBlock.CostInfo.QueryCost)
+	}
+
+	fmt.Fprintln(tw, "table\taccess_type\trows_examined_per_scan\trows_produced_per_join\tfiltered\tread_cost\teval_cost\tprefix_cost\tattached_condition")
+
+	for _, t := range plan.Tables() {
+		cost := types.CostInfo{}
+
+		if t.CostInfo != nil {
+			cost = *t.CostInfo
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%.2f\t%.2f\t%.2f\t%.2f\t%s\n",
+			t.TableName, t.AccessType, t.RowsExaminedPerScan, t.RowsProducedPerJoin, t.Filtered,
+			cost.ReadCost, cost.EvalCost, cost.PrefixCost, nullString(&t.AttachedCondition))
+	}
+
+	fmt.Fprintln(tw)
+
+	return tw.Flush()
+}
+
+// printAnalyze writes an EXPLAIN ANALYZE tree to a writer, marking misestimated iterators with '!'
+func printAnalyze(w io.Writer, root *types.AnalyzeNode) error {
+	if root == nil {
+		return nil
+	}
+
+	var walk func(n *types.AnalyzeNode, depth int)
+
+	walk = func(n *types.AnalyzeNode, depth int) {
+		marker := " "
+
+		if n.Misestimated {
+			marker = "!"
+		}
+
+		fmt.Fprintf(w, "%s %s-> %s", marker, strings.Repeat("    ", depth), n.Operation)
+
+		if n.HasEstimate {
+			fmt.Fprintf(w, "  (estimated rows=%g cost=%g)", n.EstimatedRows, n.EstimatedCost)
+		}
+
+		if n.Executed {
+			fmt.Fprintf(w, "  (actual rows=%g loops=%d time=%g..%g)", n.ActualRows, n.Loops, n.ActualFirstRow, n.ActualLastRow)
+		} else {
+			fmt.Fprint(w, "  (never executed)")
+		}
+
+		if n.Misestimated {
+			fmt.Fprintf(w, "  [rows off by %.1fx]", n.MisestimateRate)
+		}
+
+		fmt.Fprintln(w)
+
+		for _, child := range n.Children {
+			walk(child, depth+1)
+		}
+	}
+
+	walk(root, 0)
+
+	_, err := fmt.Fprintln(w)
+
+	return err
+}
+
+// nullString renders a nullable column the way the mysql client does
+func nullString(s *string) string {
+	if s == nil || *s == "" {
+		return "NULL"
+	}
+
+	return *s
+}
+
+// printProfile writes a ranked digest profile followed by the statistics of every query class
+func printProfile(w io.Writer, classes []digest.Class, o digest.OrderBy) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "# Profile ranked by %s\n", o)
+	fmt.Fprintln(tw, "# Rank\tQuery ID\tResponse time\tCalls\tR/Call\tItem")
+
+	for _, c := range classes {
+		qt := c.Metrics[digest.QueryTime]
+
+		fmt.Fprintf(tw, "# %4d\t0x%s\t%.4f %5.1f%%\t%d\t%.4f\t%s\n",
+			c.Rank, c.Checksum, qt.Sum, c.ResponseShare*100, c.Count, qt.Avg, truncate(c.Fingerprint, 40))
+	}
+
+	fmt.Fprintln(tw)
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, c := range classes {
+		fmt.Fprintf(tw, "# Query %d: 0x%s  %d calls  %.1f%% of response time\n", c.Rank, c.Checksum, c.Count, c.ResponseShare*100)
+		fmt.Fprintln(tw, "# Attribute\ttotal\tmin\tmax\tavg\t95%\tmedian")
+
+		for _, attr := range digest.Attributes {
+			m := c.Metrics[attr]
+
+			// times are in seconds with microsecond precision, row counts are whole except their average
+			format, avgFormat := "%.6f", "%.6f"
+
+			if attr == digest.RowsSent || attr == digest.RowsExamined {
+				format, avgFormat = "%.0f", "%.2f"
+			}
+
+			fmt.Fprintf(tw, "# %s\t"+format+"\t"+format+"\t"+format+"\t"+avgFormat+"\t"+format+"\t"+format+"\n",
+				attr, m.Sum, m.Min, m.Max, m.Avg, m.Pct95, m.Median)
+		}
+
+		if c.Database != "" {
+			fmt.Fprintf(tw, "# Database\t%s\n", c.Database)
+		}
+
+		fmt.Fprintf(tw, "# Fingerprint\t%s\n%s\n\n", c.Fingerprint, c.Example)
+
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// printDigestReport writes the classes of a pt-query-digest report as a ranked profile
+func printDigestReport(w io.Writer, report *types.DigestReport) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	total := report.Global.Metrics["Query_time"].Sum
+
+	fmt.Fprintf(tw, "# pt-query-digest profile of %s: %d queries, %d unique\n", report.Source, report.Global.QueryCount, report.Global.UniqueQueryCount)
+	fmt.Fprintln(tw, "# Rank\tQuery ID\tResponse time\tCalls\tR/Call\tItem")
+
+	for i, c := range report.Classes {
+		qt := c.Metrics["Query_time"]
+		share := 0.0
+
+		if total > 0 {
+			share = float64(qt.Sum / total)
+		}
+
+		fmt.Fprintf(tw, "# %4d\t0x%s\t%.4f %5.1f%%\t%d\t%.4f\t%s\n",
+			i+1, c.Checksum, qt.Sum, share*100, c.QueryCount, qt.Avg, truncate(c.Fingerprint, 40))
+	}
+
+	fmt.Fprintln(tw)
+
+	return tw.Flush()
+}
+
+// truncate shortens a string to at most n characters, marking the cut with '...'
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return s[:n-3] + "..."
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"gopherDigest/pkg/config"
+	"gopherDigest/pkg/mysql"
+	"gopherDigest/pkg/mysql/mysqltest"
+	"gopherDigest/pkg/store"
+	"gopherDigest/pkg/types"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrintAnalyze(t *testing.T) {
+	root := &types.AnalyzeNode{
+		Operation: "Nested loop inner join", HasEstimate: true, EstimatedRows: 10, EstimatedCost: 12.5,
+		Executed: true, ActualRows: 10, Loops: 1, ActualFirstRow: 0.1, ActualLastRow: 2.5,
+		Children: []*types.AnalyzeNode{
+			{Operation: "Table scan on e", HasEstimate: true, EstimatedRows: 1, Executed: true, ActualRows: 500, Loops: 1},
+			{Operation: "Table scan on d", HasEstimate: true, EstimatedRows: 3},
+		},
+	}
+
+	root.FlagMisestimates(10)
+
+	var buf bytes.Buffer
+
+	if err := printAnalyze(&buf, root); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(buf.String(), "\n")
+
+	expected := []string{
+		"  -> Nested loop inner join  (estimated rows=10 cost=12.5)  (actual rows=10 loops=1 time=0.1..2.5)",
+		"!     -> Table scan on e  (estimated rows=1 cost=0)  (actual rows=500 loops=1 time=0..0)  [rows off by 500.0x]",
+		"      -> Table scan on d  (estimated rows=3 cost=0)  (never executed)",
+	}
+
+	for i, line := range expected {
+		if lines[i] != line {
+			t.Errorf("printAnalyze line %d should be\n%q\nbut got\n%q", i+1, line, lines[i])
+		}
+	}
+}
+
+func TestPrintAnalyzeNil(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := printAnalyze(&buf, nil); err != nil || buf.Len() != 0 {
+		t.Errorf("printAnalyze of a nil tree should print nothing, but got %q", buf.String())
+	}
+}
+
+// metricsResponse answers the query metrics of a digest, with the time and rows examined of its
+// latest execution and the number of times it was executed
+func metricsResponse(digest string, timerWait, rowsExamined, count int64) mysqltest.Response {
+	row := []driver.Value{[]byte(digest), timerWait, int64(0), int64(1), rowsExamined, count}
+
+	for len(row) < 26 {
+		row = append(row, int64(0))
+	}
+
+	return mysqltest.Response{Columns: make([]string, len(row)), Rows: [][]driver.Value{row}}
+}
+
+// explainResponses answers the statements captureDigest sends for a query, with a single table
+// scan as its plan and its latest execution as its metrics
+func explainResponses(query string) map[string]mysqltest.Response {
+	return map[string]mysqltest.Response{
+		"SELECT esh.DIGEST,": metricsResponse("d1", 2500000000, 300024, 4),
+		"EXPLAIN " + query: {
+			Columns: []string{"id", "select_type", "table", "partitions", "type", "possible_keys", "key", "key_len", "ref", "rows", "filtered", "Extra"},
+			Rows:    [][]driver.Value{{int64(1), []byte("SIMPLE"), []byte("employees"), nil, []byte("ALL"), nil, nil, nil, nil, int64(300024), []byte("100.00"), nil}},
+		},
+		"EXPLAIN FORMAT=JSON " + query: {
+			Columns: []string{"EXPLAIN"},
+			Rows:    [][]driver.Value{{[]byte(`{"query_block": {"select_id": 1, "cost_info": {"query_cost": "30560.65"}, "table": {"table_name": "employees", "access_type": "ALL"}}}`)}},
+		},
+	}
+}
+
+func TestCaptureDigest(t *testing.T) {
+	query := "SELECT * FROM employees WHERE emp_no = 10001"
+	db, srv := mysqltest.NewDB(t, explainResponses(query))
+
+	defer db.Close()
+
+	results := store.NewMemory()
+
+	if err := captureDigest(results, db, "employees", query, &analyzeOptions{}); err != nil {
+		t.Fatalf("captureDigest should not return an error, but got %s", err)
+	}
+
+	plans := results.Plans("")
+
+	if len(plans) != 1 {
+		t.Fatalf("captureDigest should save a single plan, but got %+v", plans)
+	}
+
+	rec := plans[0]
+	fingerprint := mysql.Fingerprint(query)
+
+	if rec.Search != query || rec.Fingerprint != fingerprint || rec.Checksum != mysql.Checksum(fingerprint) || rec.Schema != "employees" {
+		t.Errorf("captureDigest should save the query by its fingerprint and schema, but got %+v", rec)
+	}
+
+	if len(rec.SQLExplainRows) != 1 || rec.SQLExplainRows[0].Rows != 300024 || rec.ExplainPlan == nil || rec.ExplainPlan.QueryBlock.CostInfo.QueryCost != 30560.65 {
+		t.Errorf("captureDigest should save the EXPLAIN rows and plan of the query, but got %+v", rec)
+	}
+
+	if m := rec.Metrics; m == nil || m.Digest != "d1" || m.QueryTime != 0.0025 || m.RowsExamined != 300024 || m.CountStar != 4 {
+		t.Errorf("captureDigest should save the metrics of the latest execution of the query, but got %+v", m)
+	}
+
+	if rec.ExplainAnalyze != nil {
+		t.Errorf("captureDigest should not run EXPLAIN ANALYZE unless it is enabled, but got %+v", rec.ExplainAnalyze)
+	}
+
+	srv.SetResponse("EXPLAIN "+query, mysqltest.Response{Err: errors.New("Table 'employees.employees' doesn't exist")})
+
+	if err := captureDigest(results, db, "employees", query, &analyzeOptions{}); err == nil || !strings.Contains(err.Error(), "doesn't exist") {
+		t.Errorf("captureDigest should return the EXPLAIN error, but got %v", err)
+	}
+
+	srv.SetResponse("EXPLAIN "+query, explainResponses(query)["EXPLAIN "+query])
+
+	results.Close()
+
+	if err := captureDigest(results, db, "employees", query, &analyzeOptions{}); err != store.ErrClosed {
+		t.Errorf("captureDigest should return the error of the store, but got %v", err)
+	}
+}
+
+func TestBench(t *testing.T) {
+	query := "SELECT * FROM employees"
+	responses := explainResponses(query)
+	responses[query] = mysqltest.Response{Columns: []string{"emp_no"}}
+
+	db, srv := mysqltest.NewDB(t, responses)
+
+	defer db.Close()
+
+	results := store.NewMemory()
+	statements := []string{query, "DELETE FROM employees"}
+
+	if err := bench(results, db, "employees", statements, 3, &analyzeOptions{readOnly: true}); err != nil {
+		t.Fatalf("bench should not return an error, but got %s", err)
+	}
+
+	if plans := results.Plans(""); len(plans) != 3 {
+		t.Errorf("bench should save the digest of every run, but got %d", len(plans))
+	}
+
+	for _, stmt := range srv.Statements() {
+		if strings.HasPrefix(stmt, "DELETE") {
+			t.Errorf("bench should skip writes on a read-only target, but ran %s", stmt)
+		}
+	}
+
+	if err := bench(results, db, "employees", []string{"SELECT 1"}, 1, &analyzeOptions{}); err == nil {
+		t.Errorf("bench should return the error of a failing statement")
+	}
+}
+
+func TestPrintHistory(t *testing.T) {
+	results := store.NewMemory()
+	results.SavePlan(types.QueryRecord{Search: "SELECT 1", Fingerprint: "select ?", Checksum: "3A2B1C0D9E8F7A6B"})
+	results.SavePlan(types.QueryRecord{Search: "SELECT * FROM t", Fingerprint: "select * from t", Checksum: "0123456789ABCDEF"})
+
+	var buf bytes.Buffer
+
+	if err := printHistory(&buf, results, "0x3a2b1c0d9e8f7a6b", 10); err != nil {
+		t.Fatalf("printHistory should not return an error, but got %s", err)
+	}
+
+	if out := buf.String(); !strings.Contains(out, "Query ID 0x3A2B1C0D9E8F7A6B\n# select ?\nSELECT 1\n") || strings.Contains(out, "SELECT * FROM t") {
+		t.Errorf("printHistory should only print the query with the given checksum, but got\n%s", out)
+	}
+
+	buf.Reset()
+	printHistory(&buf, results, "", 1)
+
+	if out := buf.String(); !strings.Contains(out, "SELECT * FROM t") || strings.Contains(out, "SELECT 1") {
+		t.Errorf("printHistory should print the newest query first, up to the limit, but got\n%s", out)
+	}
+}
+
+func TestCollectDigests(t *testing.T) {
+	db, srv := mysqltest.NewDB(t, map[string]mysqltest.Response{
+		"SHOW GLOBAL STATUS LIKE 'Uptime'": {Columns: []string{"Variable_name", "Value"}, Rows: [][]driver.Value{{[]byte("Uptime"), []byte("3600")}}},
+		"SELECT SCHEMA_NAME, DIGEST":       {Columns: []string{"SCHEMA_NAME", "DIGEST"}},
+	})
+
+	defer db.Close()
+
+	results := store.NewMemory()
+	var buf bytes.Buffer
+
+	if err := collectDigests(context.Background(), &buf, results, db, time.Millisecond, 2, ""); err != nil {
+		t.Fatalf("collectDigests should not return an error, but got %s", err)
+	}
+
+	if lines := strings.Count(buf.String(), "stored the load of 0 statement digests"); lines != 2 {
+		t.Errorf("collectDigests should stop after 2 intervals, but got\n%s", buf.String())
+	}
+
+	// a baseline and a snapshot per interval
+	if uptimes := strings.Count(strings.Join(srv.Statements(), "\n"), "Uptime"); uptimes != 3 {
+		t.Errorf("collectDigests should snapshot the digest summary 3 times, but got %d", uptimes)
+	}
+
+	results.Close()
+
+	if err := collectDigests(context.Background(), &buf, results, db, time.Millisecond, 0, ""); err != store.ErrClosed {
+		t.Errorf("collectDigests should stop at the error of the store, but got %v", err)
+	}
+}
+
+// historyStore is a store that does not keep baselines
+type historyStore struct {
+	store.Store
+}
+
+func TestCollectDigestsBaseline(t *testing.T) {
+	db, _ := mysqltest.NewDB(t, map[string]mysqltest.Response{
+		"SHOW GLOBAL STATUS LIKE 'Uptime'": {Columns: []string{"Variable_name", "Value"}, Rows: [][]driver.Value{{[]byte("Uptime"), []byte("3600")}}},
+		"SELECT SCHEMA_NAME, DIGEST":       {Columns: []string{"SCHEMA_NAME", "DIGEST"}},
+	})
+
+	defer db.Close()
+
+	results := store.NewMemory()
+	var buf bytes.Buffer
+
+	if err := collectDigests(context.Background(), &buf, results, db, time.Millisecond, 1, "before"); err != nil {
+		t.Fatalf("collectDigests should not return an error, but got %s", err)
+	}
+
+	if !strings.Contains(buf.String(), "stored the baseline before of 0 statement digests") {
+		t.Errorf("collectDigests should save the baseline after the last interval, but got\n%s", buf.String())
+	}
+
+	err := collectDigests(context.Background(), &buf, historyStore{results}, db, time.Millisecond, 1, "before")
+
+	if err == nil || !strings.Contains(err.Error(), "does not keep baselines") {
+		t.Errorf("collectDigests should refuse a baseline for a store that does not keep them, but got %v", err)
+	}
+}
+
+func TestPrintBaseline(t *testing.T) {
+	results := store.NewMemory()
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	results.SaveBaseline([]types.Baseline{
+		{Name: "before", Schema: "employees", Digest: "d1", DigestText: "SELECT * FROM `employees`", Start: start, End: start.Add(time.Minute),
+			DigestCounters: types.DigestCounters{CountStar: 4, SumTimerWait: 8000000000, SumRowsExamined: 1200096}},
+		{Name: "before", Schema: "salaries", Digest: "d2", Start: start, End: start.Add(time.Minute), DigestCounters: types.DigestCounters{CountStar: 1}},
+		{Name: "after", Schema: "employees", Digest: "d1", DigestCounters: types.DigestCounters{CountStar: 1}},
+	})
+
+	var buf bytes.Buffer
+
+	if err := printBaseline(&buf, results, "before", "employees"); err != nil {
+		t.Fatalf("printBaseline should not return an error, but got %s", err)
+	}
+
+	for _, expected := range []string{"# Baseline before from 2026-10-18T12:00:00Z to 2026-10-18T12:01:00Z", "8ms", "2ms", "300024", "SELECT * FROM `employees`"} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("printBaseline should print %q, but got\n%s", expected, buf.String())
+		}
+	}
+
+	if strings.Contains(buf.String(), "salaries") || strings.Count(buf.String(), "d1") != 1 {
+		t.Errorf("printBaseline should only print the digests of the baseline in the schema, but got\n%s", buf.String())
+	}
+
+	if err := printBaseline(&buf, results, "missing", ""); err == nil {
+		t.Errorf("printBaseline should return an error for a baseline that was not saved")
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	db, _ := mysqltest.NewDB(t, map[string]mysqltest.Response{
+		"SHOW GRANTS FOR CURRENT_USER()": {
+			Columns: []string{"Grants for gopher@%"},
+			Rows:    [][]driver.Value{{[]byte("GRANT SELECT ON `performance_schema`.* TO `gopher`@`%`")}},
+		},
+	})
+
+	defer db.Close()
+
+	var buf bytes.Buffer
+
+	if err := preflight(&buf, db, mysql.PerformanceSchema); err != nil {
+		t.Fatalf("preflight should not return an error, but got %s", err)
+	}
+
+	for _, expected := range []string{"Checking MySQL Account Privileges", "Account: `gopher`@`%`", mysql.PerformanceSchema.Name} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("preflight should write %q, but got %q", expected, buf.String())
+		}
+	}
+
+	buf.Reset()
+
+	if err := preflight(&buf, db, mysql.Processlist); err == nil || !strings.Contains(buf.String(), mysql.Processlist.Name) {
+		t.Errorf("preflight should write the missing privileges and return an error, but got %q, %v", buf.String(), err)
+	}
+}
+
+func TestOpenStore(t *testing.T) {
+	s := config.DefaultSettings()
+	s.Store = config.StoreSettings{Driver: config.StoreMemory}
+
+	results, err := openStore(s)
+
+	if _, ok := results.(*store.Memory); err != nil || !ok {
+		t.Errorf("openStore should open an in-memory store, but got %T, %v", results, err)
+	}
+
+	if stat, err := checkStore(context.Background(), s, probeBackoff); err != nil || !stat.Connected {
+		t.Errorf("checkStore should report an in-memory store as connected, but got %+v, %v", stat, err)
+	}
+}
+
+func TestHealthServerReady(t *testing.T) {
+	s := config.DefaultSettings()
+	s.Store = config.StoreSettings{Driver: config.StoreMemory}
+	s.MySQL.Targets = map[string]*config.MySQLTarget{
+		config.DefaultTarget: {User: "root", Host: "127.0.0.1", Port: 3306, Socket: filepath.Join(t.TempDir(), "mysqld.sock")},
+	}
+	s.MySQL.Target = config.DefaultTarget
+
+	// mysqld runs elsewhere, so the socket it reports does not exist here
+	connectMySQL = func(m mysql.MySQL) (*sql.DB, error) {
+		db, _ := mysqltest.NewDB(t, map[string]mysqltest.Response{
+			"SELECT @@hostname": {
+				Columns: []string{"@@hostname", "@@port", "@@socket", "VERSION()"},
+				Rows:    [][]driver.Value{{[]byte("db"), int64(3306), []byte("/var/run/mysqld/mysqld.sock"), []byte("8.0.21")}},
+			},
+			"SHOW SESSION STATUS LIKE 'Ssl_cipher'": {
+				Columns: []string{"Variable_name", "Value"},
+				Rows:    [][]driver.Value{{[]byte("Ssl_cipher"), []byte("")}},
+			},
+		})
+
+		return db, nil
+	}
+
+	defer func() { connectMySQL = mysql.Connect }()
+
+	// /readyz also runs the liveness probes, which look for the tools installed in the image
+	srv := healthServer(s)
+	srv.Live = nil
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("/readyz should return %d without a local MySQL socket, but got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+}
@@ -1,77 +1,165 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"gopherDigest/pkg/config"
-	"gopherDigest/pkg/mysql"
-	"gopherDigest/pkg/rethinkdb"
+	"io"
 	"log"
 	"os"
-	"sync"
+	"sort"
+	"strings"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
-func main() {
-	var err error
+// command defines a gopherDigest subcommand and its flags
+type command struct {
+	name    string
+	summary string
+	flags   *flag.FlagSet
+	run     func() error
+}
 
-	_, err = config.New()
+// newCommand creates a subcommand with its own flag set and help text
+func newCommand(name, summary string, out io.Writer) *command {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(out)
 
-	if err != nil {
-		log.Fatal(err)
-	}
+	cmd := &command{name: name, summary: summary, flags: fs}
 
-	rConfig := rethinkdb.New(
-		config.GetSecrets(os.Getenv, "RDB", "_", "USERNAME", "PASSWORD", "DATABASE", "ADDRESS")...)
+	fs.Usage = func() {
+		fmt.Fprintf(out, "Usage: gopherDigest %s [flags]\n\n%s\n\nFlags:\n", cmd.name, cmd.summary)
+		fs.PrintDefaults()
+	}
 
-	RDBsession, err := rethinkdb.Init(*rConfig)
-	defer RDBsession.Close()
+	return cmd
+}
 
-	if err != nil {
-		log.Fatalln(err)
+// commands builds the set of available subcommands keyed by name, whose flags default to the settings
+func commands(out io.Writer, s *config.Settings) map[string]*command {
+	cmds := map[string]*command{}
+
+	for _, c := range []*command{
+		newInitCommand(out, s),
+		newCheckCommand(out, s),
+		newExplainCommand(out, s),
+		newDigestCommand(out, s),
+		newBenchCommand(out, s),
+		newReportCommand(out, s),
+		newProfileCommand(out, s),
+		newPTDigestCommand(out, s),
+		newCollectCommand(out, s),
+		newRestoreCommand(out, s),
+		newSecretsCommand(out, s),
+	} {
+		cmds[c.name] = c
 	}
 
-	mysqlRootConfig := mysql.New("",
-		config.GetSecrets(os.Getenv, "MYSQL", "_", "USER", "PASSWORD", "HOST", "PORT", "MAX_CONNECTIONS")...)
+	return cmds
+}
 
-	mysqlUserConfig := mysql.New("employees",
-		config.GetSecrets(os.Getenv, "MYSQL", "_", "USER", "PASSWORD", "HOST", "PORT", "MAX_CONNECTIONS")...)
+// usage prints the top level help text listing every subcommand and the global flags
+func usage(w io.Writer, cmds map[string]*command, global *flag.FlagSet) {
+	names := []string{}
 
-	// TODO: only init if the database isn't initialized
-	db, err := mysql.Init(mysqlRootConfig)
-	defer db.Close()
+	for name := range cmds {
+		names = append(names, name)
+	}
 
-	if err != nil {
-		log.Fatal(err)
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "Usage: gopherDigest [-config file] [-target name] [-store url] [-env-file file] <command> [flags]\n\nCommands:\n")
+
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, cmds[name].summary)
 	}
 
-	queryString := "SELECT * FROM salaries s LEFT JOIN employees e USING(emp_no) LEFT JOIN dept_emp d USING(emp_no)"
+	fmt.Fprintf(w, "\nGlobal flags:\n")
+	global.SetOutput(w)
+	global.PrintDefaults()
+
+	fmt.Fprintf(w, "\nRun 'gopherDigest <command> -h' for help with a command.\n")
+}
+
+// run parses the global flags and the subcommand from the arguments, loads the settings and
+// executes the subcommand
+func run(args []string, out io.Writer) error {
+	global := flag.NewFlagSet("gopherDigest", flag.ContinueOnError)
+	path := global.String("config", os.Getenv("GOPHERDIGEST_CONFIG"), "YAML configuration file, overridden by the environment and flags (env GOPHERDIGEST_CONFIG)")
+	target := global.String("target", "", "name of the configured MySQL target to run against (env MYSQL_TARGET)")
+	storeURL := global.String("store", "", "where results are stored, rethinkdb, memory, sqlite:///path.db or jsonl:///path.jsonl (env GOPHERDIGEST_STORE)")
+	envFile := global.String("env-file", os.Getenv("GOPHERDIGEST_ENV_FILE"), "`.env` file read after the environment, <KEY>_FILE variables and Docker secrets, .env if it exists (env GOPHERDIGEST_ENV_FILE)")
 
-	db2, _ := mysql.Connect(mysqlUserConfig)
+	// the help text lists the flags with their built in defaults, as the settings may not load
+	cmds := commands(out, config.DefaultSettings())
 
-	defer db2.Close()
+	global.SetOutput(out)
+	global.Usage = func() { usage(out, cmds, global) }
 
-	// TODO: set MySQL max connections as a configurable based on available ram and buffers
-	for i := 0; i < mysqlUserConfig.GetMaxConns(); i++ {
-		if err != nil {
-			log.Fatal(err)
+	if err := global.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
 		}
+		return err
+	}
 
-		var wg sync.WaitGroup
-		wg.Add(1)
+	args = global.Args()
 
-		go func() {
-			defer wg.Done()
-			go db2.Query(queryString)
-		}()
+	if len(args) == 0 {
+		usage(out, cmds, global)
+		return fmt.Errorf("missing command")
+	}
 
-		wg.Wait()
+	name := strings.TrimLeft(args[0], "-")
 
-		explainCh := make(chan *rethinkdb.SQLExplainRow)
+	if name == "help" || name == "h" {
+		usage(out, cmds, global)
+		return nil
+	}
 
-		go mysql.FetchEventSummary(db2, queryString, &explainCh)
+	if _, ok := cmds[name]; !ok {
+		usage(out, cmds, global)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
 
-		explain := <-explainCh
+	secrets, err := secretProviders(*envFile)
 
-		rethinkdb.InsertSQLExplain(RDBsession, []rethinkdb.SQLExplainRow{*explain}, queryString)
+	if err != nil {
+		return err
+	}
+
+	settings, err := config.Load(*path, secrets, *target)
+
+	if err != nil {
+		return err
+	}
+
+	if *storeURL != "" {
+		if settings.Store, err = config.ParseStore(*storeURL); err != nil {
+			return fmt.Errorf("invalid configuration\n  -store: %s", err)
+		}
+	}
+
+	cmd := commands(out, settings)[name]
+
+	if err := cmd.flags.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+
+	if settings.Health.Address != "" {
+		stop := serveHealth(settings)
+		defer stop()
+	}
+
+	return cmd.run()
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stderr); err != nil {
+		log.Fatal(err)
 	}
 }
@@ -1 +1,55 @@
 package main
+
+import (
+	"bytes"
+	"gopherDigest/pkg/config"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tt := []struct {
+		name        string
+		args        []string
+		expectedErr string
+		expectedOut string
+	}{
+		{"Missing Command", []string{}, "missing command", "Commands:"},
+		{"Unknown Command", []string{"foo"}, `unknown command "foo"`, "Commands:"},
+		{"Help", []string{"help"}, "", "report"},
+		{"Subcommand Help", []string{"explain", "-h"}, "", "Usage: gopherDigest explain [flags]"},
+		{"Bad Flag", []string{"bench", "-bogus"}, "flag provided but not defined: -bogus", "Usage: gopherDigest bench [flags]"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			err := run(tc.args, &out)
+
+			if tc.expectedErr == "" && err != nil {
+				t.Fatalf("run of %v should not return an error, but got %s", tc.args, err)
+			}
+
+			if tc.expectedErr != "" && (err == nil || err.Error() != tc.expectedErr) {
+				t.Fatalf("run of %v should return error %q, but got %v", tc.args, tc.expectedErr, err)
+			}
+
+			if !strings.Contains(out.String(), tc.expectedOut) {
+				t.Errorf("run of %v should print %q, but got\n%s", tc.args, tc.expectedOut, out.String())
+			}
+		})
+	}
+}
+
+func TestCommands(t *testing.T) {
+	expected := []string{"init", "check", "explain", "digest", "bench", "report", "profile", "ptdigest", "collect", "restore", "secrets"}
+
+	cmds := commands(&bytes.Buffer{}, config.DefaultSettings())
+
+	for _, name := range expected {
+		if _, ok := cmds[name]; !ok {
+			t.Errorf("commands should include %q", name)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gopherDigest/pkg/config"
+	"gopherDigest/pkg/mysql"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// stringList is a flag that may be given multiple times
+type stringList []string
+
+// String returns the flag values as a comma separated list
+func (s *stringList) String() string {
+	return strings.Join(*s, ", ")
+}
+
+// Set appends a value to the list each time the flag is given
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+
+	return nil
+}
+
+// querySource collects the SQL statements under test from flags, files and stdin, or from the
+// workload of the configuration file
+type querySource struct {
+	queries  stringList
+	files    stringList
+	workload config.WorkloadSettings
+}
+
+// addQueryFlags registers the -query and -file flags on a subcommand's flag set
+func addQueryFlags(fs *flag.FlagSet, workload config.WorkloadSettings) *querySource {
+	qs := &querySource{workload: workload}
+
+	fs.Var(&qs.queries, "query", "SQL statement to run, may be repeated (defaults to the employees join)")
+	fs.Var(&qs.files, "file", "file of ';' separated SQL statements, may be repeated ('-' reads stdin)")
+
+	return qs
+}
+
+// statements returns every statement given on the command line, in files or piped
+// through stdin, falling back to the configured workload and then the default query
+// when none were given
+func (qs *querySource) statements(stdin *os.File) ([]string, error) {
+	statements, err := readStatements(qs.queries, qs.files, stdin)
+
+	if err != nil {
+		return nil, err
+	}
+
+	given := len(qs.queries) > 0 || len(qs.files) > 0
+
+	// an empty pipe, as found under some process supervisors, is not a script
+	if !given && isPiped(stdin) {
+		script, err := readScript("-", stdin)
+
+		if err != nil {
+			return nil, err
+		}
+
+		statements = append(statements, mysql.SplitStatements(script)...)
+		given = len(statements) > 0
+	}
+
+	if !given && (len(qs.workload.Queries) > 0 || len(qs.workload.Files) > 0) {
+		if statements, err = readStatements(qs.workload.Queries, qs.workload.Files, stdin); err != nil {
+			return nil, err
+		}
+
+		given = true
+	}
+
+	if !given {
+		return []string{defaultQuery}, nil
+	}
+
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("no SQL statements were found in the given queries or files")
+	}
+
+	return statements, nil
+}
+
+// readStatements splits queries and the scripts of files into statements
+func readStatements(queries, files []string, stdin io.Reader) ([]string, error) {
+	statements := []string{}
+
+	for _, q := range queries {
+		statements = append(statements, mysql.SplitStatements(q)...)
+	}
+
+	for _, path := range files {
+		script, err := readScript(path, stdin)
+
+		if err != nil {
+			return nil, err
+		}
+
+		statements = append(statements, mysql.SplitStatements(script)...)
+	}
+
+	return statements, nil
+}
+
+// readScript reads a SQL script from a file path, or from stdin when the path is '-'
+func readScript(path string, stdin io.Reader) (string, error) {
+	var script []byte
+	var err error
+
+	if path == "-" {
+		script, err = ioutil.ReadAll(stdin)
+	} else {
+		script, err = ioutil.ReadFile(path)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("could not read SQL statements from %s\n%s", path, err)
+	}
+
+	return string(script), nil
+}
+
+// isPiped reports whether a file is a pipe or redirected file rather than a terminal
+func isPiped(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+
+	fi, err := f.Stat()
+
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeNamedPipe != 0 || fi.Mode().IsRegular()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"gopherDigest/pkg/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStatements(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gopherDigest")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "workload.sql")
+	ioutil.WriteFile(script, []byte("SELECT * FROM employees;\nSELECT * FROM salaries;\n"), 0644)
+
+	empty := filepath.Join(dir, "empty.sql")
+	ioutil.WriteFile(empty, []byte("-- nothing to see here\n"), 0644)
+
+	tt := []struct {
+		name        string
+		args        []string
+		stdin       string
+		expected    []string
+		expectedErr bool
+	}{
+		{"Default Query", []string{}, "", []string{defaultQuery}, false},
+		{"Single Query", []string{"-query", "SELECT 1"}, "", []string{"SELECT 1"}, false},
+		{"Repeated Queries", []string{"-query", "SELECT 1", "-query", "SELECT 2; SELECT 3"}, "",
+			[]string{"SELECT 1", "SELECT 2", "SELECT 3"}, false},
+		{"File", []string{"-file", script}, "",
+			[]string{"SELECT * FROM employees", "SELECT * FROM salaries"}, false},
+		{"Queries Then Files", []string{"-file", script, "-query", "SELECT 1"}, "",
+			[]string{"SELECT 1", "SELECT * FROM employees", "SELECT * FROM salaries"}, false},
+		{"Explicit Stdin", []string{"-file", "-"}, "SELECT 4;SELECT 5", []string{"SELECT 4", "SELECT 5"}, false},
+		{"Piped Stdin", []string{}, "SELECT 6;", []string{"SELECT 6"}, false},
+		{"Empty Piped Stdin", []string{}, "\n", []string{defaultQuery}, false},
+		{"Missing File", []string{"-file", filepath.Join(dir, "missing.sql")}, "", nil, true},
+		{"File Without Statements", []string{"-file", empty}, "", nil, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := flag.NewFlagSet(tc.name, flag.ContinueOnError)
+			qs := addQueryFlags(fs, config.WorkloadSettings{})
+
+			if err := fs.Parse(tc.args); err != nil {
+				t.Fatal(err)
+			}
+
+			var stdin *os.File
+
+			if tc.stdin != "" {
+				stdin, _ = ioutil.TempFile(dir, "stdin")
+				stdin.WriteString(tc.stdin)
+				stdin.Seek(0, 0)
+				defer stdin.Close()
+			}
+
+			actual, err := qs.statements(stdin)
+
+			if tc.expectedErr != (err != nil) {
+				t.Fatalf("statements of %v should return an error: %v, but got %v", tc.args, tc.expectedErr, err)
+			}
+
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("statements of %v should be %q, but got %q", tc.args, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestStatementsWorkload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gopherDigest")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "workload.sql")
+	ioutil.WriteFile(script, []byte("SELECT * FROM titles;"), 0644)
+
+	workload := config.WorkloadSettings{Queries: []string{"SELECT 1; SELECT 2"}, Files: []string{script}}
+
+	tt := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"Configured Workload", []string{}, []string{"SELECT 1", "SELECT 2", "SELECT * FROM titles"}},
+		{"Flags Override The Workload", []string{"-query", "SELECT 3"}, []string{"SELECT 3"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := flag.NewFlagSet(tc.name, flag.ContinueOnError)
+			qs := addQueryFlags(fs, workload)
+
+			if err := fs.Parse(tc.args); err != nil {
+				t.Fatal(err)
+			}
+
+			actual, err := qs.statements(nil)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("statements of %v should be %q, but got %q", tc.args, tc.expected, actual)
+			}
+		})
+	}
+}
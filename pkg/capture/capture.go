@@ -0,0 +1,117 @@
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"gopherDigest/pkg/slowlog"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/google/gopacket/reassembly"
+)
+
+// DefaultPort is the port the MySQL server listens on
+const DefaultPort = 3306
+
+// pcapngMagic starts the section header block of a pcapng file
+var pcapngMagic = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+
+// packetSource reads link layer frames from a capture file
+type packetSource interface {
+	ReadPacketData() ([]byte, gopacket.CaptureInfo, error)
+	LinkType() layers.LinkType
+}
+
+// ReadFile decodes the MySQL conversations in a pcap or pcapng file into query events
+func ReadFile(path string, port int) ([]slowlog.Event, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not open the capture %s\n%s", path, err)
+	}
+
+	defer f.Close()
+
+	return Read(f, port)
+}
+
+// Read decodes the MySQL conversations with a server port in a pcap or pcapng capture,
+// such as one written by 'tcpdump -w', into query events ordered by their start time.
+// TCP streams are reassembled before their MySQL packets are decoded, so retransmitted
+// and reordered segments are handled.
+func Read(r io.Reader, port int) ([]slowlog.Event, error) {
+	src, err := openCapture(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	factory := newSessionFactory(layers.TCPPort(port))
+	assembler := reassembly.NewAssembler(reassembly.NewStreamPool(factory))
+
+	for {
+		data, ci, err := src.ReadPacketData()
+
+		// tcpdump leaves a partial packet at the end of a capture when it is killed
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("could not read the capture\n%s", err)
+		}
+
+		packet := gopacket.NewPacket(data, src.LinkType(), gopacket.DecodeOptions{Lazy: true, NoCopy: true})
+		tcp, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+
+		if !ok || packet.NetworkLayer() == nil || tcp.SrcPort != factory.port && tcp.DstPort != factory.port {
+			continue
+		}
+
+		ctx := captureContext(ci)
+		assembler.AssembleWithContext(packet.NetworkLayer().NetworkFlow(), tcp, &ctx)
+	}
+
+	assembler.FlushAll()
+
+	events := factory.events
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+
+	return events, nil
+}
+
+// openCapture detects whether a capture is a pcap or a pcapng file
+func openCapture(r io.Reader) (packetSource, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read the capture header\n%s", err)
+	}
+
+	if bytes.Equal(magic, pcapngMagic) {
+		ng, err := pcapgo.NewNgReader(br, pcapgo.DefaultNgReaderOptions)
+
+		if err != nil {
+			return nil, fmt.Errorf("could not read the pcapng capture\n%s", err)
+		}
+
+		return ng, nil
+	}
+
+	pcap, err := pcapgo.NewReader(br)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read the pcap capture\n%s", err)
+	}
+
+	return pcap, nil
+}
@@ -0,0 +1,125 @@
+package capture
+
+import (
+	"bytes"
+	"gopherDigest/pkg/slowlog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// The captures in testdata are synthetic: session.pcap holds a whole connection using
+// CLIENT_DEPRECATE_EOF, with a resultset whose segments were captured out of order and
+// retransmitted, and session.pcapng holds a connection captured after it was established,
+// using EOF packets and nanosecond timestamps.
+
+func TestReadFilePcap(t *testing.T) {
+	events, err := ReadFile("testdata/session.pcap", DefaultPort)
+
+	if err != nil {
+		t.Fatalf("ReadFile should not return an error, but got %s", err)
+	}
+
+	at := time.Date(2023, 11, 2, 14, 0, 0, 0, time.UTC)
+	event := func(offset time.Duration, query string, queryTime float64, rowsSent int64, attributes map[string]string) slowlog.Event {
+		attributes["Source"] = "tcpdump"
+
+		return slowlog.Event{
+			Time: at.Add(offset), User: "app", Host: "10.0.0.12", IP: "10.0.0.12", ThreadID: 42, Database: "employees",
+			Query: query, QueryTime: queryTime, RowsSent: rowsSent, Attributes: attributes,
+		}
+	}
+
+	expected := []slowlog.Event{
+		event(time.Second, "SELECT * FROM salaries WHERE emp_no = 10001", 0.0025, 3, map[string]string{"Command": "Query"}),
+		event(2*time.Second, "UPDATE employees SET last_name = 'Facello' WHERE emp_no = 10001", 0.004, 0,
+			map[string]string{"Command": "Query", "Rows_affected": "1"}),
+		event(3*time.Second, "SELECT * FROM missing", 0.001, 0, map[string]string{
+			"Command": "Query", "Error_no": "1146", "Error_msg": "Table 'employees.missing' doesn't exist",
+		}),
+		event(4*time.Second, "PREPARE SELECT first_name FROM employees WHERE emp_no = ?", 0.001, 0,
+			map[string]string{"Command": "Prepare", "Statement_id": "1"}),
+		event(5*time.Second, "SELECT first_name FROM employees WHERE emp_no = ?", 0.002, 1,
+			map[string]string{"Command": "Execute", "Statement_id": "1"}),
+		event(6500*time.Millisecond, "administrator command: Quit", 0, 0, map[string]string{"Command": "Quit"}),
+	}
+
+	if len(events) != len(expected) {
+		t.Fatalf("ReadFile should return %d events, but got %d\n%+v", len(expected), len(events), events)
+	}
+
+	for i := range expected {
+		if !reflect.DeepEqual(events[i], expected[i]) {
+			t.Errorf("event %d should be\n%+v\nbut got\n%+v", i+1, expected[i], events[i])
+		}
+	}
+}
+
+func TestReadFilePcapng(t *testing.T) {
+	events, err := ReadFile("testdata/session.pcapng", DefaultPort)
+
+	if err != nil {
+		t.Fatalf("ReadFile should not return an error, but got %s", err)
+	}
+
+	at := time.Date(2023, 11, 2, 14, 0, 0, 0, time.UTC)
+	expected := []struct {
+		time      time.Time
+		database  string
+		query     string
+		queryTime float64
+		rowsSent  int64
+	}{
+		{at.Add(time.Second), "", "USE `employees`", 0.00025, 0},
+		{at.Add(2 * time.Second), "employees", "SELECT COUNT(*) FROM dept_emp", 0.001234567, 1},
+		{at.Add(3 * time.Second), "employees", "CALL current_managers()", 0.005, 2},
+	}
+
+	if len(events) != len(expected) {
+		t.Fatalf("ReadFile should return %d events, but got %d\n%+v", len(expected), len(events), events)
+	}
+
+	for i, e := range expected {
+		actual := events[i]
+
+		if !actual.Time.Equal(e.time) || actual.Database != e.database || actual.Query != e.query ||
+			actual.QueryTime != e.queryTime || actual.RowsSent != e.rowsSent || actual.IP != "10.0.0.20" {
+			t.Errorf("event %d should be %+v, but got %+v", i+1, e, actual)
+		}
+	}
+}
+
+func TestReadOtherPort(t *testing.T) {
+	events, err := ReadFile("testdata/session.pcap", 3307)
+
+	if err != nil || len(events) != 0 {
+		t.Errorf("ReadFile should ignore connections to other ports, but got %+v, %v", events, err)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	if _, err := Read(bytes.NewReader([]byte("# Time: 2023-11-02T14:03:11.254153Z\n")), DefaultPort); err == nil {
+		t.Errorf("Read should return an error for a file that is not a capture")
+	}
+
+	if _, err := ReadFile("testdata/missing.pcap", DefaultPort); err == nil {
+		t.Errorf("ReadFile should return an error for a missing file")
+	}
+}
+
+func TestPacketBuffer(t *testing.T) {
+	t0 := time.Date(2023, 11, 2, 14, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Millisecond)
+	large := make([]byte, maxPayload)
+	var b packetBuffer
+
+	if packets := b.write([]byte{0xff, 0xff, 0xff, 0}, t0); len(packets) != 0 {
+		t.Fatalf("write should wait for the rest of a packet, but got %+v", packets)
+	}
+
+	packets := b.write(append(append(large, 3, 0, 0, 1), []byte("abc")...), t1)
+
+	if len(packets) != 1 || len(packets[0].payload) != maxPayload+3 || !packets[0].start.Equal(t0) || !packets[0].end.Equal(t1) {
+		t.Errorf("write should join a payload continued in the next packet, but got %d packets", len(packets))
+	}
+}
@@ -0,0 +1,476 @@
+package capture
+
+import (
+	"encoding/binary"
+	"gopherDigest/pkg/slowlog"
+	"regexp"
+	"strconv"
+)
+
+// Phases of a MySQL connection
+const (
+	phaseHandshake = iota
+	phaseAuth
+	phaseCommand
+	phaseEncrypted
+)
+
+// Capability flags sent by the client in its handshake response
+const (
+	clientConnectWithDB        = 0x00000008
+	clientSSL                  = 0x00000800
+	clientSecureConnection     = 0x00008000
+	clientPluginAuthLenencData = 0x00200000
+	clientQueryAttributes      = 0x08000000
+)
+
+// serverMoreResultsExist is the status flag of a response followed by another resultset
+const serverMoreResultsExist = 0x0008
+
+// handshakeResponseFixedLength is the length of the capabilities, max packet size, charset and filler
+// that start a handshake response
+const handshakeResponseFixedLength = 32
+
+// Commands sent by the client at the start of a request
+const (
+	comQuit        = 0x01
+	comInitDB      = 0x02
+	comQuery       = 0x03
+	comChangeUser  = 0x11
+	comStmtPrepare = 0x16
+	comStmtExecute = 0x17
+	comLongData    = 0x18
+	comStmtClose   = 0x19
+	comStmtFetch   = 0x1c
+)
+
+// commandNames are the names MySQL logs other commands under as 'administrator command: <name>'
+var commandNames = map[byte]string{
+	0x00: "Sleep", comQuit: "Quit", comInitDB: "Init DB", comQuery: "Query", 0x04: "Field List", 0x05: "Create DB",
+	0x06: "Drop DB", 0x07: "Refresh", 0x08: "Shutdown", 0x09: "Statistics", 0x0a: "Processlist",
+	0x0b: "Connect", 0x0c: "Kill", 0x0d: "Debug", 0x0e: "Ping", 0x0f: "Time", 0x10: "Delayed insert",
+	comChangeUser: "Change user", 0x12: "Binlog Dump", 0x13: "Table Dump", 0x14: "Connect Out",
+	0x15: "Register Slave", comStmtPrepare: "Prepare", comStmtExecute: "Execute", comLongData: "Long Data",
+	comStmtClose: "Close stmt", 0x1a: "Reset stmt", 0x1b: "Set option", comStmtFetch: "Fetch", 0x1d: "Daemon",
+	0x1e: "Binlog Dump GTID", 0x1f: "Reset Connection",
+}
+
+// States of the response to a request
+const (
+	responseFirst = iota
+	responseColumns
+	responseColumnsEOF
+	responseRows
+	responseDone
+)
+
+var useDB = regexp.MustCompile("(?i)^\\s*use\\s+`?([^`;\\s]+)`?\\s*;?\\s*$")
+
+// session decodes the MySQL conversation of a single connection
+type session struct {
+	emit       func(slowlog.Event)
+	client     string
+	phase      int
+	caps       uint32
+	threadID   int64
+	user       string
+	db         string
+	statements map[uint32]string
+	pending    *request
+}
+
+// request is a command waiting for, or reading, its response
+type request struct {
+	event     slowlog.Event
+	command   byte
+	query     string
+	useDB     string
+	responded bool
+	state     int
+	columns   uint64
+	affected  uint64
+}
+
+func newSession(client string, emit func(slowlog.Event)) *session {
+	return &session{emit: emit, client: client, statements: map[uint32]string{}}
+}
+
+// joinedLate is called when the capture started after the connection was established
+func (s *session) joinedLate() {
+	if s.phase == phaseHandshake {
+		s.phase = phaseCommand
+	}
+}
+
+// lost is called when a gap in the capture means a response can no longer be matched to its request
+func (s *session) lost() {
+	s.pending = nil
+}
+
+// finish emits the request still pending when the connection closes or the capture ends
+func (s *session) finish() {
+	p := s.pending
+	s.pending = nil
+
+	// a request without a response is only complete if the client expects none
+	if p == nil || !p.responded && p.command != comQuit {
+		return
+	}
+
+	if p.affected > 0 {
+		p.event.Attributes["Rows_affected"] = strconv.FormatUint(p.affected, 10)
+	}
+
+	s.emit(p.event)
+}
+
+// clientPacket handles a packet sent by the client
+func (s *session) clientPacket(p packet) {
+	switch s.phase {
+	case phaseAuth:
+		if p.seq == 1 {
+			s.handshakeResponse(p.payload)
+		}
+	case phaseCommand:
+		// every command starts a new sequence, other client packets carry LOAD DATA LOCAL
+		// contents or authentication data
+		if p.seq == 0 && len(p.payload) > 0 {
+			s.finish()
+			s.command(p)
+		}
+	}
+}
+
+// serverPacket handles a packet sent by the server
+func (s *session) serverPacket(p packet) {
+	if len(p.payload) == 0 {
+		return
+	}
+
+	switch s.phase {
+	case phaseHandshake:
+		s.greeting(p.payload)
+	case phaseAuth:
+		if p.payload[0] == 0x00 || p.payload[0] == 0xff {
+			s.phase = phaseCommand
+		}
+	case phaseCommand:
+		if s.pending != nil {
+			s.response(p)
+		}
+	}
+}
+
+// greeting reads the connection id from the initial handshake of the server
+func (s *session) greeting(b []byte) {
+	s.phase = phaseAuth
+
+	if b[0] != 10 {
+		return
+	}
+
+	if _, rest := nullTerminated(b[1:]); len(rest) >= 4 {
+		s.threadID = int64(binary.LittleEndian.Uint32(rest[:4]))
+	}
+}
+
+// handshakeResponse reads the user and the default database the client connects with
+func (s *session) handshakeResponse(b []byte) {
+	if len(b) < handshakeResponseFixedLength {
+		return
+	}
+
+	s.caps = binary.LittleEndian.Uint32(b[:4])
+
+	// the rest of the handshake is sent over TLS after a short SSL request
+	if s.caps&clientSSL != 0 && len(b) == handshakeResponseFixedLength {
+		s.phase = phaseEncrypted
+		return
+	}
+
+	user, rest := nullTerminated(b[handshakeResponseFixedLength:])
+	s.user = user
+
+	switch {
+	case s.caps&clientPluginAuthLenencData != 0:
+		n, size := lenencInt(rest)
+
+		if size == 0 || uint64(len(rest)) < uint64(size)+n {
+			return
+		}
+
+		rest = rest[uint64(size)+n:]
+	case s.caps&clientSecureConnection != 0:
+		if len(rest) == 0 || len(rest) < 1+int(rest[0]) {
+			return
+		}
+
+		rest = rest[1+int(rest[0]):]
+	default:
+		_, rest = nullTerminated(rest)
+	}
+
+	if s.caps&clientConnectWithDB != 0 {
+		s.db, _ = nullTerminated(rest)
+	}
+}
+
+// command starts a request for a command sent by the client
+func (s *session) command(p packet) {
+	cmd, body := p.payload[0], p.payload[1:]
+
+	r := &request{
+		command: cmd,
+		event: slowlog.Event{
+			Time:       p.start.UTC(),
+			User:       s.user,
+			Host:       s.client,
+			IP:         s.client,
+			ThreadID:   s.threadID,
+			Database:   s.db,
+			Attributes: map[string]string{"Source": "tcpdump", "Command": commandName(cmd)},
+		},
+	}
+
+	// commands other than statements are logged by their name
+	r.query = "administrator command: " + commandName(cmd)
+
+	switch cmd {
+	case comQuery:
+		query, ok := s.queryText(body)
+
+		if !ok {
+			return
+		}
+
+		r.query = query
+
+		if m := useDB.FindStringSubmatch(query); m != nil {
+			r.useDB = m[1]
+		}
+	case comInitDB:
+		r.useDB = string(body)
+	case comStmtPrepare:
+		r.query = "PREPARE " + string(body)
+	case comStmtExecute:
+		if len(body) >= 4 {
+			id := binary.LittleEndian.Uint32(body[:4])
+			r.event.Attributes["Statement_id"] = strconv.FormatUint(uint64(id), 10)
+
+			if text, ok := s.statements[id]; ok {
+				r.query = text
+			}
+		}
+	case comStmtClose:
+		if len(body) >= 4 {
+			delete(s.statements, binary.LittleEndian.Uint32(body[:4]))
+		}
+
+		return
+	case comLongData:
+		return
+	case comStmtFetch:
+		r.state = responseRows
+	case comChangeUser:
+		s.user, _ = nullTerminated(body)
+		r.event.User = s.user
+	}
+
+	r.event.Query = r.query
+	s.pending = r
+}
+
+// queryText reads the statement of COM_QUERY. Query attributes are skipped only when there are none,
+// as their values would have to be decoded to find where the statement starts.
+func (s *session) queryText(b []byte) (string, bool) {
+	if s.caps&clientQueryAttributes == 0 {
+		return string(b), true
+	}
+
+	params, size := lenencInt(b)
+
+	if size == 0 || params > 0 {
+		return "", false
+	}
+
+	_, sets := lenencInt(b[size:])
+
+	if sets == 0 {
+		return "", false
+	}
+
+	return string(b[size+sets:]), true
+}
+
+// response advances the pending request through a packet of its response
+func (s *session) response(p packet) {
+	r := s.pending
+	b := p.payload
+
+	if r.state == responseDone {
+		// the parameter and column definitions that follow a prepared statement's OK
+		if r.command == comStmtPrepare {
+			r.setEnd(p)
+		}
+
+		return
+	}
+
+	r.responded = true
+	r.setEnd(p)
+
+	switch r.state {
+	case responseFirst:
+		switch b[0] {
+		case 0x00:
+			if r.command == comStmtPrepare {
+				s.prepared(r, b)
+				return
+			}
+
+			s.ok(r, b[1:])
+		case 0xff:
+			r.error(b)
+		case 0xfb, 0xfe:
+			// the client is asked for a LOAD DATA LOCAL file or to switch authentication method
+		default:
+			columns, size := lenencInt(b)
+
+			if size == 0 {
+				r.state = responseDone
+				return
+			}
+
+			r.columns = columns
+			r.state = responseColumns
+		}
+	case responseColumns:
+		r.columns--
+
+		if r.columns == 0 {
+			r.state = responseColumnsEOF
+		}
+	case responseColumnsEOF:
+		r.state = responseRows
+
+		// connections without CLIENT_DEPRECATE_EOF end the column definitions with an EOF packet
+		if !isEOF(b) {
+			s.row(r, p)
+		}
+	case responseRows:
+		s.row(r, p)
+	}
+}
+
+// row handles a packet of a resultset's rows
+func (s *session) row(r *request, p packet) {
+	b := p.payload
+
+	switch {
+	case b[0] == 0xff:
+		r.error(b)
+	case b[0] == 0xfe && len(b) < maxPayload:
+		// the rows end with an EOF packet, or an OK packet with an EOF header
+		status := uint16(0)
+
+		if len(b) == 5 {
+			status = binary.LittleEndian.Uint16(b[3:5])
+		} else if _, st, ok := okPacket(b[1:]); ok {
+			status = st
+		}
+
+		r.state = responseDone
+
+		if status&serverMoreResultsExist != 0 {
+			r.state = responseFirst
+		}
+	default:
+		r.event.RowsSent++
+	}
+}
+
+// ok completes a request with an OK packet, unless more results follow
+func (s *session) ok(r *request, b []byte) {
+	affected, status, _ := okPacket(b)
+	r.affected += affected
+	r.state = responseDone
+
+	if status&serverMoreResultsExist != 0 {
+		r.state = responseFirst
+		return
+	}
+
+	if r.useDB != "" {
+		s.db = r.useDB
+	}
+}
+
+// prepared remembers the statement of a prepared statement id
+func (s *session) prepared(r *request, b []byte) {
+	r.state = responseDone
+
+	if len(b) >= 5 {
+		id := binary.LittleEndian.Uint32(b[1:5])
+		s.statements[id] = r.query[len("PREPARE "):]
+		r.event.Attributes["Statement_id"] = strconv.FormatUint(uint64(id), 10)
+	}
+}
+
+// error completes a request with an ERR packet
+func (r *request) error(b []byte) {
+	r.state = responseDone
+
+	if len(b) < 3 {
+		return
+	}
+
+	r.event.Attributes["Error_no"] = strconv.Itoa(int(binary.LittleEndian.Uint16(b[1:3])))
+	msg := b[3:]
+
+	// protocol 4.1 prefixes the message with a '#' and the SQL state
+	if len(msg) >= 6 && msg[0] == '#' {
+		msg = msg[6:]
+	}
+
+	r.event.Attributes["Error_msg"] = string(msg)
+}
+
+// setEnd measures the request up to the end of a packet of its response. Reordered segments
+// can complete an earlier packet later, so the request ends with the last packet captured.
+func (r *request) setEnd(p packet) {
+	if elapsed := p.end.Sub(r.event.Time).Seconds(); elapsed > r.event.QueryTime {
+		r.event.QueryTime = elapsed
+	}
+}
+
+// okPacket reads the affected rows and status flags of an OK packet body
+func okPacket(b []byte) (uint64, uint16, bool) {
+	affected, size := lenencInt(b)
+
+	if size == 0 {
+		return 0, 0, false
+	}
+
+	b = b[size:]
+	_, size = lenencInt(b)
+
+	if size == 0 || len(b) < size+2 {
+		return affected, 0, false
+	}
+
+	return affected, binary.LittleEndian.Uint16(b[size : size+2]), true
+}
+
+// isEOF reports whether a packet is an EOF packet
+func isEOF(b []byte) bool {
+	return b[0] == 0xfe && len(b) < 9
+}
+
+// commandName names a command byte
+func commandName(cmd byte) string {
+	if name, ok := commandNames[cmd]; ok {
+		return name
+	}
+
+	return "Unknown " + strconv.Itoa(int(cmd))
+}
@@ -0,0 +1,205 @@
+package capture
+
+import (
+	"encoding/binary"
+	"gopherDigest/pkg/slowlog"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/reassembly"
+)
+
+// maxPayload is the largest payload of a MySQL packet, longer payloads continue in the next packet
+const maxPayload = 0xffffff
+
+// invalidSequence is the next sequence number of a direction before its first segment is accepted
+const invalidSequence = -1
+
+// sessionFactory creates a session for each TCP connection to the server
+type sessionFactory struct {
+	port   layers.TCPPort
+	events []slowlog.Event
+}
+
+// stream receives both directions of a connection from the TCP assembler
+type stream struct {
+	session   *session
+	serverDir reassembly.TCPFlowDirection
+	client    packetBuffer
+	server    packetBuffer
+}
+
+// captureContext passes the capture time of each packet to the assembler
+type captureContext gopacket.CaptureInfo
+
+// packet is a MySQL protocol packet with the time its first and last bytes were captured
+type packet struct {
+	seq        byte
+	payload    []byte
+	start, end time.Time
+}
+
+// packetBuffer splits a reassembled byte stream into MySQL packets
+type packetBuffer struct {
+	data  []byte
+	since time.Time
+
+	// a payload of maxPayload bytes or more spans several packets
+	partial      []byte
+	partialStart time.Time
+	continued    bool
+}
+
+func newSessionFactory(port layers.TCPPort) *sessionFactory {
+	return &sessionFactory{port: port, events: []slowlog.Event{}}
+}
+
+// New is called by the assembler for the first packet of a connection, in either direction
+func (f *sessionFactory) New(netFlow, tcpFlow gopacket.Flow, tcp *layers.TCP, ac reassembly.AssemblerContext) reassembly.Stream {
+	// the assembler names the direction of the first packet client to server
+	client, serverDir := netFlow.Src(), reassembly.TCPDirServerToClient
+
+	if tcp.SrcPort == f.port {
+		client, serverDir = netFlow.Dst(), reassembly.TCPDirClientToServer
+	}
+
+	return &stream{session: newSession(client.String(), f.emit), serverDir: serverDir}
+}
+
+// emit records the event of a finished request
+func (f *sessionFactory) emit(e slowlog.Event) {
+	f.events = append(f.events, e)
+}
+
+// Accept starts decoding connections that were established before the capture started
+func (s *stream) Accept(tcp *layers.TCP, ci gopacket.CaptureInfo, dir reassembly.TCPFlowDirection, nextSeq reassembly.Sequence,
+	start *bool, ac reassembly.AssemblerContext) bool {
+	if nextSeq == invalidSequence && !tcp.SYN {
+		*start = true
+		s.session.joinedLate()
+	}
+
+	return true
+}
+
+// ReassembledSG decodes the packets completed by newly reassembled data. Data is timed by the
+// capture of the segment that completed it, which is when it became readable.
+func (s *stream) ReassembledSG(sg reassembly.ScatterGather, ac reassembly.AssemblerContext) {
+	dir, _, _, skip := sg.Info()
+	length, _ := sg.Lengths()
+	fromServer := dir == s.serverDir
+	buf := &s.client
+
+	if fromServer {
+		buf = &s.server
+	}
+
+	// after a gap in the capture the packet boundaries are unknown, so the next segment
+	// is assumed to start a packet, as clients and servers usually write whole packets
+	if skip > 0 {
+		*buf = packetBuffer{}
+		s.session.lost()
+	}
+
+	if length == 0 {
+		return
+	}
+
+	for _, p := range buf.write(sg.Fetch(length), ac.GetCaptureInfo().Timestamp) {
+		if fromServer {
+			s.session.serverPacket(p)
+		} else {
+			s.session.clientPacket(p)
+		}
+	}
+}
+
+// ReassemblyComplete is called once both directions are closed or the capture ends
+func (s *stream) ReassemblyComplete(ac reassembly.AssemblerContext) bool {
+	s.session.finish()
+
+	return true
+}
+
+// GetCaptureInfo returns the capture time of the packet being assembled
+func (c *captureContext) GetCaptureInfo() gopacket.CaptureInfo {
+	return gopacket.CaptureInfo(*c)
+}
+
+// write appends captured bytes and returns the packets they complete
+func (b *packetBuffer) write(data []byte, seen time.Time) []packet {
+	if len(b.data) == 0 {
+		b.since = seen
+	}
+
+	b.data = append(b.data, data...)
+	packets := []packet{}
+
+	for len(b.data) >= 4 {
+		length := int(b.data[0]) | int(b.data[1])<<8 | int(b.data[2])<<16
+
+		if len(b.data) < 4+length {
+			break
+		}
+
+		payload := b.data[4 : 4+length]
+		seq := b.data[3]
+		start := b.since
+
+		b.data = b.data[4+length:]
+		b.since = seen
+
+		if b.continued {
+			start = b.partialStart
+			payload = append(b.partial, payload...)
+		}
+
+		b.continued = length == maxPayload
+
+		if b.continued {
+			b.partial = append([]byte{}, payload...)
+			b.partialStart = start
+			continue
+		}
+
+		packets = append(packets, packet{seq: seq, payload: append([]byte{}, payload...), start: start, end: seen})
+	}
+
+	// keep the unfinished packet in its own array so the reassembly buffer can be reused
+	b.data = append([]byte{}, b.data...)
+
+	return packets
+}
+
+// lenencInt reads a length-encoded integer, returning its value and size in bytes, or a size of 0
+// when it is invalid
+func lenencInt(b []byte) (uint64, int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+
+	switch {
+	case b[0] < 0xfb:
+		return uint64(b[0]), 1
+	case b[0] == 0xfc && len(b) >= 3:
+		return uint64(binary.LittleEndian.Uint16(b[1:3])), 3
+	case b[0] == 0xfd && len(b) >= 4:
+		return uint64(b[1]) | uint64(b[2])<<8 | uint64(b[3])<<16, 4
+	case b[0] == 0xfe && len(b) >= 9:
+		return binary.LittleEndian.Uint64(b[1:9]), 9
+	}
+
+	return 0, 0
+}
+
+// nullTerminated reads a string ending with a NUL byte, returning it and the rest of the data
+func nullTerminated(b []byte) (string, []byte) {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i]), b[i+1:]
+		}
+	}
+
+	return string(b), nil
+}
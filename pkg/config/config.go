@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/fatih/color"
 )
@@ -18,27 +20,53 @@ type Dependency struct {
 	name, exeName, path, source string
 }
 
-// Health defines the health of configuration's network connection
-type Health struct {
-	Conn   string
-	Port   string
-	Socket string
-	Errors []error
+// Privileges defines whether a database account holds the privileges each feature needs
+type Privileges struct {
+	Account  string
+	Features map[string]string
+	Errors   []error
 }
 
-// New creates a new runtime configuration
-func New() (*Config, error) {
+// DependencyStatus defines whether a runtime dependency was found
+type DependencyStatus struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Found bool   `json:"found"`
+	Error string `json:"error,omitempty"`
+}
+
+// New creates a new runtime configuration, writing the dependencies it locates to a writer
+func New(w io.Writer) (*Config, error) {
+	return Defaults().verifyDependencies(w)
+}
+
+// Defaults creates the runtime configuration without verifying its dependencies
+func Defaults() *Config {
 	cfg := &Config{}
 
 	cfg.addDependency("MySQL", "mysql", "/usr/bin/mysql", "")
 	cfg.addDependency("PT Query Digest", "pt-query-digest", "/usr/bin/pt-query-digest", "")
 
-	return cfg.verifyDependencies()
+	return cfg
 }
 
-// PrintStatus prints a configuration's health status to a writer
-func (h *Health) PrintStatus(w io.Writer) {
-	fmt.Fprintf(w, "  %+v\n\n", h)
+// PrintStatus prints the privilege status of each feature, in order, to a writer
+func (p *Privileges) PrintStatus(w io.Writer) {
+	names := []string{}
+
+	for name := range p.Features {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "  Account: %s\n", p.Account)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s:%s\n", name, p.Features[name])
+	}
+
+	fmt.Fprintln(w)
 }
 
 // addDependency creates a new runtime dependency to the configuration
@@ -49,8 +77,8 @@ func (c *Config) addDependency(args ...string) *Dependency {
 }
 
 // verifyDependencies verifies the necessary required runtime dependencies are present
-func (c *Config) verifyDependencies() (*Config, error) {
-	color.New(color.Bold).Println("\nLocating Dependencies")
+func (c *Config) verifyDependencies(w io.Writer) (*Config, error) {
+	color.New(color.Bold).Fprintln(w, "\nLocating Dependencies")
 
 	if len(c.Dependencies) == 0 {
 		return nil, fmt.Errorf("cannot verify dependencies on an empty Config")
@@ -60,25 +88,37 @@ func (c *Config) verifyDependencies() (*Config, error) {
 		if dep.name != "" && dep.path != "" {
 			_, err := os.Stat(dep.path)
 			if err != nil {
-				color.New(color.FgHiRed).Printf("  [x] Unable to locate %s executable at %s\n", dep.name, dep.path)
+				color.New(color.FgHiRed).Fprintf(w, "  [x] Unable to locate %s executable at %s\n", dep.name, dep.path)
 				return nil, fmt.Errorf("missing required dependency %s", err)
 			}
-			color.New(color.FgHiGreen).Printf("  [\u2713] Using %v executable from %s \n", dep.name, dep.path)
+			color.New(color.FgHiGreen).Fprintf(w, "  [\u2713] Using %v executable from %s \n", dep.name, dep.path)
 		}
 	}
 
-	fmt.Println()
+	fmt.Fprintln(w)
 
 	return c, nil
 }
 
-// GetSecrets retrieves secret values given a retrieval function
-func GetSecrets(retrieve func(string) string, prefix, delimeter string, args ...string) []string {
-	envs := []string{}
+// Locate looks up every runtime dependency without printing, returning an error naming the missing ones
+func (c *Config) Locate() ([]DependencyStatus, error) {
+	stats := []DependencyStatus{}
+	missing := []string{}
+
+	for _, dep := range c.Dependencies {
+		stat := DependencyStatus{Name: dep.name, Path: dep.path, Found: true}
+
+		if _, err := os.Stat(dep.path); err != nil {
+			stat.Found, stat.Error = false, err.Error()
+			missing = append(missing, dep.name)
+		}
+
+		stats = append(stats, stat)
+	}
 
-	for _, key := range args {
-		envs = append(envs, retrieve(prefix+delimeter+key))
+	if len(missing) > 0 {
+		return stats, fmt.Errorf("missing required dependencies %s", strings.Join(missing, ", "))
 	}
 
-	return envs
+	return stats, nil
 }
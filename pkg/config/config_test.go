@@ -2,10 +2,15 @@ package config
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"reflect"
-	"strings"
 	"testing"
+	"time"
 )
 
 func TestNew(t *testing.T) {
@@ -26,7 +31,7 @@ func TestNew(t *testing.T) {
 		},
 	}
 
-	actual, _ := New()
+	actual, _ := New(ioutil.Discard)
 
 	if !reflect.DeepEqual(actual, expected) {
 		t.Errorf("New should be %v, but got %v", expected, actual)
@@ -35,22 +40,93 @@ func TestNew(t *testing.T) {
 
 func TestPrintStatus(t *testing.T) {
 	h := Health{
-		Conn:   "Open",
-		Port:   "3306",
-		Socket: "var/lib/xyz",
-		Errors: nil,
+		Service:   "MySQL",
+		Endpoint:  "db:3306",
+		Address:   "172.18.0.2",
+		Port:      3306,
+		Socket:    "/var/run/mysqld/mysqld.sock",
+		Version:   "8.0.21",
+		Latency:   1500 * time.Microsecond,
+		TLS:       "disabled",
+		Connected: true,
+		Errors:    []error{NewCheckError(ErrConnection, 1, errors.New("connection refused"))},
 	}
 
-	expected := fmt.Sprintf("  %+v\n\n", h)
+	expected := `MySQL Connection Status
+  Connection: OPEN
+  Endpoint: db:3306
+  Address: 172.18.0.2
+  Port: 3306
+  Socket: /var/run/mysqld/mysqld.sock
+  Version: 8.0.21
+  Latency: 1.5ms
+  TLS: disabled
+  [connection] attempt #1: connection refused
 
-	var actualBuf bytes.Buffer
-	h.PrintStatus(&actualBuf)
+`
 
-	// remove "&"" from buffer address string
-	actual := strings.Replace(actualBuf.String(), "&", "", 1)
+	var actual bytes.Buffer
+	h.PrintPlain(&actual)
 
-	if actual != expected {
-		t.Errorf("PrintStatus of %v should be\n%s but got\n%s", h, expected, actual)
+	if actual.String() != expected {
+		t.Errorf("PrintPlain of %v should be\n%s but got\n%s", h, expected, actual.String())
+	}
+}
+
+func TestRender(t *testing.T) {
+	healths := []*Health{
+		{Service: "MySQL", Endpoint: "db:3306", Port: 3306, Latency: 2 * time.Millisecond, Connected: true},
+		{Service: "RethinkDB", Endpoint: "rdb:28015", Errors: []error{
+			NewCheckError(ErrConnection, 1, errors.New("connection refused")),
+			NewCheckError(ErrConnection, 0, context.DeadlineExceeded),
+			errors.New("unclassified"),
+		}},
+	}
+
+	var actual bytes.Buffer
+
+	if err := Render(&actual, FormatJSON, healths...); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded []map[string]interface{}
+
+	if err := json.Unmarshal(actual.Bytes(), &decoded); err != nil {
+		t.Fatalf("Render should write valid JSON, but got %s\n%s", err, actual.String())
+	}
+
+	if len(decoded) != 2 || decoded[0]["port"] != 3306.0 || decoded[0]["latency_ms"] != 2.0 || decoded[0]["connected"] != true {
+		t.Errorf("Render should encode every health with its port and latency in milliseconds, but got %v", decoded)
+	}
+
+	expected := []interface{}{
+		map[string]interface{}{"kind": "connection", "attempt": 1.0, "message": "connection refused"},
+		map[string]interface{}{"kind": "timeout", "message": "context deadline exceeded"},
+		map[string]interface{}{"kind": "connection", "message": "unclassified"},
+	}
+
+	if len(decoded) == 2 && !reflect.DeepEqual(decoded[1]["errors"], expected) {
+		t.Errorf("Render should encode typed errors %v, but got %v", expected, decoded[1]["errors"])
+	}
+
+	if err := Render(&actual, "xml", healths...); err == nil {
+		t.Errorf("Render should reject an unknown format")
+	}
+}
+
+func TestPrivilegesPrintStatus(t *testing.T) {
+	p := Privileges{
+		Account:  "`gopher`@`%`",
+		Features: map[string]string{"processlist": " OK", "performance_schema": " MISSING SELECT on performance_schema.*"},
+	}
+
+	expected := "  Account: `gopher`@`%`\n  performance_schema: MISSING SELECT on performance_schema.*\n  processlist: OK\n\n"
+
+	var actual bytes.Buffer
+	p.PrintStatus(&actual)
+
+	if actual.String() != expected {
+		t.Errorf("PrintStatus of %v should be\n%s but got\n%s", p, expected, actual.String())
 	}
 }
 
@@ -76,6 +152,22 @@ func TestAddDependency(t *testing.T) {
 
 }
 
+func TestLocate(t *testing.T) {
+	cfg := &Config{}
+	cfg.addDependency("Go", "go", os.Args[0], "")
+	cfg.addDependency("Foo", "foo", "/usr/bin/foo", "")
+
+	actual, err := cfg.Locate()
+
+	if err == nil || err.Error() != "missing required dependencies Foo" {
+		t.Errorf("Locate should name the missing dependencies, but got %v", err)
+	}
+
+	if len(actual) != 2 || !actual[0].Found || actual[1].Found || actual[1].Error == "" {
+		t.Errorf("Locate should report whether each dependency was found, but got %+v", actual)
+	}
+}
+
 func TestVerifyDependencies(t *testing.T) {
 	tt := []struct {
 		name        string
@@ -155,7 +247,7 @@ func TestVerifyDependencies(t *testing.T) {
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			actual, actualErr := tc.cfg.verifyDependencies()
+			actual, actualErr := tc.cfg.verifyDependencies(ioutil.Discard)
 
 			if !reflect.DeepEqual(actual, tc.expectedCfg) && (actualErr.Error() != tc.expectedErr.Error()) {
 				t.Errorf("verifyDependencies of %s should be %+v, but got %+v", tc.name, tc.expectedCfg, actual)
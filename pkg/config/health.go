@@ -0,0 +1,211 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Health output formats
+const (
+	FormatTerminal = "terminal"
+	FormatPlain    = "plain"
+	FormatJSON     = "json"
+)
+
+// Health defines the health of a network connection to a service
+type Health struct {
+	Service   string        // name of the service, such as MySQL
+	Endpoint  string        // host:port the service was configured with
+	Address   string        // IP address the host resolved to
+	Port      int           // port the server reports it listens on
+	Socket    string        // path of the server's Unix socket
+	Server    string        // name the server reports for itself
+	Version   string        // version of the server
+	Latency   time.Duration // round trip of the successful check
+	TLS       string        // TLS version or cipher of the connection, or "disabled"
+	Connected bool
+	Errors    []error
+}
+
+// ErrorKind classifies the failures of a health check
+type ErrorKind string
+
+// Kinds of health check failures
+const (
+	ErrConnection ErrorKind = "connection"
+	ErrTimeout    ErrorKind = "timeout"
+	ErrCancelled  ErrorKind = "cancelled"
+	ErrSocket     ErrorKind = "socket"
+	ErrMetadata   ErrorKind = "metadata"
+)
+
+// CheckError is a failure of a health check, and the attempt it happened on if the check was retried
+type CheckError struct {
+	Kind    ErrorKind
+	Attempt int
+	Err     error
+}
+
+// healthJSON is the JSON encoding of a Health, with latency in milliseconds
+type healthJSON struct {
+	Service   string        `json:"service"`
+	Endpoint  string        `json:"endpoint,omitempty"`
+	Address   string        `json:"address,omitempty"`
+	Port      int           `json:"port,omitempty"`
+	Socket    string        `json:"socket,omitempty"`
+	Server    string        `json:"server,omitempty"`
+	Version   string        `json:"version,omitempty"`
+	Latency   float64       `json:"latency_ms"`
+	TLS       string        `json:"tls,omitempty"`
+	Connected bool          `json:"connected"`
+	Errors    []*CheckError `json:"errors"`
+}
+
+// Error describes the failure, including its attempt
+func (e *CheckError) Error() string {
+	if e.Attempt > 0 {
+		return fmt.Sprintf("attempt #%d: %s", e.Attempt, e.Err)
+	}
+
+	return e.Err.Error()
+}
+
+// MarshalJSON encodes the kind, attempt and message of the failure
+func (e *CheckError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Kind    ErrorKind `json:"kind"`
+		Attempt int       `json:"attempt,omitempty"`
+		Message string    `json:"message"`
+	}{e.Kind, e.Attempt, e.Err.Error()})
+}
+
+// NewCheckError classifies an error of a check attempt, where a context error means the check
+// ran out of time or was cancelled rather than failed to connect
+func NewCheckError(kind ErrorKind, attempt int, err error) *CheckError {
+	switch err {
+	case context.DeadlineExceeded:
+		kind = ErrTimeout
+	case context.Canceled:
+		kind = ErrCancelled
+	}
+
+	return &CheckError{Kind: kind, Attempt: attempt, Err: err}
+}
+
+// checkErrors converts the errors of a health to CheckErrors, for errors that were not classified
+func (h *Health) checkErrors() []*CheckError {
+	errs := []*CheckError{}
+
+	for _, err := range h.Errors {
+		if ce, ok := err.(*CheckError); ok {
+			errs = append(errs, ce)
+			continue
+		}
+
+		errs = append(errs, &CheckError{Kind: ErrConnection, Err: err})
+	}
+
+	return errs
+}
+
+// MarshalJSON encodes the health with its latency in milliseconds and its errors as objects
+func (h *Health) MarshalJSON() ([]byte, error) {
+	return json.Marshal(healthJSON{
+		Service: h.Service, Endpoint: h.Endpoint, Address: h.Address, Port: h.Port, Socket: h.Socket,
+		Server: h.Server, Version: h.Version, Latency: float64(h.Latency) / float64(time.Millisecond),
+		TLS: h.TLS, Connected: h.Connected, Errors: h.checkErrors(),
+	})
+}
+
+// PrintStatus prints a health status to a terminal, in color
+func (h *Health) PrintStatus(w io.Writer) {
+	h.print(w, color.New(color.Bold), color.New(color.FgGreen, color.Bold), color.New(color.FgRed, color.Bold))
+}
+
+// PrintPlain prints a health status as plain text
+func (h *Health) PrintPlain(w io.Writer) {
+	plain := color.New()
+	plain.DisableColor()
+
+	h.print(w, plain, plain, plain)
+}
+
+// print prints the fields of a health status that are known, one per line
+func (h *Health) print(w io.Writer, bold, green, red *color.Color) {
+	fmt.Fprintln(w, bold.Sprintf("%s Connection Status", h.Service))
+
+	if h.Connected {
+		fmt.Fprintf(w, "  Connection: %s\n", green.Sprint("OPEN"))
+	} else {
+		fmt.Fprintf(w, "  Connection: %s\n", red.Sprint("CLOSED"))
+	}
+
+	fields := [][2]string{
+		{"Endpoint", h.Endpoint}, {"Address", h.Address}, {"Port", ""}, {"Socket", h.Socket},
+		{"Server", h.Server}, {"Version", h.Version}, {"Latency", ""}, {"TLS", h.TLS},
+	}
+
+	if h.Port > 0 {
+		fields[2][1] = fmt.Sprint(h.Port)
+	}
+
+	if h.Latency > 0 {
+		fields[6][1] = h.Latency.Round(time.Microsecond).String()
+	}
+
+	for _, f := range fields {
+		if f[1] != "" {
+			fmt.Fprintf(w, "  %s: %s\n", f[0], f[1])
+		}
+	}
+
+	for _, err := range h.checkErrors() {
+		fmt.Fprintf(w, "  %s\n", red.Sprintf("[%s] %s", err.Kind, err))
+	}
+
+	fmt.Fprintln(w)
+}
+
+// CheckFormat verifies a health output format is known
+func CheckFormat(format string) error {
+	switch format {
+	case FormatTerminal, FormatPlain, FormatJSON:
+		return nil
+	}
+
+	return fmt.Errorf("unknown health format %q, expected %s, %s or %s", format, FormatTerminal, FormatPlain, FormatJSON)
+}
+
+// Render writes health statuses to a writer in a format, where JSON is an array of statuses
+func Render(w io.Writer, format string, healths ...*Health) error {
+	if err := CheckFormat(format); err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatTerminal:
+		for _, h := range healths {
+			h.PrintStatus(w)
+		}
+	case FormatPlain:
+		for _, h := range healths {
+			h.PrintPlain(w)
+		}
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		if healths == nil {
+			healths = []*Health{}
+		}
+
+		return enc.Encode(healths)
+	}
+
+	return nil
+}
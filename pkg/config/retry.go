@@ -0,0 +1,121 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// Backoff defines how an unreachable service is retried. Delays grow from Initial by Multiplier up
+// to Max, and each is randomized by up to Jitter of itself so clients restarted together do not
+// retry together. Retrying stops after MaxAttempts attempts or MaxElapsed, when either is set.
+// Each retried attempt is reported to Output, or to standard error when it is nil.
+type Backoff struct {
+	Initial     time.Duration
+	Max         time.Duration
+	Multiplier  float64
+	Jitter      float64
+	MaxElapsed  time.Duration
+	MaxAttempts int
+	Output      io.Writer
+}
+
+// DefaultBackoff waits up to two minutes for a service, such as a database container still starting
+var DefaultBackoff = Backoff{
+	Initial:     time.Second,
+	Max:         15 * time.Second,
+	Multiplier:  2,
+	Jitter:      0.2,
+	MaxElapsed:  2 * time.Minute,
+	MaxAttempts: 10,
+}
+
+// Check is one attempt to reach a service, reporting its health
+type Check func(ctx context.Context) (*Health, error)
+
+// Retry runs a check until it succeeds, the attempts or time of the backoff run out, or the context
+// is cancelled. The health of the last attempt is returned with the errors of every failed attempt,
+// and is marked connected, with the latency of the check unless it measured its own, on success.
+func (b Backoff) Retry(ctx context.Context, name string, check Check) (*Health, error) {
+	if b.MaxElapsed > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.MaxElapsed)
+		defer cancel()
+	}
+
+	start := time.Now()
+	errs := []error{}
+
+	for attempt := 1; ; attempt++ {
+		began := time.Now()
+		stat, err := check(ctx)
+
+		if stat == nil {
+			stat = &Health{}
+		}
+
+		if stat.Service == "" {
+			stat.Service = name
+		}
+
+		if err == nil {
+			if stat.Latency == 0 {
+				stat.Latency = time.Since(began)
+			}
+
+			stat.Connected = true
+			stat.Errors = append(errs, stat.Errors...)
+			return stat, nil
+		}
+
+		errs = append(errs, NewCheckError(ErrConnection, attempt, err))
+		stat.Errors = errs
+
+		if b.MaxAttempts > 0 && attempt >= b.MaxAttempts {
+			return stat, fmt.Errorf("could not connect to %s after %d attempt(s)\n%s", name, attempt, err)
+		}
+
+		wait := b.delay(attempt, rand.Float64())
+
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return stat, fmt.Errorf("could not connect to %s within %s\n%s", name, time.Since(start).Round(time.Millisecond), err)
+		}
+
+		out := b.Output
+
+		if out == nil {
+			out = os.Stderr
+		}
+
+		fmt.Fprintf(out, "could not connect to %s on attempt #%d, retrying in %s\n%s\n\n", name, attempt, wait.Round(time.Millisecond), err)
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			stat.Errors = append(errs, NewCheckError(ErrCancelled, 0, ctx.Err()))
+			return stat, fmt.Errorf("stopped connecting to %s after %d attempt(s)\n%s", name, attempt, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// delay is the wait after a failed attempt, where r is a random number in [0, 1) that spreads the
+// wait evenly within the jitter
+func (b Backoff) delay(attempt int, r float64) time.Duration {
+	multiplier := math.Max(b.Multiplier, 1)
+	d := float64(b.Initial) * math.Pow(multiplier, float64(attempt-1))
+
+	if b.Max > 0 && d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+
+	d *= 1 + b.Jitter*(2*r-1)
+
+	return time.Duration(d)
+}
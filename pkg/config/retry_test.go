@@ -0,0 +1,102 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 15 * time.Second, Multiplier: 2, Jitter: 0.2}
+
+	tt := []struct {
+		attempt  int
+		r        float64
+		expected time.Duration
+	}{
+		{1, 0.5, time.Second},
+		{2, 0.5, 2 * time.Second},
+		{4, 0.5, 8 * time.Second},
+		{5, 0.5, 15 * time.Second},
+		{20, 0.5, 15 * time.Second},
+		{1, 0, 800 * time.Millisecond},
+		{3, 1, 4800 * time.Millisecond},
+	}
+
+	for _, tc := range tt {
+		if actual := b.delay(tc.attempt, tc.r); actual != tc.expected {
+			t.Errorf("delay(%d, %v) should be %s, but got %s", tc.attempt, tc.r, tc.expected, actual)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var out bytes.Buffer
+	b := Backoff{Initial: time.Millisecond, Multiplier: 2, MaxAttempts: 5, Output: &out}
+	attempts := 0
+
+	stat, err := b.Retry(context.Background(), "test", func(ctx context.Context) (*Health, error) {
+		attempts++
+
+		if attempts < 3 {
+			return &Health{Endpoint: "db:3306"}, errors.New("connection refused")
+		}
+
+		return &Health{Endpoint: "db:3306", Version: "8.0.21"}, nil
+	})
+
+	if err != nil {
+		t.Fatalf("Retry should succeed on the third attempt, but got %s", err)
+	}
+
+	if attempts != 3 || stat.Version != "8.0.21" || !stat.Connected || stat.Service != "test" {
+		t.Errorf("Retry should return the health of the successful attempt after 3 attempts, but got %+v after %d", stat, attempts)
+	}
+
+	if len(stat.Errors) != 2 || stat.Errors[1].Error() != "attempt #2: connection refused" {
+		t.Errorf("Retry should report the error of every failed attempt, but got %v", stat.Errors)
+	}
+
+	if n := strings.Count(out.String(), "retrying in"); n != 2 {
+		t.Errorf("Retry should write each retried attempt to its output, but got %q", out.String())
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	refused := func(ctx context.Context) (*Health, error) {
+		return nil, errors.New("connection refused")
+	}
+
+	stat, err := Backoff{Initial: time.Millisecond, MaxAttempts: 3}.Retry(context.Background(), "test", refused)
+
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempt(s)") {
+		t.Errorf("Retry should stop after the maximum attempts, but got %v", err)
+	}
+
+	if stat == nil || len(stat.Errors) != 3 {
+		t.Errorf("Retry should return a health with every attempt's error, but got %+v", stat)
+	}
+
+	start := time.Now()
+	_, err = Backoff{Initial: 20 * time.Millisecond, MaxElapsed: 50 * time.Millisecond}.Retry(context.Background(), "test", refused)
+
+	if err == nil || time.Since(start) > time.Second {
+		t.Errorf("Retry should stop after the maximum elapsed time, but got %v after %s", err, time.Since(start))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+
+	_, err = Backoff{Initial: time.Hour}.Retry(ctx, "test", func(ctx context.Context) (*Health, error) {
+		attempts++
+		cancel()
+		return nil, errors.New("connection refused")
+	})
+
+	if err == nil || attempts != 1 || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("Retry should stop waiting when the context is cancelled, but got %v after %d attempt(s)", err, attempts)
+	}
+}
@@ -0,0 +1,360 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Provider looks up configuration values and secrets by key, such as MYSQL_PASSWORD
+type Provider interface {
+	Lookup(key string) (value string, ok bool, err error)
+}
+
+// ProviderFunc adapts a function to a Provider
+type ProviderFunc func(key string) (string, bool, error)
+
+// Lookup calls the function
+func (f ProviderFunc) Lookup(key string) (string, bool, error) {
+	return f(key)
+}
+
+// Chain looks a key up in each of its providers in turn, falling back to the next provider until
+// one has the key. A provider that fails stops the lookup, rather than hiding a broken secret.
+type Chain []Provider
+
+// Lookup returns the value of the first provider that has the key
+func (c Chain) Lookup(key string) (string, bool, error) {
+	for _, p := range c {
+		v, ok, err := p.Lookup(key)
+
+		if err != nil || ok {
+			return v, ok, err
+		}
+	}
+
+	return "", false, nil
+}
+
+// Values provides the values of a map
+func Values(values map[string]string) Provider {
+	return ProviderFunc(func(key string) (string, bool, error) {
+		v, ok := values[key]
+		return v, ok, nil
+	})
+}
+
+// Environment provides environment variables that are set and not empty, where lookup is
+// os.LookupEnv
+func Environment(lookup func(string) (string, bool)) Provider {
+	return ProviderFunc(func(key string) (string, bool, error) {
+		v, ok := lookup(key)
+		return v, ok && v != "", nil
+	})
+}
+
+// FileReferences provides the contents of the file named by the <key>_FILE environment variable,
+// the convention of the official Docker images, where lookup is os.LookupEnv
+func FileReferences(lookup func(string) (string, bool)) Provider {
+	return ProviderFunc(func(key string) (string, bool, error) {
+		path, ok := lookup(key + "_FILE")
+
+		if !ok || path == "" {
+			return "", false, nil
+		}
+
+		v, err := readSecret(path)
+
+		if err != nil {
+			return "", false, fmt.Errorf("could not read %s from %s_FILE\n%s", key, key, err)
+		}
+
+		return v, true, nil
+	})
+}
+
+// SecretsDir provides the contents of the files in a directory named by key, as Docker mounts
+// secrets in /run/secrets. A file named by the key in lower case is used when there is none
+// named by the key itself.
+func SecretsDir(dir string) Provider {
+	return ProviderFunc(func(key string) (string, bool, error) {
+		for _, name := range []string{key, strings.ToLower(key)} {
+			v, err := readSecret(filepath.Join(dir, name))
+
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			if err != nil {
+				return "", false, fmt.Errorf("could not read the secret %s\n%s", key, err)
+			}
+
+			return v, true, nil
+		}
+
+		return "", false, nil
+	})
+}
+
+// readSecret reads a secret from a file, without the line break editors add at its end
+func readSecret(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// DotEnv provides the variables of a .env file
+func DotEnv(path string) (Provider, error) {
+	b, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := parseDotEnv(bytes.NewReader(b), path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Values(values), nil
+}
+
+var dotEnvLine = regexp.MustCompile(`^(?:export\s+)?([A-Za-z_][A-Za-z0-9_]*)\s*=\s*(.*)$`)
+
+// parseDotEnv parses KEY=VALUE lines, ignoring blank lines and comments. Values may be single
+// quoted to be read literally, or double quoted to expand \n, \t, \" and \\.
+func parseDotEnv(r io.Reader, name string) (map[string]string, error) {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(r)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		m := dotEnvLine.FindStringSubmatch(line)
+
+		if m == nil {
+			return nil, fmt.Errorf("could not parse %s line %d, expected KEY=VALUE", name, n)
+		}
+
+		v, err := dotEnvValue(m[2])
+
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s line %d, %s", name, n, err)
+		}
+
+		values[m[1]] = v
+	}
+
+	return values, scanner.Err()
+}
+
+// dotEnvValue unquotes the value of a .env line, removing the comment after an unquoted value
+func dotEnvValue(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+
+	switch v[0] {
+	case '\'':
+		end := strings.IndexByte(v[1:], '\'')
+
+		if end < 0 {
+			return "", fmt.Errorf("unterminated single quoted value")
+		}
+
+		return v[1 : end+1], nil
+	case '"':
+		value := strings.Builder{}
+
+		for i := 1; i < len(v); i++ {
+			switch c := v[i]; {
+			case c == '"':
+				return value.String(), nil
+			case c == '\\' && i+1 < len(v):
+				i++
+
+				switch v[i] {
+				case 'n':
+					value.WriteByte('\n')
+				case 't':
+					value.WriteByte('\t')
+				default:
+					value.WriteByte(v[i])
+				}
+			default:
+				value.WriteByte(c)
+			}
+		}
+
+		return "", fmt.Errorf("unterminated double quoted value")
+	}
+
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = v[:i]
+	}
+
+	return strings.TrimSpace(v), nil
+}
+
+// encryptedHeader identifies the format of an encrypted secrets file
+const encryptedHeader = "gopherDigest-secrets-v1\n"
+
+// SecretsKeySize is the size of the AES-256 key of an encrypted secrets file
+const SecretsKeySize = 32
+
+// NewSecretsKey generates a random key for an encrypted secrets file
+func NewSecretsKey() ([]byte, error) {
+	key := make([]byte, SecretsKeySize)
+
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("could not generate a secrets key\n%s", err)
+	}
+
+	return key, nil
+}
+
+// ParseSecretsKey decodes a base64 encoded key of an encrypted secrets file
+func ParseSecretsKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+
+	if err != nil || len(key) != SecretsKeySize {
+		return nil, fmt.Errorf("the secrets key must be %d base64 encoded bytes", SecretsKeySize)
+	}
+
+	return key, nil
+}
+
+// EncryptSecrets encrypts the contents of a .env file with AES-256-GCM
+func EncryptSecrets(plaintext, key []byte) ([]byte, error) {
+	aead, err := secretsCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := parseDotEnv(bytes.NewReader(plaintext), "the secrets"); err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("could not generate a nonce\n%s", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(encryptedHeader))
+
+	return []byte(encryptedHeader + base64.StdEncoding.EncodeToString(sealed) + "\n"), nil
+}
+
+// EncryptedFile provides the variables of a .env file encrypted by EncryptSecrets
+func EncryptedFile(path string, key []byte) (Provider, error) {
+	b, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(b, []byte(encryptedHeader)) {
+		return nil, fmt.Errorf("%s is not an encrypted secrets file", path)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b[len(encryptedHeader):])))
+
+	if err != nil {
+		return nil, fmt.Errorf("could not decode the encrypted secrets file %s\n%s", path, err)
+	}
+
+	aead, err := secretsCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("the encrypted secrets file %s is truncated", path)
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(encryptedHeader))
+
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt %s, the key is wrong or the file was modified", path)
+	}
+
+	values, err := parseDotEnv(bytes.NewReader(plaintext), path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Values(values), nil
+}
+
+// secretsCipher creates the AES-256-GCM cipher of a secrets key
+func secretsCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) != SecretsKeySize {
+		return nil, fmt.Errorf("the secrets key must be %d bytes", SecretsKeySize)
+	}
+
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// GetSecrets retrieves secret values from a provider, given the prefix of their keys, returning an
+// error that lists every key that was not found
+func GetSecrets(p Provider, prefix, delimeter string, args ...string) ([]string, error) {
+	values := []string{}
+	missing := []string{}
+
+	for _, key := range args {
+		v, ok, err := p.Lookup(prefix + delimeter + key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			missing = append(missing, prefix+delimeter+key)
+		}
+
+		values = append(values, v)
+	}
+
+	if len(missing) > 0 {
+		return nil, missingSecrets(missing)
+	}
+
+	return values, nil
+}
+
+// missingSecrets reports the keys of required secrets that no provider has
+func missingSecrets(keys []string) error {
+	sort.Strings(keys)
+
+	return fmt.Errorf("missing required secrets: %s", strings.Join(keys, ", "))
+}
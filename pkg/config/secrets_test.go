@@ -0,0 +1,218 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "secrets")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// lookupEnv looks environment variables up in a map, as os.LookupEnv
+func lookupEnv(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func lookup(t *testing.T, p Provider, key string) (string, bool) {
+	v, ok, err := p.Lookup(key)
+
+	if err != nil {
+		t.Fatalf("looking %s up should not return an error, but got %s", key, err)
+	}
+
+	return v, ok
+}
+
+func TestFileProviders(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	writeFile(t, filepath.Join(dir, "mysql_password"), "docker\n")
+	writeFile(t, filepath.Join(dir, "RDB_PASSWORD"), "rethink\r\n")
+	writeFile(t, filepath.Join(dir, "password.txt"), "referenced\n")
+
+	secrets := SecretsDir(dir)
+
+	if v, ok := lookup(t, secrets, "MYSQL_PASSWORD"); !ok || v != "docker" {
+		t.Errorf("SecretsDir should read MYSQL_PASSWORD from mysql_password, but got %q, %v", v, ok)
+	}
+
+	if v, ok := lookup(t, secrets, "RDB_PASSWORD"); !ok || v != "rethink" {
+		t.Errorf("SecretsDir should read RDB_PASSWORD without its line break, but got %q, %v", v, ok)
+	}
+
+	if _, ok := lookup(t, secrets, "MYSQL_USER"); ok {
+		t.Errorf("SecretsDir should not find MYSQL_USER")
+	}
+
+	refs := FileReferences(lookupEnv(map[string]string{
+		"MYSQL_PASSWORD_FILE": filepath.Join(dir, "password.txt"), "RDB_PASSWORD_FILE": filepath.Join(dir, "missing"),
+	}))
+
+	if v, ok := lookup(t, refs, "MYSQL_PASSWORD"); !ok || v != "referenced" {
+		t.Errorf("FileReferences should read MYSQL_PASSWORD from MYSQL_PASSWORD_FILE, but got %q, %v", v, ok)
+	}
+
+	if _, ok := lookup(t, refs, "MYSQL_USER"); ok {
+		t.Errorf("FileReferences should not find MYSQL_USER without MYSQL_USER_FILE")
+	}
+
+	if _, _, err := refs.Lookup("RDB_PASSWORD"); err == nil || !strings.Contains(err.Error(), "RDB_PASSWORD_FILE") {
+		t.Errorf("FileReferences should report the unreadable RDB_PASSWORD_FILE, but got %v", err)
+	}
+}
+
+func TestDotEnv(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, ".env")
+	writeFile(t, path, `# gopherDigest
+MYSQL_USER=gopher
+export MYSQL_HOST = db.internal # the primary
+MYSQL_PASSWORD='p#ss "word"'
+RDB_PASSWORD="line\nbreak \"quoted\""
+EMPTY=
+`)
+
+	p, err := DotEnv(path)
+
+	if err != nil {
+		t.Fatalf("DotEnv should not return an error, but got %s", err)
+	}
+
+	for key, expected := range map[string]string{
+		"MYSQL_USER": "gopher", "MYSQL_HOST": "db.internal", "MYSQL_PASSWORD": `p#ss "word"`, "RDB_PASSWORD": "line\nbreak \"quoted\"", "EMPTY": "",
+	} {
+		if v, ok := lookup(t, p, key); !ok || v != expected {
+			t.Errorf("DotEnv should read %s as %q, but got %q, %v", key, expected, v, ok)
+		}
+	}
+
+	writeFile(t, path, "MYSQL_USER=gopher\nMYSQL_PASSWORD\n")
+
+	if _, err := DotEnv(path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("DotEnv should report the invalid line 2, but got %v", err)
+	}
+
+	writeFile(t, path, "MYSQL_PASSWORD=\"unterminated\n")
+
+	if _, err := DotEnv(path); err == nil || !strings.Contains(err.Error(), "unterminated") {
+		t.Errorf("DotEnv should report the unterminated value, but got %v", err)
+	}
+}
+
+func TestEncryptedFile(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	key, err := NewSecretsKey()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseSecretsKey(base64.StdEncoding.EncodeToString(key) + "\n")
+
+	if err != nil || !reflect.DeepEqual(parsed, key) {
+		t.Fatalf("ParseSecretsKey should decode the key, but got %v, %v", parsed, err)
+	}
+
+	encrypted, err := EncryptSecrets([]byte("MYSQL_PASSWORD=secret\n"), key)
+
+	if err != nil {
+		t.Fatalf("EncryptSecrets should not return an error, but got %s", err)
+	}
+
+	if strings.Contains(string(encrypted), "secret\n") {
+		t.Errorf("EncryptSecrets should not write the secrets in plain text")
+	}
+
+	path := filepath.Join(dir, "secrets.enc")
+	writeFile(t, path, string(encrypted))
+
+	p, err := EncryptedFile(path, key)
+
+	if err != nil {
+		t.Fatalf("EncryptedFile should not return an error, but got %s", err)
+	}
+
+	if v, ok := lookup(t, p, "MYSQL_PASSWORD"); !ok || v != "secret" {
+		t.Errorf("EncryptedFile should decrypt MYSQL_PASSWORD, but got %q, %v", v, ok)
+	}
+
+	other, _ := NewSecretsKey()
+
+	if _, err := EncryptedFile(path, other); err == nil || !strings.Contains(err.Error(), "key is wrong") {
+		t.Errorf("EncryptedFile should report the wrong key, but got %v", err)
+	}
+
+	if _, err := ParseSecretsKey("c2hvcnQ="); err == nil {
+		t.Errorf("ParseSecretsKey should reject a key that is too short")
+	}
+
+	if _, err := EncryptSecrets([]byte("not a .env file"), key); err == nil {
+		t.Errorf("EncryptSecrets should reject secrets it could not read back")
+	}
+}
+
+func TestChain(t *testing.T) {
+	chain := Chain{
+		Environment(lookupEnv(map[string]string{"MYSQL_USER": "env", "MYSQL_HOST": ""})),
+		Values(map[string]string{"MYSQL_USER": "dotenv", "MYSQL_HOST": "db", "MYSQL_PASSWORD": "secret"}),
+	}
+
+	for key, expected := range map[string]string{"MYSQL_USER": "env", "MYSQL_HOST": "db", "MYSQL_PASSWORD": "secret"} {
+		if v, ok := lookup(t, chain, key); !ok || v != expected {
+			t.Errorf("the chain should look %s up as %q, but got %q, %v", key, expected, v, ok)
+		}
+	}
+
+	failing := Chain{
+		ProviderFunc(func(key string) (string, bool, error) { return "", false, errors.New("permission denied") }),
+		Values(map[string]string{"MYSQL_PASSWORD": "fallback"}),
+	}
+
+	if _, _, err := failing.Lookup("MYSQL_PASSWORD"); err == nil {
+		t.Errorf("the chain should stop at a provider that fails rather than fall back")
+	}
+}
+
+func TestGetSecrets(t *testing.T) {
+	p := Values(map[string]string{"RDB_USERNAME": "gopher", "RDB_PASSWORD": "secret"})
+
+	values, err := GetSecrets(p, "RDB", "_", "USERNAME", "PASSWORD")
+
+	if err != nil || !reflect.DeepEqual(values, []string{"gopher", "secret"}) {
+		t.Errorf("GetSecrets should return the values in order, but got %v, %v", values, err)
+	}
+
+	_, err = GetSecrets(p, "RDB", "_", "USERNAME", "PASSWORD", "DATABASE", "ADDRESS")
+	expected := "missing required secrets: RDB_ADDRESS, RDB_DATABASE"
+
+	if err == nil || err.Error() != expected {
+		t.Errorf("GetSecrets should report %q, but got %v", expected, err)
+	}
+}
@@ -0,0 +1,692 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Settings is the configuration of gopherDigest. It is read from an optional YAML file, then
+// overridden by environment variables, then by command line flags.
+type Settings struct {
+	MySQL     MySQLSettings
+	RethinkDB RethinkDBSettings
+	Workload  WorkloadSettings
+	Collector CollectorSettings
+	Health    HealthSettings
+	Store     StoreSettings
+}
+
+// MySQLSettings are the MySQL servers gopherDigest may run against, and the one it does
+type MySQLSettings struct {
+	Target  string
+	Targets map[string]*MySQLTarget
+}
+
+// MySQLTarget defines a MySQL server and the account used to connect to it
+type MySQLTarget struct {
+	Host           string
+	Port           int
+	User           string
+	Password       string
+	Socket         string
+	MaxConnections int
+	ReadOnly       bool
+}
+
+// RethinkDBSettings define the RethinkDB server results are stored in
+type RethinkDBSettings struct {
+	Address  string
+	Database string
+	Username string
+	Password string
+}
+
+// WorkloadSettings define the statements run when none are given on the command line
+type WorkloadSettings struct {
+	Database string
+	Queries  []string
+	Files    []string
+}
+
+// CollectorSettings define how often performance_schema digests are collected
+type CollectorSettings struct {
+	Interval time.Duration
+	Count    int
+}
+
+// HealthSettings define where health checks are served, if anywhere
+type HealthSettings struct {
+	Address string
+}
+
+// StoreSettings select where results are stored, parsed from a URL such as sqlite:///path.db
+type StoreSettings struct {
+	Driver string
+	Path   string
+	// MaxSize and RotateDaily rotate a JSON Lines file once it holds MaxSize bytes, or once a
+	// day when RotateDaily is set
+	MaxSize     int64
+	RotateDaily bool
+}
+
+// The result stores gopherDigest can use
+const (
+	// StoreRethinkDB stores results in the configured RethinkDB database
+	StoreRethinkDB = "rethinkdb"
+	// StoreSQLite stores results in a SQLite database file
+	StoreSQLite = "sqlite"
+	// StoreJSONL appends results to a JSON Lines file
+	StoreJSONL = "jsonl"
+	// StoreMemory keeps results in memory until gopherDigest exits
+	StoreMemory = "memory"
+)
+
+// ParseStore parses the URL of a result store, either rethinkdb, memory, sqlite:///path.db or
+// jsonl:///path.jsonl, where sqlite://path.db and jsonl://path.jsonl are relative to the working
+// directory. A JSON Lines file is rotated by size with ?max_size=100MB, and daily with
+// ?rotate=daily.
+func ParseStore(location string) (StoreSettings, error) {
+	if location == StoreRethinkDB || location == StoreRethinkDB+"://" {
+		return StoreSettings{Driver: StoreRethinkDB}, nil
+	}
+
+	if location == StoreMemory || location == StoreMemory+"://" {
+		return StoreSettings{Driver: StoreMemory}, nil
+	}
+
+	for _, driver := range []string{StoreSQLite, StoreJSONL} {
+		if !strings.HasPrefix(location, driver+"://") {
+			continue
+		}
+
+		store := StoreSettings{Driver: driver, Path: strings.TrimPrefix(location, driver+"://")}
+
+		if driver == StoreJSONL {
+			if i := strings.IndexByte(store.Path, '?'); i >= 0 {
+				if err := store.parseRotation(store.Path[i+1:]); err != nil {
+					return StoreSettings{}, err
+				}
+
+				store.Path = store.Path[:i]
+			}
+		}
+
+		if store.Path == "" || store.Path == "/" {
+			return StoreSettings{}, fmt.Errorf("expected the path of a file after %s://, got %q", driver, location)
+		}
+
+		return store, nil
+	}
+
+	return StoreSettings{}, fmt.Errorf("expected rethinkdb, memory, sqlite:///path.db or jsonl:///path.jsonl, got %q", location)
+}
+
+// parseRotation parses the max_size and rotate options of a JSON Lines file
+func (s *StoreSettings) parseRotation(query string) error {
+	options, err := url.ParseQuery(query)
+
+	if err != nil {
+		return fmt.Errorf("could not parse the options %q\n%s", query, err)
+	}
+
+	for name, values := range options {
+		v := values[len(values)-1]
+
+		switch name {
+		case "max_size":
+			if s.MaxSize, err = parseSize(v); err != nil {
+				return err
+			}
+		case "rotate":
+			if v != "daily" {
+				return fmt.Errorf("expected rotate=daily, got rotate=%s", v)
+			}
+
+			s.RotateDaily = true
+		default:
+			return fmt.Errorf("unknown option %s, expected max_size or rotate", name)
+		}
+	}
+
+	return nil
+}
+
+// parseSize parses a positive number of bytes, optionally followed by KB, MB or GB
+func parseSize(v string) (int64, error) {
+	n, unit := strings.ToUpper(v), int64(1)
+
+	for suffix, size := range map[string]int64{"KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30} {
+		if strings.HasSuffix(n, suffix) {
+			n, unit = strings.TrimSuffix(n, suffix), size
+		}
+	}
+
+	size, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("expected a size such as 500KB or 100MB, got max_size=%s", v)
+	}
+
+	return size * unit, nil
+}
+
+// DefaultTarget names the MySQL target configured only by the environment
+const DefaultTarget = "default"
+
+// DefaultSettings are the settings used when neither a file nor the environment set a value
+func DefaultSettings() *Settings {
+	return &Settings{
+		MySQL: MySQLSettings{
+			Target:  DefaultTarget,
+			Targets: map[string]*MySQLTarget{DefaultTarget: {Port: 3306, MaxConnections: 151}},
+		},
+		RethinkDB: RethinkDBSettings{Address: "localhost:28015", Database: "GopherDigest"},
+		Workload:  WorkloadSettings{Database: "employees"},
+		Collector: CollectorSettings{Interval: time.Minute},
+		Store:     StoreSettings{Driver: StoreRethinkDB},
+	}
+}
+
+// Load reads the settings from a YAML file, when a path is given, and the variables of a provider,
+// selecting the MySQL target named by target if it is not empty. A string in the file written as
+// ${KEY} is a required secret, read from the provider. Every invalid value is reported by the file
+// key or variable it was read from.
+func Load(path string, secrets Provider, target string) (*Settings, error) {
+	s := DefaultSettings()
+	d := &decoder{secrets: secrets}
+
+	if path != "" {
+		b, err := ioutil.ReadFile(path)
+
+		if err != nil {
+			return nil, fmt.Errorf("could not read the configuration file %s\n%s", path, err)
+		}
+
+		var tree interface{}
+
+		if err := yaml.Unmarshal(b, &tree); err != nil {
+			return nil, fmt.Errorf("could not parse the configuration file %s\n%s", path, err)
+		}
+
+		d.settings(tree, s)
+	}
+
+	d.environment(s)
+
+	if target != "" {
+		d.selectTarget(s, target, "-target")
+	}
+
+	if t := s.Target(); t != nil {
+		d.targetEnvironment(t)
+	} else if s.MySQL.Target == "" {
+		d.fail("mysql.target", "required to choose between the MySQL targets")
+	}
+
+	d.requireTarget(s.MySQL.Target)
+
+	if len(d.missing) > 0 && len(d.errs) == 0 {
+		return nil, missingSecrets(d.missing)
+	}
+
+	d.errs = append(d.errs, d.unresolved...)
+
+	if len(d.errs) > 0 {
+		sort.Strings(d.errs)
+		return nil, fmt.Errorf("invalid configuration\n  %s", strings.Join(d.errs, "\n  "))
+	}
+
+	return s, nil
+}
+
+// Target returns the selected MySQL target
+func (s *Settings) Target() *MySQLTarget {
+	return s.MySQL.Targets[s.MySQL.Target]
+}
+
+// decoder reads settings from a parsed YAML document and a provider, collecting an error for
+// every key with an invalid value and every secret that was not found
+type decoder struct {
+	secrets    Provider
+	errs       []string
+	missing    []string
+	unresolved []string
+}
+
+// fail records an invalid value of a key
+func (d *decoder) fail(key, format string, args ...interface{}) {
+	d.errs = append(d.errs, fmt.Sprintf("%s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+// settings reads the top level sections of a configuration file
+func (d *decoder) settings(tree interface{}, s *Settings) {
+	root := d.section(tree, "", "mysql", "rethinkdb", "workload", "collector", "health", "store")
+
+	if m := d.section(root["mysql"], "mysql", "target", "targets"); m != nil {
+		d.targets(m["targets"], s)
+
+		if name := d.str(m, "target", "mysql.target"); name != "" {
+			d.selectTarget(s, name, "mysql.target")
+		}
+	}
+
+	if m := d.section(root["rethinkdb"], "rethinkdb", "address", "database", "username", "password"); m != nil {
+		s.RethinkDB.Address = d.address(m, "address", "rethinkdb.address", s.RethinkDB.Address)
+		s.RethinkDB.Database = d.strDefault(m, "database", "rethinkdb.database", s.RethinkDB.Database)
+		s.RethinkDB.Username = d.str(m, "username", "rethinkdb.username")
+		s.RethinkDB.Password = d.str(m, "password", "rethinkdb.password")
+	}
+
+	if m := d.section(root["workload"], "workload", "database", "queries", "files"); m != nil {
+		s.Workload.Database = d.strDefault(m, "database", "workload.database", s.Workload.Database)
+		s.Workload.Queries = d.list(m, "queries", "workload.queries")
+		s.Workload.Files = d.list(m, "files", "workload.files")
+	}
+
+	if m := d.section(root["collector"], "collector", "interval", "count"); m != nil {
+		if _, ok := m["interval"]; ok {
+			s.Collector.Interval = d.duration(m, "interval", "collector.interval")
+		}
+
+		s.Collector.Count = d.integer(m, "count", "collector.count", 0, 0, -1)
+	}
+
+	if m := d.section(root["health"], "health", "address"); m != nil {
+		s.Health.Address = d.address(m, "address", "health.address", "")
+	}
+
+	if v := d.str(root, "store", "store"); v != "" {
+		d.store(s, "store", v)
+	}
+}
+
+// store selects the result store of a URL
+func (d *decoder) store(s *Settings, key, location string) {
+	store, err := ParseStore(location)
+
+	if err != nil {
+		d.fail(key, "%s", err)
+		return
+	}
+
+	s.Store = store
+}
+
+// targets reads the named MySQL targets, selecting the default target, or the only one
+func (d *decoder) targets(v interface{}, s *Settings) {
+	if v == nil {
+		return
+	}
+
+	targets, ok := v.(map[interface{}]interface{})
+
+	if !ok || len(targets) == 0 {
+		d.fail("mysql.targets", "expected a mapping of target names to MySQL servers")
+		return
+	}
+
+	s.MySQL.Targets = map[string]*MySQLTarget{}
+	names := []string{}
+
+	for name, tv := range targets {
+		key := fmt.Sprintf("mysql.targets.%v", name)
+		m := d.section(tv, key, "host", "port", "user", "password", "socket", "max_connections", "read_only")
+		t := &MySQLTarget{
+			Host:           d.str(m, "host", key+".host"),
+			Port:           d.integer(m, "port", key+".port", 3306, 1, 65535),
+			User:           d.str(m, "user", key+".user"),
+			Password:       d.str(m, "password", key+".password"),
+			Socket:         d.str(m, "socket", key+".socket"),
+			MaxConnections: d.integer(m, "max_connections", key+".max_connections", 151, 1, -1),
+			ReadOnly:       d.boolean(m, "read_only", key+".read_only"),
+		}
+
+		s.MySQL.Targets[fmt.Sprint(name)] = t
+		names = append(names, fmt.Sprint(name))
+	}
+
+	s.MySQL.Target = ""
+
+	if _, ok := s.MySQL.Targets[DefaultTarget]; ok {
+		s.MySQL.Target = DefaultTarget
+	} else if len(names) == 1 {
+		s.MySQL.Target = names[0]
+	}
+}
+
+// selectTarget selects the MySQL target with a name, reporting the key that named it if there is none
+func (d *decoder) selectTarget(s *Settings, name, key string) {
+	if _, ok := s.MySQL.Targets[name]; !ok {
+		names := []string{}
+
+		for n := range s.MySQL.Targets {
+			names = append(names, n)
+		}
+
+		sort.Strings(names)
+		d.fail(key, "there is no MySQL target named %q, expected one of %s", name, strings.Join(names, ", "))
+
+		return
+	}
+
+	s.MySQL.Target = name
+}
+
+// lookup reads a variable from the provider, treating an empty value as not set
+func (d *decoder) lookup(key string) string {
+	v, _, err := d.secrets.Lookup(key)
+
+	if err != nil {
+		d.fail(key, "%s", strings.Replace(err.Error(), "\n", ", ", -1))
+		return ""
+	}
+
+	return v
+}
+
+var secretReference = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+// resolve replaces a ${KEY} reference with the secret it names, recording the secrets not found
+func (d *decoder) resolve(key, v string) string {
+	m := secretReference.FindStringSubmatch(v)
+
+	if m == nil {
+		return v
+	}
+
+	secret, ok, err := d.secrets.Lookup(m[1])
+
+	if err != nil {
+		d.fail(key, "%s", strings.Replace(err.Error(), "\n", ", ", -1))
+	} else if !ok {
+		d.missing = append(d.missing, fmt.Sprintf("%s (%s)", m[1], key))
+		d.unresolved = append(d.unresolved, fmt.Sprintf("%s: missing required secret %s", key, m[1]))
+	}
+
+	return secret
+}
+
+// requireTarget only keeps the missing secrets of the selected MySQL target, as the other targets
+// may not be used
+func (d *decoder) requireTarget(name string) {
+	missing, unresolved := d.missing[:0], d.unresolved[:0]
+
+	for i, key := range d.unresolved {
+		if !strings.HasPrefix(key, "mysql.targets.") || strings.HasPrefix(key, join("mysql.targets", name)+".") {
+			missing, unresolved = append(missing, d.missing[i]), append(unresolved, key)
+		}
+	}
+
+	d.missing, d.unresolved = missing, unresolved
+}
+
+// environment overrides the settings with the environment variables gopherDigest has always read
+func (d *decoder) environment(s *Settings) {
+	getenv := d.lookup
+
+	if name := getenv("MYSQL_TARGET"); name != "" {
+		d.selectTarget(s, name, "MYSQL_TARGET")
+	}
+
+	env := func(key string, value *string) {
+		if v := getenv(key); v != "" {
+			*value = v
+		}
+	}
+
+	if v := getenv("RDB_ADDRESS"); v != "" {
+		s.RethinkDB.Address = d.checkAddress("RDB_ADDRESS", v)
+	}
+
+	env("RDB_DATABASE", &s.RethinkDB.Database)
+	env("RDB_USERNAME", &s.RethinkDB.Username)
+	env("RDB_PASSWORD", &s.RethinkDB.Password)
+
+	if v := getenv("HEALTH_ADDRESS"); v != "" {
+		s.Health.Address = d.checkAddress("HEALTH_ADDRESS", v)
+	}
+
+	if v := getenv("GOPHERDIGEST_STORE"); v != "" {
+		d.store(s, "GOPHERDIGEST_STORE", v)
+	}
+}
+
+// targetEnvironment overrides the selected MySQL target with the MYSQL_ environment variables
+func (d *decoder) targetEnvironment(t *MySQLTarget) {
+	getenv := d.lookup
+
+	for key, value := range map[string]*string{
+		"MYSQL_HOST": &t.Host, "MYSQL_USER": &t.User, "MYSQL_PASSWORD": &t.Password, "MYSQL_SOCKET": &t.Socket,
+	} {
+		if v := getenv(key); v != "" {
+			*value = v
+		}
+	}
+
+	if v := getenv("MYSQL_PORT"); v != "" {
+		t.Port = d.parseInt("MYSQL_PORT", v, 1, 65535)
+	}
+
+	if v := getenv("MYSQL_MAX_CONNECTIONS"); v != "" {
+		t.MaxConnections = d.parseInt("MYSQL_MAX_CONNECTIONS", v, 1, -1)
+	}
+
+	if v := getenv("MYSQL_READ_ONLY"); v != "" {
+		readOnly, err := strconv.ParseBool(v)
+
+		if err != nil {
+			d.fail("MYSQL_READ_ONLY", "expected true or false, got %q", v)
+		}
+
+		t.ReadOnly = readOnly
+	}
+}
+
+// section reads a mapping, reporting every key it does not expect
+func (d *decoder) section(v interface{}, key string, keys ...string) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+
+	m, ok := v.(map[interface{}]interface{})
+
+	if !ok {
+		d.fail(keyName(key), "expected a mapping, got %v", v)
+		return nil
+	}
+
+	section := map[string]interface{}{}
+
+	for k, value := range m {
+		name := fmt.Sprint(k)
+		known := false
+
+		for _, expected := range keys {
+			known = known || name == expected
+		}
+
+		if !known {
+			d.fail(join(key, name), "unknown key, expected one of %s", strings.Join(keys, ", "))
+			continue
+		}
+
+		section[name] = value
+	}
+
+	return section
+}
+
+// str reads a string, accepting numbers and booleans as they are written
+func (d *decoder) str(m map[string]interface{}, name, key string) string {
+	switch v := m[name].(type) {
+	case nil:
+		return ""
+	case string:
+		return d.resolve(key, v)
+	case int, float64, bool:
+		return fmt.Sprint(v)
+	default:
+		d.fail(key, "expected a string, got %v", v)
+		return ""
+	}
+}
+
+// strDefault reads a string, keeping a default when the key is not set
+func (d *decoder) strDefault(m map[string]interface{}, name, key, def string) string {
+	if v := d.str(m, name, key); v != "" {
+		return v
+	}
+
+	return def
+}
+
+// integer reads an integer of at least min and at most max, unless max is negative, keeping a
+// default when the key is not set
+func (d *decoder) integer(m map[string]interface{}, name, key string, def, min, max int) int {
+	switch v := m[name].(type) {
+	case nil:
+		return def
+	case int:
+		return d.checkInt(key, v, min, max)
+	case string:
+		return d.parseInt(key, v, min, max)
+	default:
+		d.fail(key, "expected a whole number, got %v", v)
+		return def
+	}
+}
+
+// parseInt parses an integer of at least min and at most max, unless max is negative
+func (d *decoder) parseInt(key, v string, min, max int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+
+	if err != nil {
+		d.fail(key, "expected a whole number, got %q", v)
+		return 0
+	}
+
+	return d.checkInt(key, n, min, max)
+}
+
+// checkInt verifies an integer is at least min and at most max, unless max is negative
+func (d *decoder) checkInt(key string, n, min, max int) int {
+	switch {
+	case max >= 0 && (n < min || n > max):
+		d.fail(key, "must be between %d and %d, got %d", min, max, n)
+	case n < min:
+		d.fail(key, "must be at least %d, got %d", min, n)
+	}
+
+	return n
+}
+
+// boolean reads true or false
+func (d *decoder) boolean(m map[string]interface{}, name, key string) bool {
+	switch v := m[name].(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	default:
+		d.fail(key, "expected true or false, got %v", v)
+		return false
+	}
+}
+
+// duration reads a positive duration such as 30s or 5m
+func (d *decoder) duration(m map[string]interface{}, name, key string) time.Duration {
+	s, ok := m[name].(string)
+	dur, err := time.ParseDuration(s)
+
+	if !ok || err != nil {
+		d.fail(key, "expected a duration such as 30s or 5m, got %v", m[name])
+		return 0
+	}
+
+	if dur <= 0 {
+		d.fail(key, "must be positive, got %s", dur)
+	}
+
+	return dur
+}
+
+// list reads a list of strings, or a single string as a list of one
+func (d *decoder) list(m map[string]interface{}, name, key string) []string {
+	switch v := m[name].(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{v}
+	case []interface{}:
+		items := []string{}
+
+		for i, item := range v {
+			s, ok := item.(string)
+
+			if !ok {
+				d.fail(fmt.Sprintf("%s[%d]", key, i), "expected a string, got %v", item)
+				continue
+			}
+
+			items = append(items, s)
+		}
+
+		return items
+	default:
+		d.fail(key, "expected a list of strings, got %v", v)
+		return nil
+	}
+}
+
+// address reads a host:port address, keeping a default when the key is not set
+func (d *decoder) address(m map[string]interface{}, name, key, def string) string {
+	v := d.str(m, name, key)
+
+	if v == "" {
+		return def
+	}
+
+	return d.checkAddress(key, v)
+}
+
+// checkAddress verifies an address is a host and port
+func (d *decoder) checkAddress(key, v string) string {
+	_, port, err := net.SplitHostPort(v)
+
+	if err == nil {
+		_, err = strconv.ParseUint(port, 10, 16)
+	}
+
+	if err != nil {
+		d.fail(key, "expected a host:port address, got %q", v)
+	}
+
+	return v
+}
+
+// join appends a key to the key of its section
+func join(section, name string) string {
+	if section == "" {
+		return name
+	}
+
+	return section + "." + name
+}
+
+// keyName names a key for an error, where the empty key is the whole file
+func keyName(key string) string {
+	if key == "" {
+		return "configuration file"
+	}
+
+	return key
+}
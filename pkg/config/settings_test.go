@@ -0,0 +1,261 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const settingsFile = `
+mysql:
+  target: primary
+  targets:
+    primary:
+      host: db
+      port: 3306
+      user: gopher
+      password: secret
+      socket: /var/run/mysqld/mysqld.sock
+    replica:
+      host: replica
+      port: "3307"
+      max_connections: 50
+      read_only: true
+rethinkdb:
+  address: rdb:28015
+  username: gopher
+workload:
+  database: sakila
+  queries:
+    - SELECT * FROM film
+  files: workload.sql
+collector:
+  interval: 30s
+  count: 10
+health:
+  address: :8081
+store: sqlite:///var/lib/gopherDigest.db
+`
+
+func writeSettings(t *testing.T, yaml string) (string, func()) {
+	dir, err := ioutil.TempDir("", "settings")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "gopherDigest.yaml")
+
+	if err := ioutil.WriteFile(path, []byte(yaml), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestLoadEnvironment(t *testing.T) {
+	s, err := Load("", Values(map[string]string{
+		"MYSQL_USER": "root", "MYSQL_HOST": "127.0.0.1", "MYSQL_READ_ONLY": "1", "RDB_DATABASE": "Digests",
+	}), "")
+
+	if err != nil {
+		t.Fatalf("Load should not return an error, but got %s", err)
+	}
+
+	expected := MySQLTarget{User: "root", Host: "127.0.0.1", Port: 3306, MaxConnections: 151, ReadOnly: true}
+
+	if s.MySQL.Target != DefaultTarget || !reflect.DeepEqual(*s.Target(), expected) {
+		t.Errorf("Load should configure the default target %+v from the environment, but got %+v", expected, s.MySQL)
+	}
+
+	if s.RethinkDB.Database != "Digests" || s.RethinkDB.Address != "localhost:28015" {
+		t.Errorf("Load should override the default RethinkDB settings with the environment, but got %+v", s.RethinkDB)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path, cleanup := writeSettings(t, settingsFile)
+	defer cleanup()
+
+	s, err := Load(path, Values(map[string]string{"MYSQL_HOST": "db.internal", "RDB_PASSWORD": "rdbsecret"}), "")
+
+	if err != nil {
+		t.Fatalf("Load should not return an error, but got %s", err)
+	}
+
+	expected := MySQLTarget{
+		Host: "db.internal", Port: 3306, User: "gopher", Password: "secret", Socket: "/var/run/mysqld/mysqld.sock", MaxConnections: 151,
+	}
+
+	if s.MySQL.Target != "primary" || !reflect.DeepEqual(*s.Target(), expected) {
+		t.Errorf("Load should select the primary target %+v, overridden by the environment, but got %+v", expected, *s.Target())
+	}
+
+	if !reflect.DeepEqual(s.RethinkDB, RethinkDBSettings{Address: "rdb:28015", Database: "GopherDigest", Username: "gopher", Password: "rdbsecret"}) {
+		t.Errorf("Load should read the RethinkDB settings from the file and environment, but got %+v", s.RethinkDB)
+	}
+
+	if !reflect.DeepEqual(s.Workload, WorkloadSettings{Database: "sakila", Queries: []string{"SELECT * FROM film"}, Files: []string{"workload.sql"}}) {
+		t.Errorf("Load should read the workload from the file, but got %+v", s.Workload)
+	}
+
+	if s.Collector.Interval != 30*time.Second || s.Collector.Count != 10 || s.Health.Address != ":8081" {
+		t.Errorf("Load should read the collector and health settings from the file, but got %+v, %+v", s.Collector, s.Health)
+	}
+
+	if s.Store != (StoreSettings{Driver: StoreSQLite, Path: "/var/lib/gopherDigest.db"}) {
+		t.Errorf("Load should read the store from the file, but got %+v", s.Store)
+	}
+
+	s, err = Load(path, Values(map[string]string{"MYSQL_TARGET": "primary", "MYSQL_USER": "monitor"}), "replica")
+
+	if err != nil {
+		t.Fatalf("Load should not return an error, but got %s", err)
+	}
+
+	expected = MySQLTarget{Host: "replica", Port: 3307, User: "monitor", MaxConnections: 50, ReadOnly: true}
+
+	if s.MySQL.Target != "replica" || !reflect.DeepEqual(*s.Target(), expected) {
+		t.Errorf("the target flag should select the replica %+v over the environment, but got %+v", expected, *s.Target())
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tt := []struct {
+		name     string
+		file     string
+		env      map[string]string
+		target   string
+		expected []string
+	}{
+		{
+			"Invalid Environment",
+			"",
+			map[string]string{"MYSQL_PORT": "33o6", "MYSQL_MAX_CONNECTIONS": "-1", "MYSQL_READ_ONLY": "maybe", "RDB_ADDRESS": "rdb", "GOPHERDIGEST_STORE": "sqlite://"},
+			"",
+			[]string{
+				`GOPHERDIGEST_STORE: expected the path of a file after sqlite://, got "sqlite://"`,
+				`MYSQL_MAX_CONNECTIONS: must be at least 1, got -1`,
+				`MYSQL_PORT: expected a whole number, got "33o6"`,
+				`MYSQL_READ_ONLY: expected true or false, got "maybe"`,
+				`RDB_ADDRESS: expected a host:port address, got "rdb"`,
+			},
+		},
+		{
+			"Invalid File",
+			"mysql:\n  targets:\n    primary:\n      hots: db\n      port: 70000\ncollector:\n  interval: 10\n  count: many\nstorage: {}\nstore: mongodb://db\n",
+			nil,
+			"",
+			[]string{
+				`collector.count: expected a whole number, got "many"`,
+				`collector.interval: expected a duration such as 30s or 5m, got 10`,
+				`mysql.targets.primary.hots: unknown key, expected one of host, port, user, password, socket, max_connections, read_only`,
+				`mysql.targets.primary.port: must be between 1 and 65535, got 70000`,
+				`storage: unknown key, expected one of mysql, rethinkdb, workload, collector, health, store`,
+				`store: expected rethinkdb, memory, sqlite:///path.db or jsonl:///path.jsonl, got "mongodb://db"`,
+			},
+		},
+		{
+			"Ambiguous Target",
+			"mysql:\n  targets:\n    primary: {host: db}\n    replica: {host: replica}\n",
+			nil,
+			"",
+			[]string{`mysql.target: required to choose between the MySQL targets`},
+		},
+		{
+			"Unknown Target",
+			"mysql:\n  target: primary\n  targets:\n    primary: {host: db}\n",
+			map[string]string{"MYSQL_TARGET": "replica"},
+			"staging",
+			[]string{
+				`-target: there is no MySQL target named "staging", expected one of primary`,
+				`MYSQL_TARGET: there is no MySQL target named "replica", expected one of primary`,
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			path := ""
+
+			if tc.file != "" {
+				var cleanup func()
+				path, cleanup = writeSettings(t, tc.file)
+				defer cleanup()
+			}
+
+			_, err := Load(path, Values(tc.env), tc.target)
+
+			if err == nil {
+				t.Fatalf("Load should return an error")
+			}
+
+			actual := strings.Split(err.Error(), "\n  ")[1:]
+
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("Load should report\n%s\nbut got\n%s", strings.Join(tc.expected, "\n"), strings.Join(actual, "\n"))
+			}
+		})
+	}
+}
+
+func TestLoadSecrets(t *testing.T) {
+	path, cleanup := writeSettings(t, "mysql:\n  targets:\n    primary:\n      user: gopher\n      password: ${MYSQL_PASSWORD}\n    replica:\n      password: ${REPLICA_PASSWORD}\nrethinkdb:\n  password: ${RDB_PASSWORD}\n")
+	defer cleanup()
+
+	s, err := Load(path, Values(map[string]string{"MYSQL_PASSWORD": "secret", "RDB_PASSWORD": "rdbsecret"}), "primary")
+
+	if err != nil {
+		t.Fatalf("Load should not return an error, but got %s", err)
+	}
+
+	if s.Target().Password != "secret" || s.RethinkDB.Password != "rdbsecret" {
+		t.Errorf("Load should replace the secret references with their values, but got %+v, %+v", *s.Target(), s.RethinkDB)
+	}
+
+	_, err = Load(path, Values(map[string]string{"MYSQL_PASSWORD": "secret", "RDB_PASSWORD": "rdbsecret"}), "replica")
+	expected := "missing required secrets: REPLICA_PASSWORD (mysql.targets.replica.password)"
+
+	if err == nil || err.Error() != expected {
+		t.Errorf("Load should require the secrets of the selected target, reporting %q, but got %v", expected, err)
+	}
+
+	_, err = Load(path, Values(nil), "primary")
+	expected = "missing required secrets: MYSQL_PASSWORD (mysql.targets.primary.password), RDB_PASSWORD (rethinkdb.password)"
+
+	if err == nil || err.Error() != expected {
+		t.Errorf("Load should report %q, but got %v", expected, err)
+	}
+}
+
+func TestParseStore(t *testing.T) {
+	tt := []struct {
+		url      string
+		expected StoreSettings
+	}{
+		{"rethinkdb", StoreSettings{Driver: StoreRethinkDB}},
+		{"memory", StoreSettings{Driver: StoreMemory}},
+		{"sqlite:///var/lib/gopherDigest.db", StoreSettings{Driver: StoreSQLite, Path: "/var/lib/gopherDigest.db"}},
+		{"sqlite://gopherDigest.db", StoreSettings{Driver: StoreSQLite, Path: "gopherDigest.db"}},
+		{"jsonl:///tmp/results.jsonl", StoreSettings{Driver: StoreJSONL, Path: "/tmp/results.jsonl"}},
+		{"jsonl://results.jsonl?max_size=10MB&rotate=daily", StoreSettings{Driver: StoreJSONL, Path: "results.jsonl", MaxSize: 10 << 20, RotateDaily: true}},
+	}
+
+	for _, tc := range tt {
+		actual, err := ParseStore(tc.url)
+
+		if err != nil || actual != tc.expected {
+			t.Errorf("ParseStore(%q) should return %+v, but got %+v, %v", tc.url, tc.expected, actual, err)
+		}
+	}
+
+	for _, url := range []string{"jsonl://results.jsonl?max_size=lots", "jsonl://results.jsonl?rotate=hourly", "jsonl://results.jsonl?compress=gzip", "jsonl://?rotate=daily"} {
+		if _, err := ParseStore(url); err == nil {
+			t.Errorf("ParseStore(%q) should return an error", url)
+		}
+	}
+}
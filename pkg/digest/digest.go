@@ -0,0 +1,233 @@
+package digest
+
+import (
+	"fmt"
+	"gopherDigest/pkg/format"
+	"gopherDigest/pkg/mysql"
+	"gopherDigest/pkg/slowlog"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Attribute names follow the slow query log headers they are read from
+const (
+	QueryTime    = "Query_time"
+	LockTime     = "Lock_time"
+	RowsSent     = "Rows_sent"
+	RowsExamined = "Rows_examined"
+)
+
+// Attributes lists every aggregated event attribute
+var Attributes = []string{QueryTime, LockTime, RowsSent, RowsExamined}
+
+// Stats lists the statistics a profile can be ranked by
+var Stats = []string{"sum", "min", "max", "avg", "median", "95", "cnt"}
+
+// Metric summarizes the values of an attribute across every event of a class
+type Metric struct {
+	Sum    float64
+	Min    float64
+	Max    float64
+	Avg    float64
+	Median float64
+	Pct95  float64
+	values []float64
+}
+
+// Class represents every event sharing a fingerprint
+type Class struct {
+	Rank          int
+	Fingerprint   string
+	Checksum      string
+	Count         int64
+	Example       string
+	Database      string
+	FirstSeen     time.Time
+	LastSeen      time.Time
+	Metrics       map[string]*Metric
+	ResponseShare float64
+	slowest       float64
+}
+
+// OrderBy defines the attribute and statistic a profile is ranked by, such as Query_time:sum
+type OrderBy struct {
+	Attribute string
+	Stat      string
+}
+
+// Aggregator groups query events by fingerprint
+type Aggregator struct {
+	classes map[string]*Class
+	order   []string
+}
+
+// NewAggregator creates an empty aggregator
+func NewAggregator() *Aggregator {
+	return &Aggregator{classes: map[string]*Class{}}
+}
+
+// ParseOrderBy parses an 'attribute:stat' ranking, where the stat defaults to sum
+func ParseOrderBy(spec string) (OrderBy, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	o := OrderBy{Attribute: parts[0], Stat: "sum"}
+
+	if len(parts) == 2 {
+		o.Stat = parts[1]
+	}
+
+	if format.IndexOfString(o.Attribute, Attributes) == -1 {
+		return o, fmt.Errorf("cannot order by unknown attribute %q, expected one of %s", o.Attribute, strings.Join(Attributes, ", "))
+	}
+
+	if format.IndexOfString(o.Stat, Stats) == -1 {
+		return o, fmt.Errorf("cannot order by unknown statistic %q, expected one of %s", o.Stat, strings.Join(Stats, ", "))
+	}
+
+	return o, nil
+}
+
+// String formats the ranking the way it is parsed
+func (o OrderBy) String() string {
+	return o.Attribute + ":" + o.Stat
+}
+
+// Add aggregates an event into the class of its fingerprint
+func (a *Aggregator) Add(e slowlog.Event) {
+	fingerprint := mysql.Fingerprint(e.Query)
+	c, ok := a.classes[fingerprint]
+
+	if !ok {
+		c = &Class{
+			Fingerprint: fingerprint,
+			Checksum:    mysql.Checksum(fingerprint),
+			Metrics:     map[string]*Metric{},
+			FirstSeen:   e.Time,
+			LastSeen:    e.Time,
+		}
+
+		for _, attr := range Attributes {
+			c.Metrics[attr] = &Metric{}
+		}
+
+		a.classes[fingerprint] = c
+		a.order = append(a.order, fingerprint)
+	}
+
+	c.Count++
+
+	// keep the slowest statement as the class's example, like pt-query-digest
+	if c.Count == 1 || e.QueryTime > c.slowest {
+		c.Example = e.Query
+		c.Database = e.Database
+		c.slowest = e.QueryTime
+	}
+
+	if !e.Time.IsZero() && (c.FirstSeen.IsZero() || e.Time.Before(c.FirstSeen)) {
+		c.FirstSeen = e.Time
+	}
+
+	if e.Time.After(c.LastSeen) {
+		c.LastSeen = e.Time
+	}
+
+	c.Metrics[QueryTime].add(e.QueryTime)
+	c.Metrics[LockTime].add(e.LockTime)
+	c.Metrics[RowsSent].add(float64(e.RowsSent))
+	c.Metrics[RowsExamined].add(float64(e.RowsExamined))
+}
+
+// Profile ranks every class by an attribute's statistic, highest first, and computes
+// each class's share of the total response time
+func (a *Aggregator) Profile(o OrderBy) []Class {
+	classes := []Class{}
+	total := 0.0
+
+	for _, fingerprint := range a.order {
+		c := a.classes[fingerprint]
+
+		for _, m := range c.Metrics {
+			m.summarize()
+		}
+
+		total += c.Metrics[QueryTime].Sum
+		classes = append(classes, *c)
+	}
+
+	for i := range classes {
+		if total > 0 {
+			classes[i].ResponseShare = classes[i].Metrics[QueryTime].Sum / total
+		}
+	}
+
+	sort.SliceStable(classes, func(i, j int) bool {
+		return classes[i].value(o) > classes[j].value(o)
+	})
+
+	for i := range classes {
+		classes[i].Rank = i + 1
+	}
+
+	return classes
+}
+
+// value returns the statistic a class is ranked by
+func (c Class) value(o OrderBy) float64 {
+	m := c.Metrics[o.Attribute]
+
+	switch o.Stat {
+	case "min":
+		return m.Min
+	case "max":
+		return m.Max
+	case "avg":
+		return m.Avg
+	case "median":
+		return m.Median
+	case "95":
+		return m.Pct95
+	case "cnt":
+		return float64(c.Count)
+	}
+
+	return m.Sum
+}
+
+// add records a value of an attribute
+func (m *Metric) add(v float64) {
+	m.values = append(m.values, v)
+}
+
+// summarize computes the metric's statistics from its recorded values
+func (m *Metric) summarize() {
+	if len(m.values) == 0 {
+		return
+	}
+
+	sorted := append([]float64{}, m.values...)
+	sort.Float64s(sorted)
+
+	m.Sum = 0
+
+	for _, v := range sorted {
+		m.Sum += v
+	}
+
+	m.Min = sorted[0]
+	m.Max = sorted[len(sorted)-1]
+	m.Avg = m.Sum / float64(len(sorted))
+	m.Median = percentile(sorted, 0.5)
+	m.Pct95 = percentile(sorted, 0.95)
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+
+	if rank < 0 {
+		rank = 0
+	}
+
+	return sorted[rank]
+}
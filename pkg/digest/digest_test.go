@@ -0,0 +1,144 @@
+package digest
+
+import (
+	"gopherDigest/pkg/slowlog"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func events() []slowlog.Event {
+	at := time.Date(2023, 11, 2, 14, 0, 0, 0, time.UTC)
+
+	return []slowlog.Event{
+		{Time: at, Database: "employees", QueryTime: 1, LockTime: 0.1, RowsSent: 1, RowsExamined: 100, Query: "SELECT * FROM employees WHERE emp_no = 1"},
+		{Time: at.Add(time.Minute), Database: "employees", QueryTime: 4, LockTime: 0.2, RowsSent: 1, RowsExamined: 300, Query: "SELECT * FROM employees WHERE emp_no = 2"},
+		{Time: at.Add(-time.Minute), Database: "employees", QueryTime: 2, LockTime: 0.3, RowsSent: 0, RowsExamined: 200, Query: "select * from employees where emp_no = 3"},
+		{Time: at, QueryTime: 3, LockTime: 0, RowsSent: 500, RowsExamined: 50, Query: "SELECT * FROM salaries LIMIT 500"},
+	}
+}
+
+func TestProfile(t *testing.T) {
+	agg := NewAggregator()
+
+	for _, e := range events() {
+		agg.Add(e)
+	}
+
+	classes := agg.Profile(OrderBy{QueryTime, "sum"})
+
+	if len(classes) != 2 {
+		t.Fatalf("Profile should return 2 classes, but got %d", len(classes))
+	}
+
+	c := classes[0]
+
+	if c.Rank != 1 || c.Fingerprint != "select * from employees where emp_no = ?" || c.Count != 3 {
+		t.Errorf("Profile should rank the employees lookup first with 3 calls, but got %+v", c)
+	}
+
+	if c.Example != "SELECT * FROM employees WHERE emp_no = 2" {
+		t.Errorf("Example of a class should be its slowest query, but got %q", c.Example)
+	}
+
+	if !c.FirstSeen.Equal(events()[2].Time) || !c.LastSeen.Equal(events()[1].Time) {
+		t.Errorf("FirstSeen and LastSeen should span the class's events, but got %v and %v", c.FirstSeen, c.LastSeen)
+	}
+
+	if math.Abs(c.ResponseShare-0.7) > 1e-9 || math.Abs(classes[1].ResponseShare-0.3) > 1e-9 {
+		t.Errorf("ResponseShare should be 0.7 and 0.3, but got %v and %v", c.ResponseShare, classes[1].ResponseShare)
+	}
+
+	tt := []struct {
+		attribute string
+		expected  Metric
+	}{
+		{QueryTime, Metric{Sum: 7, Min: 1, Max: 4, Avg: 7.0 / 3, Median: 2, Pct95: 4}},
+		{RowsSent, Metric{Sum: 2, Min: 0, Max: 1, Avg: 2.0 / 3, Median: 1, Pct95: 1}},
+		{RowsExamined, Metric{Sum: 600, Min: 100, Max: 300, Avg: 200, Median: 200, Pct95: 300}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.attribute, func(t *testing.T) {
+			actual := *c.Metrics[tc.attribute]
+			actual.values = nil
+
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("%s metric should be %+v, but got %+v", tc.attribute, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestProfileOrderBy(t *testing.T) {
+	tt := []struct {
+		orderBy  OrderBy
+		expected string
+	}{
+		{OrderBy{QueryTime, "sum"}, "select * from employees where emp_no = ?"},
+		{OrderBy{QueryTime, "max"}, "select * from employees where emp_no = ?"},
+		{OrderBy{QueryTime, "avg"}, "select * from salaries limit ?"},
+		{OrderBy{RowsSent, "sum"}, "select * from salaries limit ?"},
+		{OrderBy{RowsExamined, "min"}, "select * from employees where emp_no = ?"},
+		{OrderBy{LockTime, "cnt"}, "select * from employees where emp_no = ?"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.orderBy.String(), func(t *testing.T) {
+			agg := NewAggregator()
+
+			for _, e := range events() {
+				agg.Add(e)
+			}
+
+			if actual := agg.Profile(tc.orderBy)[0].Fingerprint; actual != tc.expected {
+				t.Errorf("Profile ordered by %s should rank %q first, but got %q", tc.orderBy, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseOrderBy(t *testing.T) {
+	tt := []struct {
+		spec     string
+		expected OrderBy
+		isValid  bool
+	}{
+		{"Query_time", OrderBy{QueryTime, "sum"}, true},
+		{"Rows_examined:95", OrderBy{RowsExamined, "95"}, true},
+		{"Lock_time:median", OrderBy{LockTime, "median"}, true},
+		{"Tmp_tables:sum", OrderBy{}, false},
+		{"Query_time:p99", OrderBy{}, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.spec, func(t *testing.T) {
+			actual, err := ParseOrderBy(tc.spec)
+
+			if (err == nil) != tc.isValid {
+				t.Fatalf("ParseOrderBy of %q validity should be %v, but got error %v", tc.spec, tc.isValid, err)
+			}
+
+			if tc.isValid && actual != tc.expected {
+				t.Errorf("ParseOrderBy of %q should be %+v, but got %+v", tc.spec, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20}
+
+	if actual := percentile(values, 0.95); actual != 19 {
+		t.Errorf("95th percentile of 1..20 should be 19, but got %v", actual)
+	}
+
+	if actual := percentile(values, 0.5); actual != 10 {
+		t.Errorf("median of 1..20 should be 10, but got %v", actual)
+	}
+
+	if actual := percentile([]float64{3}, 0.95); actual != 3 {
+		t.Errorf("95th percentile of a single value should be the value, but got %v", actual)
+	}
+}
@@ -0,0 +1,189 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds how long the probes of a request may run
+const DefaultTimeout = 10 * time.Second
+
+// Probe checks one dependency of gopherDigest, returning its detail for the status report
+type Probe func(ctx context.Context) (interface{}, error)
+
+// Server serves the liveness of gopherDigest on /healthz and its readiness on /readyz. Liveness
+// runs the Live probes, and readiness runs both the Live and the Ready probes.
+type Server struct {
+	Live    map[string]Probe
+	Ready   map[string]Probe
+	Timeout time.Duration
+}
+
+// Status is the JSON report of a set of probes
+type Status struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckStatus `json:"checks"`
+}
+
+// CheckStatus is the result of one probe
+type CheckStatus struct {
+	Status string      `json:"status"`
+	Error  string      `json:"error,omitempty"`
+	Detail interface{} `json:"detail,omitempty"`
+}
+
+// Statuses of a probe and of a report
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Handler routes /healthz and /readyz
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.Handle("/healthz", s.handle(s.Live))
+	mux.Handle("/readyz", s.handle(s.Live, s.Ready))
+
+	return mux
+}
+
+// ListenAndServe serves the health endpoints on an address until the context is cancelled
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+
+	if err != nil {
+		return fmt.Errorf("could not listen for health checks on %s\n%s", addr, err)
+	}
+
+	srv := &http.Server{Handler: s.Handler()}
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+
+		shutdown, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		srv.Shutdown(shutdown)
+	}()
+
+	if err := srv.Serve(ln); err != http.ErrServerClosed {
+		return fmt.Errorf("could not serve health checks on %s\n%s", addr, err)
+	}
+
+	<-done
+
+	return nil
+}
+
+// handle runs a set of probes for every request, responding 200 when they all pass and 503
+// when any fails
+func (s *Server) handle(sets ...map[string]Probe) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		timeout := s.Timeout
+
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		defer cancel()
+
+		status := run(ctx, sets...)
+		code := http.StatusOK
+
+		if status.Status != StatusOK {
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+
+		if req.Method == http.MethodGet {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			enc.Encode(status)
+		}
+	})
+}
+
+// run runs every probe of the sets concurrently and reports their results
+func run(ctx context.Context, sets ...map[string]Probe) Status {
+	status := Status{Status: StatusOK, Checks: map[string]CheckStatus{}}
+	names := []string{}
+	probes := map[string]Probe{}
+
+	for _, set := range sets {
+		for name, probe := range set {
+			names = append(names, name)
+			probes[name] = probe
+		}
+	}
+
+	sort.Strings(names)
+
+	results := make([]CheckStatus, len(names))
+	wg := sync.WaitGroup{}
+
+	for i, name := range names {
+		wg.Add(1)
+
+		go func(i int, probe Probe) {
+			defer wg.Done()
+			results[i] = check(ctx, probe)
+		}(i, probes[name])
+	}
+
+	wg.Wait()
+
+	for i, name := range names {
+		status.Checks[name] = results[i]
+
+		if results[i].Status != StatusOK {
+			status.Status = StatusFail
+		}
+	}
+
+	return status
+}
+
+// check runs a probe, failing it if it does not finish before the context
+func check(ctx context.Context, probe Probe) CheckStatus {
+	type result struct {
+		detail interface{}
+		err    error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		detail, err := probe(ctx)
+		done <- result{detail, err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return CheckStatus{Status: StatusFail, Error: res.err.Error(), Detail: res.detail}
+		}
+
+		return CheckStatus{Status: StatusOK, Detail: res.detail}
+	case <-ctx.Done():
+		return CheckStatus{Status: StatusFail, Error: ctx.Err().Error()}
+	}
+}
@@ -0,0 +1,129 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func ok(detail interface{}) Probe {
+	return func(ctx context.Context) (interface{}, error) {
+		return detail, nil
+	}
+}
+
+func failing(msg string) Probe {
+	return func(ctx context.Context) (interface{}, error) {
+		return map[string]bool{"connected": false}, errors.New(msg)
+	}
+}
+
+func TestServer(t *testing.T) {
+	tt := []struct {
+		name     string
+		server   Server
+		path     string
+		method   string
+		expected int
+		checks   map[string]string
+	}{
+		{
+			"live",
+			Server{Live: map[string]Probe{"dependencies": ok("found")}, Ready: map[string]Probe{"mysql": failing("connection refused")}},
+			"/healthz", http.MethodGet, http.StatusOK,
+			map[string]string{"dependencies": StatusOK},
+		},
+		{
+			"not ready",
+			Server{Live: map[string]Probe{"dependencies": ok("found")}, Ready: map[string]Probe{"mysql": failing("connection refused"), "rethinkdb": ok("open")}},
+			"/readyz", http.MethodGet, http.StatusServiceUnavailable,
+			map[string]string{"dependencies": StatusOK, "mysql": StatusFail, "rethinkdb": StatusOK},
+		},
+		{
+			"ready",
+			Server{Live: map[string]Probe{"dependencies": ok("found")}, Ready: map[string]Probe{"mysql": ok("open")}},
+			"/readyz", http.MethodGet, http.StatusOK,
+			map[string]string{"dependencies": StatusOK, "mysql": StatusOK},
+		},
+		{
+			"timeout",
+			Server{Ready: map[string]Probe{"mysql": func(ctx context.Context) (interface{}, error) {
+				time.Sleep(time.Second)
+				return nil, nil
+			}}, Timeout: 10 * time.Millisecond},
+			"/readyz", http.MethodGet, http.StatusServiceUnavailable,
+			map[string]string{"mysql": StatusFail},
+		},
+		{
+			"method not allowed",
+			Server{},
+			"/healthz", http.MethodPost, http.StatusMethodNotAllowed,
+			nil,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tc.server.Handler().ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+
+			if rec.Code != tc.expected {
+				t.Errorf("%s %s should respond %d, but got %d", tc.method, tc.path, tc.expected, rec.Code)
+			}
+
+			if tc.checks == nil {
+				return
+			}
+
+			var status Status
+
+			if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+				t.Fatalf("the response should be JSON, but got %s\n%s", err, rec.Body.String())
+			}
+
+			actual := map[string]string{}
+
+			for name, check := range status.Checks {
+				actual[name] = check.Status
+
+				if check.Status == StatusFail && check.Error == "" {
+					t.Errorf("the failed check %s should report its error", name)
+				}
+			}
+
+			if len(actual) != len(tc.checks) {
+				t.Errorf("the response should report the checks %v, but got %v", tc.checks, actual)
+			}
+
+			for name, expected := range tc.checks {
+				if actual[name] != expected {
+					t.Errorf("the check %s should be %s, but got %s", name, expected, actual[name])
+				}
+			}
+		})
+	}
+}
+
+func TestListenAndServe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		done <- (&Server{}).ListenAndServe(ctx, "127.0.0.1:0")
+	}()
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("ListenAndServe should stop without an error when its context is cancelled, but got %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("ListenAndServe should stop when its context is cancelled")
+	}
+}
@@ -0,0 +1,402 @@
+package jsonl
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"gopherDigest/pkg/config"
+	"gopherDigest/pkg/store"
+	"gopherDigest/pkg/types"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The kinds of record a JSON Lines file holds
+const (
+	KindPlan     = "plan"
+	KindRun      = "run"
+	KindInterval = "interval"
+)
+
+// Record is a line of a JSON Lines file, holding the captured plans of a query, the report of a
+// pt-query-digest run or the load of a statement digest over an interval
+type Record struct {
+	Kind     string                `json:"kind"`
+	Time     time.Time             `json:"time"`
+	Source   string                `json:"source,omitempty"`
+	Query    *types.QueryRecord    `json:"query,omitempty"`
+	Run      *types.DigestReport   `json:"run,omitempty"`
+	Interval *types.DigestInterval `json:"interval,omitempty"`
+}
+
+// Rotation defines when a file is rotated, once it holds MaxSize bytes if MaxSize is positive, and
+// when the day changes if Daily is set
+type Rotation struct {
+	MaxSize int64
+	Daily   bool
+}
+
+// rotatedLayout is the layout of the time in the name of a rotated file
+const rotatedLayout = "20060102T150405"
+
+// Sink appends results to a JSON Lines file, one record per line, rotating the file by renaming it
+// with the time it was rotated, such as results-20060102T150405.jsonl
+type Sink struct {
+	path     string
+	rotation Rotation
+	now      func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+var _ store.Store = (*Sink)(nil)
+
+// Open opens a JSON Lines file for appending after verifying it can be written. The file is only
+// created once a result is saved.
+func Open(path string, rotation Rotation) (*Sink, error) {
+	if err := writable(path); err != nil {
+		return nil, err
+	}
+
+	return &Sink{path: path, rotation: rotation, now: time.Now}, nil
+}
+
+// writable verifies results can be appended to a file, without creating it: an existing file must
+// open for appending, and the directory of a missing one must accept new files
+func writable(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+
+	if err == nil {
+		return f.Close()
+	}
+
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("could not open the results file %s\n%s", path, err)
+	}
+
+	probe, err := ioutil.TempFile(filepath.Dir(path), ".gopherDigest-")
+
+	if err != nil {
+		return fmt.Errorf("could not create the results file %s\n%s", path, err)
+	}
+
+	probe.Close()
+
+	return os.Remove(probe.Name())
+}
+
+// open opens the file, creating it if it does not exist, and continues its size and the day it was
+// last written
+func (s *Sink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+
+	if err != nil {
+		return fmt.Errorf("could not open the results file %s\n%s", s.path, err)
+	}
+
+	info, err := f.Stat()
+
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("could not open the results file %s\n%s", s.path, err)
+	}
+
+	s.file, s.size, s.opened = f, info.Size(), info.ModTime()
+
+	if s.size == 0 {
+		s.opened = s.now()
+	}
+
+	return nil
+}
+
+// rotated names a rotated file by the time it was rotated, before its extension
+func (s *Sink) rotated(t time.Time) string {
+	ext := filepath.Ext(s.path)
+	base := strings.TrimSuffix(s.path, ext)
+	name := fmt.Sprintf("%s-%s%s", base, t.Format(rotatedLayout), ext)
+
+	for n := 1; ; n++ {
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			return name
+		}
+
+		name = fmt.Sprintf("%s-%s.%d%s", base, t.Format(rotatedLayout), n, ext)
+	}
+}
+
+// rotate renames the file when writing n more bytes would exceed its size, or the day changed
+func (s *Sink) rotate(now time.Time, n int64) error {
+	y, m, d := s.opened.Date()
+	ny, nm, nd := now.Date()
+	daily := s.rotation.Daily && (y != ny || m != nm || d != nd)
+	full := s.rotation.MaxSize > 0 && s.size+n > s.rotation.MaxSize
+
+	if s.size == 0 || (!daily && !full) {
+		return nil
+	}
+
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("could not close the results file %s\n%s", s.path, err)
+	}
+
+	if err := os.Rename(s.path, s.rotated(now)); err != nil {
+		return fmt.Errorf("could not rotate the results file %s\n%s", s.path, err)
+	}
+
+	return s.open()
+}
+
+// write appends records to the file, each on its own line
+func (s *Sink) write(records ...Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil && len(records) > 0 {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+
+	for _, rec := range records {
+		b, err := json.Marshal(rec)
+
+		if err != nil {
+			return fmt.Errorf("could not encode a %s record\n%s", rec.Kind, err)
+		}
+
+		b = append(b, '\n')
+
+		if err := s.rotate(rec.Time, int64(len(b))); err != nil {
+			return err
+		}
+
+		if _, err := s.file.Write(b); err != nil {
+			return fmt.Errorf("could not write to the results file %s\n%s", s.path, err)
+		}
+
+		s.size += int64(len(b))
+	}
+
+	return nil
+}
+
+// SavePlan appends the EXPLAIN rows, JSON execution plan and optional EXPLAIN ANALYZE tree of a
+// captured query, timestamped with the time it was saved
+func (s *Sink) SavePlan(rec types.QueryRecord) error {
+	now := s.now()
+	rec.QueryTime, rec.Timestamp = now, now.Unix()
+
+	return s.write(Record{Kind: KindPlan, Time: now, Query: &rec})
+}
+
+// SaveRun appends the report of a pt-query-digest run
+func (s *Sink) SaveRun(report types.DigestReport) error {
+	now := s.now()
+	report.Timestamp = now.Unix()
+
+	return s.write(Record{Kind: KindRun, Time: now, Source: report.Source, Run: &report})
+}
+
+// SaveDigestSnapshot appends the load of every statement digest over an interval, one record per
+// digest
+func (s *Sink) SaveDigestSnapshot(intervals []types.DigestInterval) error {
+	now := s.now()
+	records := make([]Record, len(intervals))
+
+	for i := range intervals {
+		records[i] = Record{Kind: KindInterval, Time: now, Interval: &intervals[i]}
+	}
+
+	return s.write(records...)
+}
+
+// QueryHistory reads the most recently captured queries back from the file and its rotated files,
+// limited to a single query fingerprint when a checksum is given
+func (s *Sink) QueryHistory(checksum string, limit int) ([]types.QueryRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results, err := ReadFiles(Files(s.path)...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	records := []types.QueryRecord{}
+
+	for i := len(results.Queries) - 1; i >= 0 && len(records) < limit; i-- {
+		if checksum == "" || results.Queries[i].Checksum == checksum {
+			records = append(records, results.Queries[i])
+		}
+	}
+
+	return records, nil
+}
+
+// Close closes the file, if a result was saved to it
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	return s.file.Close()
+}
+
+// Results are the records of JSON Lines files, in the order they were written
+type Results struct {
+	Queries   []types.QueryRecord
+	Runs      []types.DigestReport
+	Intervals []types.DigestInterval
+}
+
+// Read reads the records of a JSON Lines stream into the results, reporting the line of a record
+// it cannot decode. Blank lines are skipped.
+func (res *Results) Read(r io.Reader, name string) error {
+	reader := bufio.NewReader(r)
+
+	for n := 1; ; n++ {
+		line, err := reader.ReadBytes('\n')
+
+		if len(strings.TrimSpace(string(line))) > 0 {
+			if err := res.add(line); err != nil {
+				return fmt.Errorf("could not read %s line %d\n%s", name, n, err)
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("could not read %s\n%s", name, err)
+		}
+	}
+}
+
+// add decodes a record into the results
+func (res *Results) add(line []byte) error {
+	var rec Record
+
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return err
+	}
+
+	switch {
+	case rec.Kind == KindPlan && rec.Query != nil:
+		res.Queries = append(res.Queries, *rec.Query)
+	case rec.Kind == KindRun && rec.Run != nil:
+		rec.Run.Source = rec.Source
+		rec.Run.Timestamp = rec.Time.Unix()
+		res.Runs = append(res.Runs, *rec.Run)
+	case rec.Kind == KindInterval && rec.Interval != nil:
+		res.Intervals = append(res.Intervals, *rec.Interval)
+	default:
+		return fmt.Errorf("unknown record kind %q", rec.Kind)
+	}
+
+	return nil
+}
+
+// ReadFiles reads the records of JSON Lines files, in order
+func ReadFiles(paths ...string) (*Results, error) {
+	res := &Results{}
+
+	for _, path := range paths {
+		f, err := os.Open(path)
+
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("could not open the results file %s\n%s", path, err)
+		}
+
+		err = res.Read(f, path)
+		f.Close()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// Files lists a results file after the files it was rotated to, from the oldest to the newest.
+// Only names holding a rotation time are listed, so results-old.jsonl is not read as rotated.
+func Files(path string) []string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	matches, _ := filepath.Glob(base + "-*" + ext)
+
+	type rotatedFile struct {
+		name  string
+		stamp string
+		seq   int
+	}
+
+	rotated := []rotatedFile{}
+
+	for _, match := range matches {
+		f := rotatedFile{name: match, stamp: strings.TrimSuffix(strings.TrimPrefix(match, base+"-"), ext)}
+
+		if i := strings.IndexByte(f.stamp, '.'); i >= 0 {
+			if _, err := fmt.Sscanf(f.stamp[i+1:], "%d", &f.seq); err != nil {
+				continue
+			}
+
+			f.stamp = f.stamp[:i]
+		}
+
+		if _, err := time.Parse(rotatedLayout, f.stamp); err == nil {
+			rotated = append(rotated, f)
+		}
+	}
+
+	sort.Slice(rotated, func(i, j int) bool {
+		if rotated[i].stamp != rotated[j].stamp {
+			return rotated[i].stamp < rotated[j].stamp
+		}
+
+		return rotated[i].seq < rotated[j].seq
+	})
+
+	files := []string{}
+
+	for _, f := range rotated {
+		files = append(files, f.name)
+	}
+
+	return append(files, path)
+}
+
+// CheckConnection reports whether results can be appended to a JSON Lines file, without creating
+// it if it does not exist
+func CheckConnection(path string) (*config.Health, error) {
+	stat := &config.Health{Service: "JSON Lines", Endpoint: path, TLS: "disabled"}
+	start := time.Now()
+
+	if err := writable(path); err != nil {
+		stat.Errors = append(stat.Errors, config.NewCheckError(config.ErrConnection, 1, err))
+		return stat, err
+	}
+
+	stat.Latency = time.Since(start)
+	stat.Connected = true
+
+	return stat, nil
+}
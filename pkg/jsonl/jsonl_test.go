@@ -0,0 +1,261 @@
+package jsonl
+
+import (
+	"bytes"
+	"encoding/json"
+	"gopherDigest/pkg/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tempSink(t *testing.T, rotation Rotation, now *time.Time) (*Sink, string, func()) {
+	dir, err := ioutil.TempDir("", "jsonl")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "results.jsonl")
+	s, err := Open(path, rotation)
+
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Open should not return an error, but got %s", err)
+	}
+
+	s.now = func() time.Time { return *now }
+
+	return s, path, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSink(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	s, path, cleanup := tempSink(t, Rotation{}, &now)
+	defer cleanup()
+
+	table, filtered := "employees", 100.0
+	plan := &types.ExplainPlan{QueryBlock: types.QueryBlock{SelectID: 1, CostInfo: &types.CostInfo{QueryCost: 12.5}}}
+
+	for _, rec := range []types.QueryRecord{
+		{Search: "SELECT * FROM employees", Fingerprint: "select * from employees", Checksum: "A",
+			SQLExplainRows: []types.SQLExplainRow{{ID: 1, Table: &table, Rows: 10, Filtered: &filtered}}, ExplainPlan: plan,
+			Metrics: &types.QueryMetrics{Digest: "d1", QueryTime: 0.0025, RowsSent: 10, RowsExamined: 10, DigestCounters: types.DigestCounters{CountStar: 4}}},
+		{Search: "SELECT 1", Fingerprint: "select ?", Checksum: "B"},
+		{Search: "SELECT 2", Fingerprint: "select ?", Checksum: "B"},
+	} {
+		if err := s.SavePlan(rec); err != nil {
+			t.Fatalf("SavePlan should not return an error, but got %s", err)
+		}
+	}
+
+	report := types.DigestReport{Source: "slow.log", Classes: []types.DigestClass{{Checksum: "A", QueryCount: 3}}}
+
+	if err := s.SaveRun(report); err != nil {
+		t.Fatalf("SaveRun should not return an error, but got %s", err)
+	}
+
+	intervals := []types.DigestInterval{
+		{Schema: "employees", Digest: "d1", End: now, DigestCounters: types.DigestCounters{CountStar: 5, SumTimerWait: 100}},
+		{Schema: "employees", Digest: "d2", End: now, Reset: true},
+	}
+
+	if err := s.SaveDigestSnapshot(intervals); err != nil {
+		t.Fatalf("SaveDigestSnapshot should not return an error, but got %s", err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+
+	if len(lines) != 6 {
+		t.Fatalf("the file should hold one line per record, but got %d lines", len(lines))
+	}
+
+	var line map[string]interface{}
+
+	if err := json.Unmarshal([]byte(lines[0]), &line); err != nil || line["kind"] != KindPlan || line["query"].(map[string]interface{})["query"] != "SELECT * FROM employees" {
+		t.Errorf("a plan record should hold the query under query.query, but got %s", lines[0])
+	}
+
+	if !strings.Contains(lines[0], `"filtered":100,`) {
+		t.Errorf("a plan record should hold the filtered percentage of a plan row as a number, but got %s", lines[0])
+	}
+
+	if !strings.Contains(lines[0], `"metrics":{"digest":"d1","query_time":0.0025,"lock_time":0,"rows_sent":10,"rows_examined":10,"count_star":4,`) {
+		t.Errorf("a plan record should hold the metrics of the query, but got %s", lines[0])
+	}
+
+	res, err := ReadFiles(path)
+
+	if err != nil {
+		t.Fatalf("ReadFiles should not return an error, but got %s", err)
+	}
+
+	if len(res.Queries) != 3 || !reflect.DeepEqual(res.Queries[0].ExplainPlan, plan) || res.Queries[0].Metrics.RowsExamined != 10 || !res.Queries[0].QueryTime.Equal(now) || res.Queries[0].Timestamp != now.Unix() {
+		t.Errorf("ReadFiles should read the queries back with their plans and timestamps, but got %+v", res.Queries)
+	}
+
+	if len(res.Runs) != 1 || res.Runs[0].Source != "slow.log" || res.Runs[0].Timestamp != now.Unix() || res.Runs[0].Classes[0].QueryCount != 3 {
+		t.Errorf("ReadFiles should read the pt-query-digest run back with its source, but got %+v", res.Runs)
+	}
+
+	if len(res.Intervals) != 2 || res.Intervals[0].CountStar != 5 || !res.Intervals[1].Reset {
+		t.Errorf("ReadFiles should read the digest intervals back with their metrics, but got %+v", res.Intervals)
+	}
+
+	history, err := s.QueryHistory("B", 1)
+
+	if err != nil || len(history) != 1 || history[0].Search != "SELECT 2" {
+		t.Errorf("QueryHistory should return the newest query with checksum B, but got %+v, %v", history, err)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	s, path, cleanup := tempSink(t, Rotation{MaxSize: 400, Daily: true}, &now)
+	defer cleanup()
+
+	save := func(query string) {
+		if err := s.SavePlan(types.QueryRecord{Search: query, Checksum: "A"}); err != nil {
+			t.Fatalf("SavePlan should not return an error, but got %s", err)
+		}
+	}
+
+	// each record is about 190 bytes, so the third one exceeds the size and rotates the file
+	save("SELECT 1")
+	save("SELECT 2")
+	save("SELECT 3")
+
+	now = now.Add(24 * time.Hour)
+	save("SELECT 4")
+
+	files := Files(path)
+	expected := []string{
+		filepath.Join(filepath.Dir(path), "results-20261018T120000.jsonl"),
+		filepath.Join(filepath.Dir(path), "results-20261019T120000.jsonl"),
+		path,
+	}
+
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("the file should be rotated by size and then by day to %v, but got %v", expected, files)
+	}
+
+	for _, f := range files {
+		if info, err := os.Stat(f); err != nil || info.Size() > 400 {
+			t.Errorf("every file should hold at most 400 bytes, but %s holds %v", f, info)
+		}
+	}
+
+	history, err := s.QueryHistory("", 10)
+
+	if err != nil || len(history) != 4 || history[0].Search != "SELECT 4" || history[3].Search != "SELECT 1" {
+		t.Errorf("QueryHistory should read every rotated file, newest first, but got %+v, %v", history, err)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonl")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"results-20261018T120000.1.jsonl", "results-20261018T120000.jsonl", "results-20261017T090000.jsonl", "results-old.jsonl"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(dir, "results.jsonl")
+	expected := []string{
+		filepath.Join(dir, "results-20261017T090000.jsonl"),
+		filepath.Join(dir, "results-20261018T120000.jsonl"),
+		filepath.Join(dir, "results-20261018T120000.1.jsonl"),
+		path,
+	}
+
+	if files := Files(path); !reflect.DeepEqual(files, expected) {
+		t.Errorf("Files should list the rotated files oldest first, then the file, as %v, but got %v", expected, files)
+	}
+}
+
+func TestRead(t *testing.T) {
+	res := &Results{}
+	input := `{"kind":"plan","time":"2026-10-18T12:00:00Z","query":{"query":"SELECT 1","checksum":"A"}}
+
+{"kind":"plan","time":"2026-10-18T12:00:00Z","query":{"query":"SELECT 2"`
+
+	err := res.Read(bytes.NewBufferString(input), "results.jsonl")
+
+	if err == nil || !strings.Contains(err.Error(), "results.jsonl line 3") {
+		t.Errorf("Read should report the truncated line 3, but got %v", err)
+	}
+
+	if len(res.Queries) != 1 || res.Queries[0].Search != "SELECT 1" {
+		t.Errorf("Read should keep the records before the error, but got %+v", res.Queries)
+	}
+
+	if err := res.Read(bytes.NewBufferString(`{"kind":"sample"}`), "results.jsonl"); err == nil || !strings.Contains(err.Error(), `unknown record kind "sample"`) {
+		t.Errorf("Read should report an unknown record kind, but got %v", err)
+	}
+}
+
+func TestCheckConnection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonl")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "results.jsonl")
+
+	if stat, err := CheckConnection(path); err != nil || !stat.Connected {
+		t.Fatalf("CheckConnection should report a file in a writable directory as connected, but got %+v, %v", stat, err)
+	}
+
+	s, err := Open(path, Rotation{})
+
+	if err != nil {
+		t.Fatalf("Open should not return an error, but got %s", err)
+	}
+
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("CheckConnection and Open should not create any file before a result is saved, but found %d", len(entries))
+	}
+
+	if err := s.SavePlan(types.QueryRecord{Search: "SELECT 1"}); err != nil {
+		t.Fatalf("SavePlan should not return an error, but got %s", err)
+	}
+
+	s.Close()
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("SavePlan should create the file, but got %s", err)
+	}
+
+	missing := filepath.Join(dir, "missing", "results.jsonl")
+
+	if stat, err := CheckConnection(missing); err == nil || stat.Connected {
+		t.Errorf("CheckConnection should report a file in a missing directory as not connected, but got %+v", stat)
+	}
+
+	if _, err := Open(missing, Rotation{}); err == nil {
+		t.Errorf("Open should return an error for a file in a missing directory")
+	}
+}
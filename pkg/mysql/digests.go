@@ -0,0 +1,260 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gopherDigest/pkg/types"
+	"strconv"
+	"time"
+)
+
+// digestSummaryQuery reads the cumulative counters of every statement digest. The columns
+// are common to MySQL 5.6 and later.
+const digestSummaryQuery = `
+	SELECT SCHEMA_NAME, DIGEST, DIGEST_TEXT, COUNT_STAR, SUM_TIMER_WAIT, SUM_LOCK_TIME,
+		SUM_ERRORS, SUM_WARNINGS, SUM_ROWS_AFFECTED, SUM_ROWS_SENT, SUM_ROWS_EXAMINED,
+		SUM_CREATED_TMP_DISK_TABLES, SUM_CREATED_TMP_TABLES, SUM_SELECT_FULL_JOIN,
+		SUM_SELECT_FULL_RANGE_JOIN, SUM_SELECT_RANGE, SUM_SELECT_RANGE_CHECK, SUM_SELECT_SCAN,
+		SUM_SORT_MERGE_PASSES, SUM_SORT_RANGE, SUM_SORT_ROWS, SUM_SORT_SCAN,
+		SUM_NO_INDEX_USED, SUM_NO_GOOD_INDEX_USED, FIRST_SEEN, LAST_SEEN
+	FROM performance_schema.events_statements_summary_by_digest`
+
+// queryMetricsQuery reads the latest execution of a statement in the statement history of every
+// thread, with the cumulative counters of its digest
+const queryMetricsQuery = `
+	SELECT esh.DIGEST, IFNULL(esh.TIMER_WAIT, 0), IFNULL(esh.LOCK_TIME, 0), esh.ROWS_SENT, esh.ROWS_EXAMINED,
+		essbd.COUNT_STAR, essbd.SUM_TIMER_WAIT, essbd.SUM_LOCK_TIME, essbd.SUM_ERRORS, essbd.SUM_WARNINGS,
+		essbd.SUM_ROWS_AFFECTED, essbd.SUM_ROWS_SENT, essbd.SUM_ROWS_EXAMINED,
+		essbd.SUM_CREATED_TMP_DISK_TABLES, essbd.SUM_CREATED_TMP_TABLES, essbd.SUM_SELECT_FULL_JOIN,
+		essbd.SUM_SELECT_FULL_RANGE_JOIN, essbd.SUM_SELECT_RANGE, essbd.SUM_SELECT_RANGE_CHECK,
+		essbd.SUM_SELECT_SCAN, essbd.SUM_SORT_MERGE_PASSES, essbd.SUM_SORT_RANGE, essbd.SUM_SORT_ROWS,
+		essbd.SUM_SORT_SCAN, essbd.SUM_NO_INDEX_USED, essbd.SUM_NO_GOOD_INDEX_USED
+	FROM performance_schema.events_statements_history esh
+	INNER JOIN performance_schema.events_statements_summary_by_digest essbd
+		ON essbd.DIGEST = esh.DIGEST AND essbd.SCHEMA_NAME <=> esh.CURRENT_SCHEMA
+	WHERE esh.SQL_TEXT = ?
+	ORDER BY esh.TIMER_END DESC LIMIT 1`
+
+// picoseconds are the unit of the performance_schema timers
+const picoseconds = 1e12
+
+// seenLayout is the format of the FIRST_SEEN and LAST_SEEN columns
+const seenLayout = "2006-01-02 15:04:05.999999"
+
+// DigestCollector periodically snapshots the statement digest summary and reports the
+// load of each digest between consecutive snapshots
+type DigestCollector struct {
+	db   *sql.DB
+	prev *types.DigestSnapshot
+}
+
+// NewDigestCollector creates a collector reading the digest summary of a database
+func NewDigestCollector(db *sql.DB) *DigestCollector {
+	return &DigestCollector{db: db}
+}
+
+// Collect snapshots the digest summary and returns each digest's load since the previous
+// snapshot. The first call only records a baseline and returns no intervals.
+func (c *DigestCollector) Collect() ([]types.DigestInterval, error) {
+	snap, err := SnapshotDigests(c.db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	prev := c.prev
+	c.prev = snap
+
+	if prev == nil {
+		return []types.DigestInterval{}, nil
+	}
+
+	return DigestDeltas(prev, snap), nil
+}
+
+// Run collects the digest load every interval until the context is done, passing the
+// intervals of each collection to store
+func (c *DigestCollector) Run(ctx context.Context, every time.Duration, store func([]types.DigestInterval) error) error {
+	if _, err := c.Collect(); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			intervals, err := c.Collect()
+
+			if err != nil {
+				return err
+			}
+
+			if err := store(intervals); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// SnapshotDigests reads the server's uptime and every row of the statement digest summary
+func SnapshotDigests(db *sql.DB) (*types.DigestSnapshot, error) {
+	snap := &types.DigestSnapshot{Time: time.Now().UTC(), Digests: []types.StatementDigest{}}
+
+	var name, uptime string
+
+	if err := db.QueryRow("SHOW GLOBAL STATUS LIKE 'Uptime'").Scan(&name, &uptime); err != nil {
+		return nil, fmt.Errorf("could not read the MySQL server uptime\n%s", err)
+	}
+
+	snap.Uptime, _ = strconv.ParseInt(uptime, 10, 64)
+
+	rows, err := db.Query(digestSummaryQuery)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read the statement digest summary\n%s", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var d types.StatementDigest
+		var schema, digest, text sql.NullString
+		var firstSeen, lastSeen string
+
+		dest := append([]interface{}{&schema, &digest, &text}, counterFields(&d.DigestCounters)...)
+		err := rows.Scan(append(dest, &firstSeen, &lastSeen)...)
+
+		if err != nil {
+			return nil, fmt.Errorf("could not scan the statement digest summary\n%s", err)
+		}
+
+		d.Schema, d.Digest, d.DigestText = schema.String, digest.String, text.String
+
+		if d.FirstSeen, err = time.Parse(seenLayout, firstSeen); err != nil {
+			return nil, fmt.Errorf("could not parse FIRST_SEEN of digest %s\n%s", d.Digest, err)
+		}
+
+		if d.LastSeen, err = time.Parse(seenLayout, lastSeen); err != nil {
+			return nil, fmt.Errorf("could not parse LAST_SEEN of digest %s\n%s", d.Digest, err)
+		}
+
+		snap.Digests = append(snap.Digests, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read the statement digest summary\n%s", err)
+	}
+
+	return snap, nil
+}
+
+// counterFields returns the scan destinations of the digest counters, in the order of their
+// columns in the digest summary
+func counterFields(c *types.DigestCounters) []interface{} {
+	return []interface{}{&c.CountStar, &c.SumTimerWait, &c.SumLockTime,
+		&c.SumErrors, &c.SumWarnings, &c.SumRowsAffected, &c.SumRowsSent, &c.SumRowsExamined,
+		&c.SumCreatedTmpDiskTables, &c.SumCreatedTmpTables, &c.SumSelectFullJoin,
+		&c.SumSelectFullRangeJoin, &c.SumSelectRange, &c.SumSelectRangeCheck, &c.SumSelectScan,
+		&c.SumSortMergePasses, &c.SumSortRange, &c.SumSortRows, &c.SumSortScan,
+		&c.SumNoIndexUsed, &c.SumNoGoodIndexUsed}
+}
+
+// FetchQueryMetrics reads the time and rows of the latest execution of a query, and the counters
+// of its digest, returning nil when the query is no longer in the statement history
+func FetchQueryMetrics(db *sql.DB, query string) (*types.QueryMetrics, error) {
+	m := &types.QueryMetrics{}
+	var timerWait, lockTime uint64
+
+	dest := append([]interface{}{&m.Digest, &timerWait, &lockTime, &m.RowsSent, &m.RowsExamined}, counterFields(&m.DigestCounters)...)
+	err := db.QueryRow(queryMetricsQuery, query).Scan(dest...)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read the metrics of the query %s\n%s", query, err)
+	}
+
+	m.QueryTime, m.LockTime = float64(timerWait)/picoseconds, float64(lockTime)/picoseconds
+
+	return m, nil
+}
+
+// DigestDeltas computes the load of every digest executed between two snapshots. A digest's
+// counters are treated as reset when the server restarted, when the summary table was
+// truncated or the digest was evicted and re-added (its FIRST_SEEN changed), or when its
+// count went down. Digests that were not executed during the interval are left out.
+func DigestDeltas(prev, cur *types.DigestSnapshot) []types.DigestInterval {
+	intervals := []types.DigestInterval{}
+	restarted := cur.Uptime < prev.Uptime
+	previous := map[string]types.StatementDigest{}
+
+	for _, d := range prev.Digests {
+		previous[d.Schema+"/"+d.Digest] = d
+	}
+
+	for _, d := range cur.Digests {
+		iv := types.DigestInterval{
+			Schema: d.Schema, Digest: d.Digest, DigestText: d.DigestText, Start: prev.Time, End: cur.Time,
+		}
+
+		p, ok := previous[d.Schema+"/"+d.Digest]
+
+		switch {
+		case restarted || (ok && (!d.FirstSeen.Equal(p.FirstSeen) || d.CountStar < p.CountStar)):
+			iv.DigestCounters = d.DigestCounters
+			iv.Reset = true
+		case ok:
+			iv.DigestCounters = d.DigestCounters.Sub(p.DigestCounters)
+		default:
+			// digests first seen during the interval were executed entirely within it
+			iv.DigestCounters = d.DigestCounters
+		}
+
+		if iv.CountStar > 0 {
+			intervals = append(intervals, iv)
+		}
+	}
+
+	return intervals
+}
+
+// DigestBaselines sums the intervals of every statement digest into a named baseline, spanning
+// from the start of its first interval to the end of its last, in the order digests were first
+// collected
+func DigestBaselines(name string, intervals []types.DigestInterval) []types.Baseline {
+	baselines := []types.Baseline{}
+	index := map[[2]string]int{}
+
+	for _, iv := range intervals {
+		key := [2]string{iv.Schema, iv.Digest}
+		i, ok := index[key]
+
+		if !ok {
+			index[key] = len(baselines)
+			baselines = append(baselines, types.Baseline{
+				Name: name, Schema: iv.Schema, Digest: iv.Digest, DigestText: iv.DigestText, Start: iv.Start, End: iv.End,
+			})
+			i = len(baselines) - 1
+		}
+
+		b := &baselines[i]
+		b.DigestCounters = b.DigestCounters.Add(iv.DigestCounters)
+
+		if iv.Start.Before(b.Start) {
+			b.Start = iv.Start
+		}
+
+		if iv.End.After(b.End) {
+			b.End = iv.End
+		}
+	}
+
+	return baselines
+}
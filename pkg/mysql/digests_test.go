@@ -0,0 +1,209 @@
+package mysql
+
+import (
+	"database/sql/driver"
+	"gopherDigest/pkg/mysql/mysqltest"
+	"gopherDigest/pkg/types"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// digestColumns are the columns selected by digestSummaryQuery
+var digestColumns = []string{"SCHEMA_NAME", "DIGEST", "DIGEST_TEXT", "COUNT_STAR", "SUM_TIMER_WAIT", "SUM_LOCK_TIME",
+	"SUM_ERRORS", "SUM_WARNINGS", "SUM_ROWS_AFFECTED", "SUM_ROWS_SENT", "SUM_ROWS_EXAMINED",
+	"SUM_CREATED_TMP_DISK_TABLES", "SUM_CREATED_TMP_TABLES", "SUM_SELECT_FULL_JOIN",
+	"SUM_SELECT_FULL_RANGE_JOIN", "SUM_SELECT_RANGE", "SUM_SELECT_RANGE_CHECK", "SUM_SELECT_SCAN",
+	"SUM_SORT_MERGE_PASSES", "SUM_SORT_RANGE", "SUM_SORT_ROWS", "SUM_SORT_SCAN",
+	"SUM_NO_INDEX_USED", "SUM_NO_GOOD_INDEX_USED", "FIRST_SEEN", "LAST_SEEN"}
+
+// digestRow builds a digest summary row as the MySQL driver would return it
+func digestRow(digest string, count, timerWait, rowsExamined, noIndexUsed int64, firstSeen string) []driver.Value {
+	row := []driver.Value{[]byte("employees"), []byte(digest), []byte("SELECT * FROM `employees` WHERE `emp_no` = ?"),
+		count, timerWait, int64(0)}
+
+	for i := 0; i < 18; i++ {
+		row = append(row, int64(0))
+	}
+
+	row[10], row[22] = rowsExamined, noIndexUsed
+
+	return append(row, []byte(firstSeen), []byte("2023-11-02 15:00:00.000000"))
+}
+
+func snapshot(at time.Time, uptime int64, digests ...types.StatementDigest) *types.DigestSnapshot {
+	return &types.DigestSnapshot{Time: at, Uptime: uptime, Digests: digests}
+}
+
+func statementDigest(digest string, count, rowsExamined uint64, firstSeen time.Time) types.StatementDigest {
+	return types.StatementDigest{
+		Schema: "employees", Digest: digest, FirstSeen: firstSeen,
+		DigestCounters: types.DigestCounters{CountStar: count, SumTimerWait: count * 1000, SumRowsExamined: rowsExamined},
+	}
+}
+
+func TestDigestDeltas(t *testing.T) {
+	t0 := time.Date(2023, 11, 2, 14, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Minute)
+	seen := t0.Add(-time.Hour)
+	reseen := t0.Add(30 * time.Second)
+
+	tt := []struct {
+		name     string
+		prev     *types.DigestSnapshot
+		cur      *types.DigestSnapshot
+		expected []types.DigestInterval
+	}{
+		{"Growth", snapshot(t0, 100, statementDigest("a", 10, 100, seen)), snapshot(t1, 160, statementDigest("a", 15, 180, seen)),
+			[]types.DigestInterval{{Schema: "employees", Digest: "a", Start: t0, End: t1,
+				DigestCounters: types.DigestCounters{CountStar: 5, SumTimerWait: 5000, SumRowsExamined: 80}}}},
+		{"Idle Digest", snapshot(t0, 100, statementDigest("a", 10, 100, seen)), snapshot(t1, 160, statementDigest("a", 10, 100, seen)),
+			[]types.DigestInterval{}},
+		{"New Digest", snapshot(t0, 100), snapshot(t1, 160, statementDigest("b", 2, 20, reseen)),
+			[]types.DigestInterval{{Schema: "employees", Digest: "b", Start: t0, End: t1,
+				DigestCounters: types.DigestCounters{CountStar: 2, SumTimerWait: 2000, SumRowsExamined: 20}}}},
+		{"Truncated", snapshot(t0, 100, statementDigest("a", 10, 100, seen)), snapshot(t1, 160, statementDigest("a", 12, 30, reseen)),
+			[]types.DigestInterval{{Schema: "employees", Digest: "a", Start: t0, End: t1, Reset: true,
+				DigestCounters: types.DigestCounters{CountStar: 12, SumTimerWait: 12000, SumRowsExamined: 30}}}},
+		{"Count Decreased", snapshot(t0, 100, statementDigest("a", 10, 100, seen)), snapshot(t1, 160, statementDigest("a", 3, 30, seen)),
+			[]types.DigestInterval{{Schema: "employees", Digest: "a", Start: t0, End: t1, Reset: true,
+				DigestCounters: types.DigestCounters{CountStar: 3, SumTimerWait: 3000, SumRowsExamined: 30}}}},
+		{"Server Restart", snapshot(t0, 100, statementDigest("a", 10, 100, seen)), snapshot(t1, 20, statementDigest("a", 11, 110, seen)),
+			[]types.DigestInterval{{Schema: "employees", Digest: "a", Start: t0, End: t1, Reset: true,
+				DigestCounters: types.DigestCounters{CountStar: 11, SumTimerWait: 11000, SumRowsExamined: 110}}}},
+		{"Evicted Digest", snapshot(t0, 100, statementDigest("a", 10, 100, seen), statementDigest("b", 1, 1, seen)),
+			snapshot(t1, 160, statementDigest("a", 11, 101, seen)),
+			[]types.DigestInterval{{Schema: "employees", Digest: "a", Start: t0, End: t1,
+				DigestCounters: types.DigestCounters{CountStar: 1, SumTimerWait: 1000, SumRowsExamined: 1}}}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := DigestDeltas(tc.prev, tc.cur)
+
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("DigestDeltas should be\n%+v\nbut got\n%+v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDigestCollector(t *testing.T) {
+	uptime := mysqltest.Response{Columns: []string{"Variable_name", "Value"}, Rows: [][]driver.Value{{[]byte("Uptime"), []byte("3600")}}}
+
+	db, srv := mysqltest.NewDB(t, map[string]mysqltest.Response{
+		"SHOW GLOBAL STATUS LIKE 'Uptime'": uptime,
+		digestSummaryQuery: {Columns: digestColumns, Rows: [][]driver.Value{
+			digestRow("a1", 10, 5000, 100, 0, "2023-11-02 14:00:00.123456"),
+		}},
+	})
+
+	defer db.Close()
+
+	c := NewDigestCollector(db)
+
+	if intervals, err := c.Collect(); err != nil || len(intervals) != 0 {
+		t.Fatalf("the first Collect should only record a baseline, but got %+v, %v", intervals, err)
+	}
+
+	srv.SetResponse(digestSummaryQuery, mysqltest.Response{Columns: digestColumns, Rows: [][]driver.Value{
+		digestRow("a1", 14, 9000, 180, 4, "2023-11-02 14:00:00.123456"),
+		{nil, nil, nil, int64(3), int64(300), int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), int64(0),
+			int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), int64(0),
+			[]byte("2023-11-02 14:30:00.000000"), []byte("2023-11-02 15:00:00.000000")},
+	}})
+
+	intervals, err := c.Collect()
+
+	if err != nil {
+		t.Fatalf("Collect should not return an error, but got %s", err)
+	}
+
+	if len(intervals) != 2 {
+		t.Fatalf("Collect should return 2 intervals, but got %+v", intervals)
+	}
+
+	a := intervals[0]
+
+	if a.Digest != "a1" || a.Reset || a.CountStar != 4 || a.SumTimerWait != 4000 || a.SumRowsExamined != 80 || a.SumNoIndexUsed != 4 {
+		t.Errorf("Collect should return the delta of digest a1, but got %+v", a)
+	}
+
+	if overflow := intervals[1]; overflow.Digest != "" || overflow.CountStar != 3 {
+		t.Errorf("Collect should return the NULL digest of statements beyond the table's size, but got %+v", overflow)
+	}
+
+	if a.End.Before(a.Start) {
+		t.Errorf("an interval should end after it starts, but got %s to %s", a.Start, a.End)
+	}
+}
+
+func TestSnapshotDigestsError(t *testing.T) {
+	db, _ := mysqltest.NewDB(t, map[string]mysqltest.Response{
+		"SHOW GLOBAL STATUS LIKE 'Uptime'": {Columns: []string{"Variable_name", "Value"}, Rows: [][]driver.Value{{[]byte("Uptime"), []byte("1")}}},
+		digestSummaryQuery: {Columns: digestColumns, Rows: [][]driver.Value{
+			digestRow("a1", 1, 1, 1, 0, "yesterday"),
+		}},
+	})
+
+	defer db.Close()
+
+	if _, err := SnapshotDigests(db); err == nil {
+		t.Errorf("SnapshotDigests should return an error for an unparseable FIRST_SEEN")
+	}
+}
+
+func TestFetchQueryMetrics(t *testing.T) {
+	query := "SELECT * FROM employees WHERE emp_no = 10001"
+	row := append([]driver.Value{[]byte("d1"), int64(2500000000), int64(1000000), int64(1), int64(1)}, digestRow("d1", 4, 10000000000, 4, 0, "")[3:24]...)
+
+	db, srv := mysqltest.NewDB(t, map[string]mysqltest.Response{
+		queryMetricsQuery: {Columns: make([]string, len(row)), Rows: [][]driver.Value{row}},
+	})
+
+	defer db.Close()
+
+	m, err := FetchQueryMetrics(db, query)
+
+	if err != nil {
+		t.Fatalf("FetchQueryMetrics should not return an error, but got %s", err)
+	}
+
+	expected := &types.QueryMetrics{Digest: "d1", QueryTime: 0.0025, LockTime: 0.000001, RowsSent: 1, RowsExamined: 1,
+		DigestCounters: types.DigestCounters{CountStar: 4, SumTimerWait: 10000000000, SumRowsExamined: 4}}
+
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("FetchQueryMetrics should read the latest execution of the query and its digest, expected %+v, but got %+v", expected, m)
+	}
+
+	srv.SetResponse(queryMetricsQuery, mysqltest.Response{Columns: make([]string, len(row))})
+
+	if m, err := FetchQueryMetrics(db, query); m != nil || err != nil {
+		t.Errorf("FetchQueryMetrics should return nil for a query no longer in the statement history, but got %+v, %v", m, err)
+	}
+}
+
+func TestDigestBaselines(t *testing.T) {
+	t0 := time.Date(2023, 11, 2, 14, 0, 0, 0, time.UTC)
+	interval := func(schema, digest string, start time.Time, count uint64) types.DigestInterval {
+		return types.DigestInterval{Schema: schema, Digest: digest, Start: start, End: start.Add(time.Minute),
+			DigestCounters: types.DigestCounters{CountStar: count, SumTimerWait: count * 1000}}
+	}
+
+	baselines := DigestBaselines("before", []types.DigestInterval{
+		interval("employees", "d1", t0, 2),
+		interval("employees", "d2", t0, 1),
+		interval("employees", "d1", t0.Add(time.Minute), 3),
+		interval("salaries", "d1", t0.Add(time.Minute), 4),
+	})
+
+	expected := []types.Baseline{
+		{Name: "before", Schema: "employees", Digest: "d1", Start: t0, End: t0.Add(2 * time.Minute), DigestCounters: types.DigestCounters{CountStar: 5, SumTimerWait: 5000}},
+		{Name: "before", Schema: "employees", Digest: "d2", Start: t0, End: t0.Add(time.Minute), DigestCounters: types.DigestCounters{CountStar: 1, SumTimerWait: 1000}},
+		{Name: "before", Schema: "salaries", Digest: "d1", Start: t0.Add(time.Minute), End: t0.Add(2 * time.Minute), DigestCounters: types.DigestCounters{CountStar: 4, SumTimerWait: 4000}},
+	}
+
+	if !reflect.DeepEqual(baselines, expected) {
+		t.Errorf("DigestBaselines should sum the intervals of each digest of each schema, expected %+v, but got %+v", expected, baselines)
+	}
+}
@@ -0,0 +1,195 @@
+package mysql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"gopherDigest/pkg/types"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// analyzeLine matches an EXPLAIN ANALYZE iterator with its optional estimates and measurements
+var analyzeLine = regexp.MustCompile(`^(.*?)` +
+	`(?:\s+\(cost=(?:[^ )]+?\.\.)?([^ )]+) rows=([^ )]+)\))?` +
+	`(?:\s+\((?:actual time=([^ )]+?)\.\.([^ )]+) rows=([^ )]+) loops=([^ )]+)|(never executed))\))?$`)
+
+// serverVersion matches the major, minor and patch numbers of a MySQL version string
+var serverVersion = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)`)
+
+// ExplainJSON runs a MySQL EXPLAIN FORMAT=JSON statement on a given query and
+// parses the resulting execution plan tree
+func ExplainJSON(db *sql.DB, query string) (*types.ExplainPlan, error) {
+	var doc []byte
+
+	if err := db.QueryRow("EXPLAIN FORMAT=JSON " + query).Scan(&doc); err != nil {
+		return nil, fmt.Errorf("could not run EXPLAIN FORMAT=JSON on the query\n%s", err)
+	}
+
+	return ParseExplainJSON(doc)
+}
+
+// ParseExplainJSON parses an EXPLAIN FORMAT=JSON document into an execution plan
+func ParseExplainJSON(doc []byte) (*types.ExplainPlan, error) {
+	plan := &types.ExplainPlan{}
+
+	if err := json.Unmarshal(doc, plan); err != nil {
+		return nil, fmt.Errorf("could not parse the JSON execution plan\n%s", err)
+	}
+
+	return plan, nil
+}
+
+// ExplainAnalyze runs a MySQL EXPLAIN ANALYZE statement on a given query, parses
+// the resulting iterator tree and flags nodes whose row estimates are off by more than a factor.
+// EXPLAIN ANALYZE executes the query and requires MySQL 8.0.18 or later.
+func ExplainAnalyze(db *sql.DB, query string, factor float64) (*types.AnalyzeNode, error) {
+	var version, tree string
+
+	if err := db.QueryRow("SELECT VERSION()").Scan(&version); err != nil {
+		return nil, fmt.Errorf("could not determine the MySQL server version\n%s", err)
+	}
+
+	if !SupportsExplainAnalyze(version) {
+		return nil, fmt.Errorf("EXPLAIN ANALYZE requires MySQL 8.0.18 or later, but the server is running %s", version)
+	}
+
+	if err := db.QueryRow("EXPLAIN ANALYZE " + query).Scan(&tree); err != nil {
+		return nil, fmt.Errorf("could not run EXPLAIN ANALYZE on the query\n%s", err)
+	}
+
+	root, err := ParseExplainAnalyze(tree)
+
+	if err != nil {
+		return nil, err
+	}
+
+	root.FlagMisestimates(factor)
+
+	return root, nil
+}
+
+// SupportsExplainAnalyze determines whether a MySQL server version supports EXPLAIN ANALYZE
+func SupportsExplainAnalyze(version string) bool {
+	if strings.Contains(strings.ToLower(version), "mariadb") {
+		return false
+	}
+
+	m := serverVersion.FindStringSubmatch(version)
+
+	if m == nil {
+		return false
+	}
+
+	v := [3]int{}
+
+	for i := range v {
+		v[i], _ = strconv.Atoi(m[i+1])
+	}
+
+	if v[0] != 8 {
+		return v[0] > 8
+	}
+
+	return v[1] > 0 || v[2] >= 18
+}
+
+// ParseExplainAnalyze parses the iterator tree text printed by EXPLAIN ANALYZE
+func ParseExplainAnalyze(tree string) (*types.AnalyzeNode, error) {
+	type level struct {
+		indent int
+		node   *types.AnalyzeNode
+	}
+
+	var root *types.AnalyzeNode
+	stack := []level{}
+
+	for i, line := range strings.Split(tree, "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+
+		if strings.TrimSpace(trimmed) == "" {
+			continue
+		}
+
+		// long conditions may wrap onto lines without an iterator arrow
+		if !strings.HasPrefix(trimmed, "-> ") {
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("could not parse line %d of the EXPLAIN ANALYZE tree %q", i+1, line)
+			}
+
+			last := stack[len(stack)-1].node
+			last.Operation += " " + strings.TrimSpace(trimmed)
+			continue
+		}
+
+		node, err := parseAnalyzeNode(strings.TrimPrefix(strings.TrimSpace(trimmed), "-> "))
+
+		if err != nil {
+			return nil, fmt.Errorf("could not parse line %d of the EXPLAIN ANALYZE tree\n%s", i+1, err)
+		}
+
+		indent := len(line) - len(trimmed)
+
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+
+		if len(stack) == 0 {
+			if root != nil {
+				return nil, fmt.Errorf("the EXPLAIN ANALYZE tree has more than one root at line %d", i+1)
+			}
+
+			root = node
+		} else {
+			parent := stack[len(stack)-1].node
+			parent.Children = append(parent.Children, node)
+		}
+
+		stack = append(stack, level{indent: indent, node: node})
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("the EXPLAIN ANALYZE tree is empty")
+	}
+
+	return root, nil
+}
+
+// parseAnalyzeNode parses a single iterator line, without its arrow, into a tree node
+func parseAnalyzeNode(line string) (*types.AnalyzeNode, error) {
+	m := analyzeLine.FindStringSubmatch(line)
+
+	if m == nil {
+		return nil, fmt.Errorf("unrecognized iterator %q", line)
+	}
+
+	node := &types.AnalyzeNode{Operation: strings.TrimSpace(m[1])}
+
+	var err error
+	parse := func(s string) float64 {
+		f, perr := strconv.ParseFloat(s, 64)
+
+		if perr != nil && err == nil {
+			err = fmt.Errorf("invalid number %q in iterator %q", s, line)
+		}
+
+		return f
+	}
+
+	if m[2] != "" {
+		node.HasEstimate = true
+		node.EstimatedCost = parse(m[2])
+		node.EstimatedRows = parse(m[3])
+	}
+
+	if m[4] != "" {
+		node.Executed = true
+		node.ActualFirstRow = parse(m[4])
+		node.ActualLastRow = parse(m[5])
+		node.ActualRows = parse(m[6])
+		node.Loops = int64(parse(m[7]))
+	}
+
+	return node, err
+}
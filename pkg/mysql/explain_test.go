@@ -0,0 +1,239 @@
+package mysql
+
+import (
+	"gopherDigest/pkg/types"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestParseExplainJSON(t *testing.T) {
+	doc, err := ioutil.ReadFile("testdata/explain_join.json")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := ParseExplainJSON(doc)
+
+	if err != nil {
+		t.Fatalf("ParseExplainJSON should not return an error, but got %s", err)
+	}
+
+	if plan.QueryBlock.SelectID != 1 || plan.QueryBlock.CostInfo.QueryCost != 4028329.98 {
+		t.Errorf("ParseExplainJSON should parse the query block cost, but got %+v", plan.QueryBlock.CostInfo)
+	}
+
+	if len(plan.QueryBlock.NestedLoop) != 3 {
+		t.Fatalf("ParseExplainJSON should parse 3 nested loop tables, but got %d", len(plan.QueryBlock.NestedLoop))
+	}
+
+	e := plan.QueryBlock.NestedLoop[1].Table
+
+	expected := types.PlanTable{
+		TableName:           "e",
+		AccessType:          "eq_ref",
+		PossibleKeys:        []string{"PRIMARY"},
+		Key:                 "PRIMARY",
+		UsedKeyParts:        []string{"emp_no"},
+		KeyLength:           "4",
+		Ref:                 []string{"employees.s.emp_no"},
+		RowsExaminedPerScan: 1,
+		RowsProducedPerJoin: 2838426,
+		Filtered:            100,
+		CostInfo: &types.CostInfo{
+			ReadCost:        709606.50,
+			EvalCost:        283842.60,
+			PrefixCost:      1283030.45,
+			DataReadPerJoin: "368M",
+		},
+		UsedColumns: []string{"emp_no", "birth_date", "first_name", "last_name", "gender", "hire_date"},
+	}
+
+	if !reflect.DeepEqual(e, expected) {
+		t.Errorf("ParseExplainJSON should parse the joined table as\n%+v\nbut got\n%+v", expected, e)
+	}
+}
+
+func TestParseExplainJSONSubqueries(t *testing.T) {
+	doc, err := ioutil.ReadFile("testdata/explain_subquery.json")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := ParseExplainJSON(doc)
+
+	if err != nil {
+		t.Fatalf("ParseExplainJSON should not return an error, but got %s", err)
+	}
+
+	order := plan.QueryBlock.OrderingOperation
+
+	if order == nil || !order.UsingFilesort || order.CostInfo.SortCost != 3 {
+		t.Fatalf("ParseExplainJSON should parse the ordering operation, but got %+v", order)
+	}
+
+	derived := order.Table.MaterializedFromSubquery
+
+	if derived == nil || !derived.UsingTemporaryTable || derived.QueryBlock.GroupingOperation.Table.TableName != "dept_emp" {
+		t.Errorf("ParseExplainJSON should parse the materialized subquery, but got %+v", derived)
+	}
+
+	if len(order.Table.AttachedSubqueries) != 1 || !order.Table.AttachedSubqueries[0].Cacheable {
+		t.Errorf("ParseExplainJSON should parse the attached subquery, but got %+v", order.Table.AttachedSubqueries)
+	}
+
+	if order.Table.AttachedCondition == "" {
+		t.Errorf("ParseExplainJSON should parse the attached condition")
+	}
+}
+
+func TestParseExplainJSONInvalid(t *testing.T) {
+	tt := []struct {
+		name string
+		doc  string
+	}{
+		{"Truncated", `{"query_block": {`},
+		{"Bad Cost", `{"query_block": {"cost_info": {"query_cost": "cheap"}}}`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseExplainJSON([]byte(tc.doc)); err == nil {
+				t.Errorf("ParseExplainJSON of %s should return an error", tc.doc)
+			}
+		})
+	}
+}
+
+func TestParseExplainAnalyze(t *testing.T) {
+	tree, err := ioutil.ReadFile("testdata/explain_analyze.txt")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := ParseExplainAnalyze(string(tree))
+
+	if err != nil {
+		t.Fatalf("ParseExplainAnalyze should not return an error, but got %s", err)
+	}
+
+	expectedRoot := types.AnalyzeNode{
+		Operation:      "Nested loop left join",
+		EstimatedCost:  4.68e+6,
+		EstimatedRows:  3.15e+6,
+		HasEstimate:    true,
+		ActualFirstRow: 0.105,
+		ActualLastRow:  3841.301,
+		ActualRows:     3145718,
+		Loops:          1,
+		Executed:       true,
+	}
+
+	actualRoot := *root
+	actualRoot.Children = nil
+
+	if !reflect.DeepEqual(actualRoot, expectedRoot) {
+		t.Errorf("ParseExplainAnalyze root should be\n%+v\nbut got\n%+v", expectedRoot, actualRoot)
+	}
+
+	if len(root.Children) != 2 || len(root.Children[0].Children) != 2 || len(root.Children[1].Children) != 2 {
+		t.Fatalf("ParseExplainAnalyze should nest iterators by indentation, but got %+v", root.Children)
+	}
+
+	lookup := root.Children[0].Children[1]
+
+	if lookup.Operation != "Single-row index lookup on e using PRIMARY (emp_no=s.emp_no)" || lookup.Loops != 2844047 {
+		t.Errorf("ParseExplainAnalyze should keep parenthesized operation details, but got %+v", lookup)
+	}
+
+	filter := root.Children[1]
+
+	if filter.EstimatedCost != 0.997 || filter.EstimatedRows != 0.37 {
+		t.Errorf("ParseExplainAnalyze should parse ranged cost estimates, but got %+v", filter)
+	}
+
+	subquery := filter.Children[1]
+
+	if subquery.HasEstimate || subquery.Executed || subquery.Operation != "Select #2 (subquery in condition; run only once)" {
+		t.Errorf("ParseExplainAnalyze should parse iterators without measurements, but got %+v", subquery)
+	}
+
+	if scan := subquery.Children[0]; scan.Executed || !scan.HasEstimate || scan.EstimatedRows != 24 {
+		t.Errorf("ParseExplainAnalyze should parse never executed iterators, but got %+v", scan)
+	}
+}
+
+func TestParseExplainAnalyzeInvalid(t *testing.T) {
+	tt := []struct {
+		name string
+		tree string
+	}{
+		{"Empty", "\n"},
+		{"Missing Arrow", "Table scan on s"},
+		{"Two Roots", "-> Table scan on s\n-> Table scan on e"},
+		{"Bad Number", "-> Table scan on s  (cost=abc rows=1)"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseExplainAnalyze(tc.tree); err == nil {
+				t.Errorf("ParseExplainAnalyze of %q should return an error", tc.tree)
+			}
+		})
+	}
+}
+
+func TestFlagMisestimates(t *testing.T) {
+	tree, err := ioutil.ReadFile("testdata/explain_analyze.txt")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := ParseExplainAnalyze(string(tree))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flagged := root.FlagMisestimates(10)
+
+	if len(flagged) != 1 || flagged[0] != root.Children[1] {
+		t.Fatalf("FlagMisestimates should flag only the filter, but got %+v", flagged)
+	}
+
+	if !root.Children[1].Misestimated || root.Children[1].MisestimateRate != 40 {
+		t.Errorf("FlagMisestimates should record the filter's misestimate rate, but got %+v", root.Children[1])
+	}
+
+	if len(root.FlagMisestimates(50)) != 0 || root.Children[1].Misestimated {
+		t.Errorf("FlagMisestimates should not flag estimates within the factor")
+	}
+}
+
+func TestSupportsExplainAnalyze(t *testing.T) {
+	tt := []struct {
+		version  string
+		expected bool
+	}{
+		{"8.0.18", true},
+		{"8.0.35-log", true},
+		{"8.4.0", true},
+		{"9.1.0", true},
+		{"8.0.17", false},
+		{"5.7.44-log", false},
+		{"10.6.16-MariaDB", false},
+		{"unknown", false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.version, func(t *testing.T) {
+			if actual := SupportsExplainAnalyze(tc.version); actual != tc.expected {
+				t.Errorf("SupportsExplainAnalyze of %s should be %v, but got %v", tc.version, tc.expected, actual)
+			}
+		})
+	}
+}
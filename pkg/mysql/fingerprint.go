@@ -0,0 +1,145 @@
+package mysql
+
+import (
+	"crypto/md5"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The patterns below follow pt-query-digest's fingerprint rules in the order it applies them
+var (
+	mysqldumpQuery   = regexp.MustCompile("^SELECT /\\*!40001 SQL_NO_CACHE \\*/ \\* FROM `")
+	perconaQuery     = regexp.MustCompile(`/\*\w+\.\w+:[0-9]/[0-9]\*/`)
+	callQuery        = regexp.MustCompile(`(?i)^\s*(call\s+\S+)\(`)
+	multiValueInsert = regexp.MustCompile(`(?is)^((?:INSERT|REPLACE)(?: IGNORE)?\s+INTO.+?VALUES\s*\(.*?\))\s*,\s*\(`)
+	lineComments     = regexp.MustCompile(`(?m)(?:--|#)[^'"\r\n]*$`)
+	blockComments    = regexp.MustCompile(`(?s)/\*[^!].*?\*/`)
+	useQuery         = regexp.MustCompile(`(?i)^use \S+$`)
+	escapedQuotes    = regexp.MustCompile(`([^\\])(\\['"])`)
+	doubleQuoted     = regexp.MustCompile(`(?s)([^\\])(".*?[^\\]?")`)
+	singleQuoted     = regexp.MustCompile(`(?s)([^\\])('.*?[^\\]?')`)
+	booleans         = regexp.MustCompile(`(?i)\bfalse\b|\btrue\b`)
+	numbers          = regexp.MustCompile(`(^|[^\w$])[0-9+-][0-9a-fA-F.xXbB+-]*`)
+	numberPrefixes   = regexp.MustCompile(`[xb.+-]\?`)
+	whitespace       = regexp.MustCompile(`[ \n\t\r\f]+`)
+	nulls            = regexp.MustCompile(`\bnull\b`)
+	valueLists       = regexp.MustCompile(`\b(in|values?)(?:[\s,]*\([\s?,]*\))+`)
+	unions           = regexp.MustCompile(`\s(union(?:\sall)?)\s`)
+	limits           = regexp.MustCompile(`\blimit \?(?:, ?\?| offset \?)?`)
+	orderBy          = regexp.MustCompile(`\border by `)
+	ascending        = regexp.MustCompile(`\s+asc\b`)
+)
+
+// Fingerprint abstracts a query into its pattern the way pt-query-digest does, by
+// removing comments and literals, collapsing IN lists, VALUES tuples and repeated
+// UNIONs, and normalizing whitespace and case. Numbers embedded in identifiers, such
+// as db1.t2, are kept as with pt-query-digest's --match-embedded-numbers.
+func Fingerprint(query string) string {
+	switch {
+	case mysqldumpQuery.MatchString(query):
+		return "mysqldump"
+	case perconaQuery.MatchString(query):
+		return "percona-toolkit"
+	case strings.HasPrefix(query, "administrator command: "):
+		return query
+	}
+
+	if m := callQuery.FindStringSubmatch(query); m != nil {
+		return strings.ToLower(m[1])
+	}
+
+	// shorten multi-value INSERT statements before the expensive replacements
+	if m := multiValueInsert.FindStringSubmatch(query); m != nil {
+		query = m[1]
+	}
+
+	query = lineComments.ReplaceAllString(query, "")
+	query = blockComments.ReplaceAllString(query, "")
+
+	if useQuery.MatchString(strings.TrimSpace(query)) {
+		return "use ?"
+	}
+
+	query = escapedQuotes.ReplaceAllString(query, "${1}")
+	query = strings.NewReplacer(`\\`, "", `\'`, "", `\"`, "").Replace(query)
+	query = doubleQuoted.ReplaceAllString(query, "${1}?")
+	query = singleQuoted.ReplaceAllString(query, "${1}?")
+	query = booleans.ReplaceAllString(query, "?")
+	query = numbers.ReplaceAllString(query, "${1}?")
+	query = numberPrefixes.ReplaceAllString(query, "?")
+	query = whitespace.ReplaceAllString(strings.TrimSpace(query), " ")
+	query = strings.ToLower(query)
+	query = nulls.ReplaceAllString(query, "?")
+	query = valueLists.ReplaceAllString(query, "${1}(?+)")
+	query = collapseUnions(query)
+
+	if loc := limits.FindStringIndex(query); loc != nil {
+		query = query[:loc[0]] + "limit ?" + query[loc[1]:]
+	}
+
+	if loc := orderBy.FindStringIndex(query); loc != nil {
+		query = query[:loc[1]] + ascending.ReplaceAllString(query[loc[1]:], "")
+	}
+
+	return query
+}
+
+// collapseUnions replaces repetitions of the same SELECT joined by UNION with a single
+// SELECT followed by a /*repeat union*/ comment
+func collapseUnions(query string) string {
+	seps := unions.FindAllStringSubmatchIndex(query, -1)
+
+	if len(seps) == 0 {
+		return query
+	}
+
+	var b strings.Builder
+	start := 0
+	prev := ""
+	repeat := ""
+
+	for i := 0; i <= len(seps); i++ {
+		end := len(query)
+
+		if i < len(seps) {
+			end = seps[i][0]
+		}
+
+		segment := query[start:end]
+
+		if i > 0 && strings.HasPrefix(segment, "select ") && strings.HasSuffix(prev, segment) {
+			repeat = query[seps[i-1][2]:seps[i-1][3]]
+		} else {
+			if repeat != "" {
+				b.WriteString(" /*repeat " + repeat + "*/")
+				repeat = ""
+			}
+
+			if i > 0 {
+				b.WriteString(query[seps[i-1][0]:seps[i-1][1]])
+			}
+
+			b.WriteString(segment)
+			prev = segment
+		}
+
+		if i < len(seps) {
+			start = seps[i][1]
+		}
+	}
+
+	if repeat != "" {
+		b.WriteString(" /*repeat " + repeat + "*/")
+	}
+
+	return b.String()
+}
+
+// Checksum creates the query ID of a fingerprint, as used by pt-query-digest, from
+// the last 16 hex digits of its MD5 checksum
+func Checksum(fingerprint string) string {
+	sum := fmt.Sprintf("%X", md5.Sum([]byte(fingerprint)))
+
+	return sum[len(sum)-16:]
+}
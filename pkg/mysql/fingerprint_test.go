@@ -0,0 +1,76 @@
+package mysql
+
+import (
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	tt := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{"Literals", "SELECT * FROM employees WHERE emp_no=10001 AND last_name='Facello'",
+			"select * from employees where emp_no=? and last_name=?"},
+		{"Double Quoted Strings", `SELECT * FROM t WHERE a = "x" OR b = 'it\'s'`, "select * from t where a = ? or b = ?"},
+		{"Whitespace And Case", "SELECT  *\n\tFROM  salaries\r\n WHERE salary >  60000  ",
+			"select * from salaries where salary > ?"},
+		{"Embedded Numbers", "SELECT * FROM db1.t2 WHERE c = -1.5e3", "select * from db1.t2 where c = ?"},
+		{"Booleans And Nulls", "SELECT * FROM t WHERE a = TRUE AND b IS NULL OR c = false", "select * from t where a = ? and b is ? or c = ?"},
+		{"Hex Literal", "SELECT * FROM t WHERE a = 0xDEADBEEF", "select * from t where a = ?"},
+		{"IN List", "SELECT * FROM t WHERE a IN (1, 2, 3) AND b in ('x','y')", "select * from t where a in(?+) and b in(?+)"},
+		{"Multi-Value Insert", "INSERT INTO t (a, b) VALUES (1, 'x'), (2, 'y'), (3, 'z')", "insert into t (a, b) values(?+)"},
+		{"Single Value Replace", "REPLACE IGNORE INTO t VALUES (1)", "replace ignore into t values(?+)"},
+		{"Comments", "SELECT /* hint */ a -- trailing\nFROM t # mysql comment\nWHERE b = 1",
+			"select a from t where b = ?"},
+		{"Executable Comment", "SELECT /*!50000 SQL_NO_CACHE */ a FROM t", "select /*!? sql_no_cache */ a from t"},
+		{"Limit", "SELECT * FROM t LIMIT 10, 20", "select * from t limit ?"},
+		{"Limit Offset", "SELECT * FROM t LIMIT 10 OFFSET 20", "select * from t limit ?"},
+		{"Order By Ascending", "SELECT * FROM t ORDER BY a ASC, b DESC, c ASC", "select * from t order by a, b desc, c"},
+		{"Repeated Union", "SELECT a FROM t WHERE b = 1 UNION SELECT a FROM t WHERE b = 2 UNION ALL SELECT a FROM t WHERE b = 3",
+			"select a from t where b = ? /*repeat union all*/"},
+		{"Distinct Union", "SELECT a FROM t UNION SELECT b FROM u", "select a from t union select b from u"},
+		{"Use", "use `employees`", "use ?"},
+		{"Call", "CALL update_salaries(10001, 5000)", "call update_salaries"},
+		{"Administrator Command", "administrator command: Quit", "administrator command: Quit"},
+		{"mysqldump", "SELECT /*!40001 SQL_NO_CACHE */ * FROM `employees`", "mysqldump"},
+		{"Percona Toolkit", "REPLACE /*foo.bar:1/5*/ INTO checksums", "percona-toolkit"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := Fingerprint(tc.query)
+
+			if actual != tc.expected {
+				t.Errorf("Fingerprint of %q should be %q, but got %q", tc.query, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFingerprintGroupsEquivalentQueries(t *testing.T) {
+	a := Fingerprint("SELECT * FROM employees WHERE emp_no = 10001")
+	b := Fingerprint("select *\nfrom employees\nwhere emp_no = 499999 -- last one")
+
+	if a != b || Checksum(a) != Checksum(b) {
+		t.Errorf("equivalent queries should share a fingerprint, but got %q and %q", a, b)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	tt := []struct {
+		fingerprint string
+		expected    string
+	}{
+		{"select ?", "16219655761820A2"},
+		{"select * from t where a in(?+)", "E9C1637F37F97167"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.fingerprint, func(t *testing.T) {
+			if actual := Checksum(tc.fingerprint); actual != tc.expected {
+				t.Errorf("Checksum of %q should be %s, but got %s", tc.fingerprint, tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package mysql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSnapshotFile is where the original values of changed global variables are kept, in a
+// directory of the user that outlives reboots, unlike the temporary directory
+var DefaultSnapshotFile = filepath.Join(stateDir(), "globals.json")
+
+// stateDir is the gopherDigest directory of $XDG_STATE_HOME, or of the user configuration
+// directory when it is not set, falling back to the temporary directory without a home directory
+func stateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "gopherDigest")
+	}
+
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "gopherDigest")
+	}
+
+	return filepath.Join(os.TempDir(), "gopherDigest")
+}
+
+// Snapshot records the original values of the global variables gopherDigest changes on a MySQL
+// server. It is written to a file before every change, so the server can be restored by a later
+// process if the one that changed it crashed.
+type Snapshot struct {
+	Server  string            `json:"server"`
+	Taken   time.Time         `json:"taken"`
+	Globals map[string]string `json:"globals"`
+	path    string
+}
+
+// OpenSnapshot continues the snapshot of a server left in a file by a process that did not restore
+// it, so the values it recorded are not replaced by the ones it set, or starts a new snapshot
+func OpenSnapshot(path, server string) (*Snapshot, error) {
+	s, err := LoadSnapshot(path)
+
+	if os.IsNotExist(err) {
+		return &Snapshot{Server: server, Taken: time.Now().UTC(), Globals: map[string]string{}, path: path}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Server != server {
+		return nil, fmt.Errorf("the snapshot %s holds the globals of %s, restore it before changing %s", path, s.Server, server)
+	}
+
+	return s, nil
+}
+
+// LoadSnapshot reads a snapshot persisted by a process that changed global variables
+func LoadSnapshot(path string) (*Snapshot, error) {
+	b, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{path: path}
+
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("could not parse the snapshot %s\n%s", path, err)
+	}
+
+	if s.Globals == nil {
+		s.Globals = map[string]string{}
+	}
+
+	return s, nil
+}
+
+// SetGlobal changes a global variable to a SQL literal, recording and persisting its original
+// value first if it has not been changed before
+func (s *Snapshot) SetGlobal(db *sql.DB, name, value string) error {
+	if _, ok := s.Globals[name]; !ok {
+		var original string
+
+		if err := db.QueryRow(fmt.Sprintf("SELECT @@GLOBAL.%s", name)).Scan(&original); err != nil {
+			return fmt.Errorf("could not read the global variable %s\n%s", name, err)
+		}
+
+		s.Globals[name] = original
+
+		if err := s.save(); err != nil {
+			delete(s.Globals, name)
+			return err
+		}
+	}
+
+	stmt := fmt.Sprintf("SET @@GLOBAL.%s = %s", name, value)
+	fmt.Printf("mysql> %s;\n", stmt)
+
+	if _, err := db.Exec(stmt); err != nil {
+		return fmt.Errorf("could not set the global variable %s\n%s", name, err)
+	}
+
+	return nil
+}
+
+// Statements returns the statements that restore the recorded global variables
+func (s *Snapshot) Statements() []string {
+	stmts := []string{}
+
+	for _, name := range s.names() {
+		stmts = append(stmts, s.restoreStatement(name))
+	}
+
+	return stmts
+}
+
+// Restore sets every recorded global variable back to its original value and removes the
+// snapshot file once they all are
+func (s *Snapshot) Restore(db *sql.DB) error {
+	failed := []string{}
+
+	for _, name := range s.names() {
+		stmt := s.restoreStatement(name)
+		fmt.Printf("mysql> %s;\n", stmt)
+
+		if _, err := db.Exec(stmt); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+
+		delete(s.Globals, name)
+	}
+
+	if len(failed) > 0 {
+		if err := s.save(); err != nil {
+			return err
+		}
+
+		return fmt.Errorf("could not restore the global variables, the rest are kept in %s\n%s", s.path, strings.Join(failed, "\n"))
+	}
+
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not remove the snapshot %s\n%s", s.path, err)
+	}
+
+	return nil
+}
+
+// names returns the recorded global variables in order
+func (s *Snapshot) names() []string {
+	names := []string{}
+
+	for name := range s.Globals {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// restoreStatement sets a global variable back to its recorded value
+func (s *Snapshot) restoreStatement(name string) string {
+	return fmt.Sprintf("SET @@GLOBAL.%s = %s", name, literal(s.Globals[name]))
+}
+
+// save writes the snapshot to a temporary file that replaces its file, so a crash while it is
+// written cannot lose the values of an earlier snapshot. The directory is created if needed, and
+// only the user can read the file.
+func (s *Snapshot) save() error {
+	b, err := json.MarshalIndent(s, "", "  ")
+
+	if err != nil {
+		return fmt.Errorf("could not encode the snapshot\n%s", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("could not create the directory of the snapshot %s\n%s", s.path, err)
+	}
+
+	// TempFile creates the file with mode 0600
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+
+	if err != nil {
+		return fmt.Errorf("could not write the snapshot %s\n%s", s.path, err)
+	}
+
+	_, err = tmp.Write(b)
+
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not write the snapshot %s\n%s", s.path, err)
+	}
+
+	return nil
+}
+
+// literal formats a value read from a global variable as a SQL literal, as numeric variables
+// reject quoted values
+func literal(value string) string {
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+
+	return "'" + strings.Replace(strings.Replace(value, `\`, `\\`, -1), "'", `\'`, -1) + "'"
+}
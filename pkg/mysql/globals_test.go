@@ -0,0 +1,210 @@
+package mysql
+
+import (
+	"database/sql/driver"
+	"errors"
+	"gopherDigest/pkg/mysql/mysqltest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// globalResponse answers SELECT @@GLOBAL.<name> with a single value
+func globalResponse(name string, value driver.Value) mysqltest.Response {
+	return mysqltest.Response{Columns: []string{"@@GLOBAL." + name}, Rows: [][]driver.Value{{value}}}
+}
+
+func tempSnapshotPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "globals")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return filepath.Join(dir, "globals.json"), func() { os.RemoveAll(dir) }
+}
+
+func TestSnapshotSetGlobalAndRestore(t *testing.T) {
+	path, cleanup := tempSnapshotPath(t)
+	defer cleanup()
+
+	db, srv := mysqltest.NewDB(t, map[string]mysqltest.Response{
+		"SELECT @@GLOBAL.slow_query_log":  globalResponse("slow_query_log", int64(0)),
+		"SELECT @@GLOBAL.long_query_time": globalResponse("long_query_time", []byte("10.000000")),
+		"SELECT @@GLOBAL.log_output":      globalResponse("log_output", []byte("FILE")),
+		"SET @@GLOBAL.":                   {},
+	})
+
+	defer db.Close()
+
+	snap, err := OpenSnapshot(path, "db:3306")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, global := range [][2]string{{"slow_query_log", "'ON'"}, {"long_query_time", "0"}, {"log_output", "'TABLE'"}, {"long_query_time", "1"}} {
+		if err := snap.SetGlobal(db, global[0], global[1]); err != nil {
+			t.Fatalf("SetGlobal should not return an error, but got %s", err)
+		}
+	}
+
+	persisted, err := LoadSnapshot(path)
+
+	if err != nil {
+		t.Fatalf("SetGlobal should persist the snapshot, but got %s", err)
+	}
+
+	expected := map[string]string{"slow_query_log": "0", "long_query_time": "10.000000", "log_output": "FILE"}
+
+	if persisted.Server != "db:3306" || !reflect.DeepEqual(persisted.Globals, expected) {
+		t.Errorf("the snapshot should record the original values %v of db:3306, but got %+v", expected, persisted)
+	}
+
+	if err := snap.Restore(db); err != nil {
+		t.Fatalf("Restore should not return an error, but got %s", err)
+	}
+
+	statements := srv.Statements()
+	restored := statements[len(statements)-3:]
+
+	if !reflect.DeepEqual(restored, []string{
+		"SET @@GLOBAL.log_output = 'FILE'", "SET @@GLOBAL.long_query_time = 10.000000", "SET @@GLOBAL.slow_query_log = 0",
+	}) {
+		t.Errorf("Restore should set every global back to its original value, but got %q", restored)
+	}
+
+	if len(statements) != 10 {
+		t.Errorf("a global should only be read before its first change, but got %q", statements)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Restore should remove the snapshot file, but got %v", err)
+	}
+}
+
+func TestSnapshotSave(t *testing.T) {
+	tmp, cleanup := tempSnapshotPath(t)
+	defer cleanup()
+
+	path := filepath.Join(filepath.Dir(tmp), "state", "gopherDigest", "globals.json")
+	snap, err := OpenSnapshot(path, "db:3306")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap.Globals["slow_query_log"] = "0"
+
+	if err := snap.save(); err != nil {
+		t.Fatalf("save should create the directory of the snapshot, but got %s", err)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("save should write the snapshot only the user can read, but got %v, %v", info, err)
+	}
+
+	if entries, _ := ioutil.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("save should not leave its temporary file behind, but found %d files", len(entries))
+	}
+}
+
+func TestStateDir(t *testing.T) {
+	state := filepath.Join("home", "gopher", ".local", "state")
+	t.Setenv("XDG_STATE_HOME", state)
+
+	if dir := stateDir(); dir != filepath.Join(state, "gopherDigest") {
+		t.Errorf("stateDir should be in $XDG_STATE_HOME, but got %s", dir)
+	}
+
+	t.Setenv("XDG_STATE_HOME", "")
+
+	if config, err := os.UserConfigDir(); err == nil && stateDir() != filepath.Join(config, "gopherDigest") {
+		t.Errorf("stateDir should be in the user configuration directory %s, but got %s", config, stateDir())
+	}
+}
+
+func TestOpenSnapshotUnrestored(t *testing.T) {
+	path, cleanup := tempSnapshotPath(t)
+	defer cleanup()
+
+	if err := ioutil.WriteFile(path, []byte(`{"server": "db:3306", "globals": {"slow_query_log": "0"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	db, srv := mysqltest.NewDB(t, map[string]mysqltest.Response{"SET @@GLOBAL.": {}})
+	defer db.Close()
+
+	snap, err := OpenSnapshot(path, "db:3306")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := snap.SetGlobal(db, "slow_query_log", "'ON'"); err != nil {
+		t.Fatalf("SetGlobal should not return an error, but got %s", err)
+	}
+
+	if statements := srv.Statements(); len(statements) != 1 || snap.Globals["slow_query_log"] != "0" {
+		t.Errorf("an unrestored snapshot should keep its original values, but got %v after %q", snap.Globals, statements)
+	}
+
+	if _, err := OpenSnapshot(path, "replica:3306"); err == nil {
+		t.Errorf("OpenSnapshot should refuse to continue the snapshot of another server")
+	}
+}
+
+func TestSnapshotRestoreFailure(t *testing.T) {
+	path, cleanup := tempSnapshotPath(t)
+	defer cleanup()
+
+	db, srv := mysqltest.NewDB(t, map[string]mysqltest.Response{
+		"SELECT @@GLOBAL.slow_query_log":            globalResponse("slow_query_log", int64(0)),
+		"SELECT @@GLOBAL.log_slow_slave_statements": globalResponse("log_slow_slave_statements", int64(0)),
+		"SET @@GLOBAL.": {},
+	})
+
+	defer db.Close()
+
+	snap, err := OpenSnapshot(path, "db:3306")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap.SetGlobal(db, "slow_query_log", "'ON'")
+	snap.SetGlobal(db, "log_slow_slave_statements", "'ON'")
+
+	srv.SetResponse("SET @@GLOBAL.log_slow_slave_statements", mysqltest.Response{Err: errors.New("Access denied")})
+
+	if err := snap.Restore(db); err == nil {
+		t.Fatalf("Restore should return an error when a global cannot be restored")
+	}
+
+	persisted, err := LoadSnapshot(path)
+
+	if err != nil || !reflect.DeepEqual(persisted.Globals, map[string]string{"log_slow_slave_statements": "0"}) {
+		t.Errorf("the snapshot file should keep the globals that were not restored, but got %+v, %v", persisted, err)
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	tt := []struct {
+		value    string
+		expected string
+	}{
+		{"151", "151"},
+		{"10.000000", "10.000000"},
+		{"FILE,TABLE", "'FILE,TABLE'"},
+		{"", "''"},
+		{`it's C:\logs`, `'it\'s C:\\logs'`},
+	}
+
+	for _, tc := range tt {
+		if actual := literal(tc.value); actual != tc.expected {
+			t.Errorf("literal(%q) should be %s, but got %s", tc.value, tc.expected, actual)
+		}
+	}
+}
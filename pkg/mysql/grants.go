@@ -0,0 +1,337 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"gopherDigest/pkg/config"
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Feature is a part of gopherDigest and the privileges it needs on the MySQL server
+type Feature struct {
+	Name     string
+	Requires []Requirement
+}
+
+// Requirement is a privilege, or any one of several alternatives, on a database and table,
+// where "*" stands for every database or every table
+type Requirement struct {
+	Privileges []string
+	Database   string
+	Table      string
+}
+
+var (
+	// SlowQueryLog sets the global variables that log every statement to the slow query log
+	SlowQueryLog = Feature{Name: "slow query log", Requires: []Requirement{
+		{Privileges: []string{"SYSTEM_VARIABLES_ADMIN", "SUPER"}, Database: "*", Table: "*"},
+	}}
+
+	// PerformanceSchema reads the statement digests and statement history
+	PerformanceSchema = Feature{Name: "performance_schema", Requires: []Requirement{
+		{Privileges: []string{"SELECT"}, Database: "performance_schema", Table: "events_statements_summary_by_digest"},
+		{Privileges: []string{"SELECT"}, Database: "performance_schema", Table: "events_statements_history"},
+	}}
+
+	// Processlist sees the running statements of every account
+	Processlist = Feature{Name: "processlist", Requires: []Requirement{
+		{Privileges: []string{"PROCESS"}, Database: "*", Table: "*"},
+	}}
+)
+
+// TargetSchema runs and explains queries against the tables of a database
+func TargetSchema(database string) Feature {
+	return Feature{Name: "target schema " + database, Requires: []Requirement{
+		{Privileges: []string{"SELECT"}, Database: database, Table: "*"},
+	}}
+}
+
+// Grants are the privileges of an account, as listed by SHOW GRANTS
+type Grants struct {
+	Account string
+	Roles   []string
+	grants  []grant
+	revokes []grant
+}
+
+// grant is a set of privileges on a database and table, where "*" stands for all of them
+type grant struct {
+	privileges map[string]bool
+	database   string
+	table      string
+}
+
+var (
+	privilegeGrant = regexp.MustCompile("(?is)^(GRANT|REVOKE)\\s+(.+?)\\s+ON\\s+(?:(?:TABLE|FUNCTION|PROCEDURE)\\s+)?" +
+		"(`(?:[^`]|``)*`|\\*|[^\\s.]+)\\.(`(?:[^`]|``)*`|\\*|\\S+)\\s+(?:TO|FROM)\\s+(\\S+)")
+	roleGrant  = regexp.MustCompile(`(?is)^GRANT\s+(.+?)\s+TO\s+(\S+)`)
+	columnList = regexp.MustCompile(`\(.*\)`)
+	spaces     = regexp.MustCompile(`\s+`)
+)
+
+// showGrantsQuery lists the grants of the account of a connection
+const showGrantsQuery = "SHOW GRANTS FOR CURRENT_USER()"
+
+// String describes a requirement, such as SELECT on performance_schema.threads
+func (r Requirement) String() string {
+	return fmt.Sprintf("%s on %s.%s", strings.Join(r.Privileges, " or "), r.Database, r.Table)
+}
+
+// CheckPrivileges verifies the account of a connection holds the privileges every feature needs,
+// reporting the missing privileges of each feature. Privileges granted through roles are included.
+func CheckPrivileges(db *sql.DB, features ...Feature) (*config.Privileges, error) {
+	red := color.New(color.FgRed, color.Bold)
+	green := color.New(color.FgGreen, color.Bold)
+
+	grants, err := showGrants(db, showGrantsQuery)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the privileges of roles are only listed when SHOW GRANTS is asked to use them
+	if len(grants.Roles) > 0 {
+		if grants, err = showGrants(db, showGrantsQuery+" USING "+strings.Join(grants.Roles, ", ")); err != nil {
+			return nil, err
+		}
+	}
+
+	stat := &config.Privileges{Account: grants.Account, Features: map[string]string{}}
+	failed := []string{}
+
+	for _, f := range features {
+		missing := grants.Missing(f)
+
+		if len(missing) == 0 {
+			stat.Features[f.Name] = green.Sprint(" OK")
+			continue
+		}
+
+		needs := []string{}
+
+		for _, r := range missing {
+			needs = append(needs, r.String())
+		}
+
+		stat.Features[f.Name] = red.Sprintf(" MISSING %s", strings.Join(needs, ", "))
+		stat.Errors = append(stat.Errors, fmt.Errorf("%s needs %s", f.Name, strings.Join(needs, ", ")))
+		failed = append(failed, f.Name)
+	}
+
+	if len(failed) > 0 {
+		return stat, fmt.Errorf("the MySQL account %s is missing the privileges needed for the %s", grants.Account, strings.Join(failed, ", "))
+	}
+
+	return stat, nil
+}
+
+// showGrants reads and parses the grants of the current account
+func showGrants(db *sql.DB, query string) (*Grants, error) {
+	rows, err := db.Query(query)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read the privileges of the MySQL account\n%s", err)
+	}
+
+	defer rows.Close()
+
+	statements := []string{}
+
+	for rows.Next() {
+		var stmt string
+
+		if err := rows.Scan(&stmt); err != nil {
+			return nil, fmt.Errorf("could not scan the privileges of the MySQL account\n%s", err)
+		}
+
+		statements = append(statements, stmt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read the privileges of the MySQL account\n%s", err)
+	}
+
+	return ParseGrants(statements), nil
+}
+
+// ParseGrants parses the GRANT and partial REVOKE statements listed by SHOW GRANTS. Column
+// privileges are ignored, as they do not cover whole tables.
+func ParseGrants(statements []string) *Grants {
+	g := &Grants{}
+
+	for _, stmt := range statements {
+		stmt = strings.TrimSpace(stmt)
+
+		if m := privilegeGrant.FindStringSubmatch(stmt); m != nil {
+			gr := grant{privileges: map[string]bool{}, database: unquoteIdentifier(m[3]), table: unquoteIdentifier(m[4])}
+
+			for _, p := range splitList(m[2]) {
+				if columnList.MatchString(p) {
+					continue
+				}
+
+				p = strings.ToUpper(spaces.ReplaceAllString(strings.TrimSpace(p), " "))
+
+				if p == "ALL PRIVILEGES" {
+					p = "ALL"
+				}
+
+				gr.privileges[p] = true
+			}
+
+			if g.Account == "" {
+				g.Account = m[5]
+			}
+
+			if strings.EqualFold(m[1], "REVOKE") {
+				g.revokes = append(g.revokes, gr)
+			} else {
+				g.grants = append(g.grants, gr)
+			}
+
+			continue
+		}
+
+		// PROXY grants name an account instead of a database and table
+		if m := roleGrant.FindStringSubmatch(stmt); m != nil && !strings.HasPrefix(strings.ToUpper(m[1]), "PROXY ") {
+			g.Roles = append(g.Roles, splitList(m[1])...)
+
+			if g.Account == "" {
+				g.Account = m[2]
+			}
+		}
+	}
+
+	return g
+}
+
+// Has determines whether a privilege is held on a table, where a table of "*" needs the
+// privilege on the whole database and a database of "*" needs it globally
+func (g *Grants) Has(privilege, database, table string) bool {
+	held := false
+
+	for _, gr := range g.grants {
+		if gr.covers(privilege, database, table) {
+			held = true
+			break
+		}
+	}
+
+	if !held {
+		return false
+	}
+
+	// partial revokes remove privileges from the databases of a global grant
+	for _, r := range g.revokes {
+		if r.database != "*" && r.covers(privilege, database, "*") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Missing returns the requirements of a feature that are not met by any alternative privilege
+func (g *Grants) Missing(f Feature) []Requirement {
+	missing := []Requirement{}
+
+	for _, r := range f.Requires {
+		met := false
+
+		for _, p := range r.Privileges {
+			if g.Has(p, r.Database, r.Table) {
+				met = true
+				break
+			}
+		}
+
+		if !met {
+			missing = append(missing, r)
+		}
+	}
+
+	return missing
+}
+
+// covers determines whether a grant includes a privilege on a table
+func (gr grant) covers(privilege, database, table string) bool {
+	if !gr.privileges[privilege] && !(gr.privileges["ALL"] && privilege != "GRANT OPTION" && privilege != "PROXY") {
+		return false
+	}
+
+	if gr.database == "*" {
+		return true
+	}
+
+	if database == "*" || !matchDatabase(gr.database, database) {
+		return false
+	}
+
+	return gr.table == "*" || (table != "*" && strings.EqualFold(gr.table, table))
+}
+
+// matchDatabase matches a database name against the pattern of a grant, where '%' and '_'
+// are wildcards unless escaped with '\'
+func matchDatabase(pattern, database string) bool {
+	expr := strings.Builder{}
+	expr.WriteString("^")
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			expr.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		case c == '%':
+			expr.WriteString(".*")
+		case c == '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	expr.WriteString("$")
+
+	return regexp.MustCompile(expr.String()).MatchString(database)
+}
+
+// unquoteIdentifier removes the backticks around an identifier
+func unquoteIdentifier(id string) string {
+	if len(id) >= 2 && id[0] == '`' && id[len(id)-1] == '`' {
+		return strings.Replace(id[1:len(id)-1], "``", "`", -1)
+	}
+
+	return id
+}
+
+// splitList splits a comma separated list, ignoring commas within parentheses and quotes
+func splitList(list string) []string {
+	items := []string{}
+	depth, start := 0, 0
+	var quote byte
+
+	for i := 0; i < len(list); i++ {
+		c := list[i]
+
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '`' || c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			items = append(items, strings.TrimSpace(list[start:i]))
+			start = i + 1
+		}
+	}
+
+	return append(items, strings.TrimSpace(list[start:]))
+}
@@ -0,0 +1,160 @@
+package mysql
+
+import (
+	"database/sql/driver"
+	"gopherDigest/pkg/mysql/mysqltest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// grantsResponse answers SHOW GRANTS with one statement per row
+func grantsResponse(statements ...string) mysqltest.Response {
+	rows := [][]driver.Value{}
+
+	for _, stmt := range statements {
+		rows = append(rows, []driver.Value{[]byte(stmt)})
+	}
+
+	return mysqltest.Response{Columns: []string{"Grants for gopher@%"}, Rows: rows}
+}
+
+func TestGrantsMissing(t *testing.T) {
+	tt := []struct {
+		name     string
+		grants   []string
+		feature  Feature
+		expected []Requirement
+	}{
+		{
+			"global ALL PRIVILEGES",
+			[]string{"GRANT ALL PRIVILEGES ON *.* TO `root`@`localhost` WITH GRANT OPTION"},
+			SlowQueryLog,
+			[]Requirement{},
+		},
+		{
+			"dynamic privilege as an alternative to SUPER",
+			[]string{"GRANT USAGE ON *.* TO `gopher`@`%`", "GRANT SYSTEM_VARIABLES_ADMIN ON *.* TO `gopher`@`%`"},
+			SlowQueryLog,
+			[]Requirement{},
+		},
+		{
+			"database privileges do not cover globals",
+			[]string{"GRANT ALL PRIVILEGES ON `employees`.* TO `gopher`@`%`"},
+			SlowQueryLog,
+			SlowQueryLog.Requires,
+		},
+		{
+			"database wildcard",
+			[]string{"GRANT SELECT, INSERT ON `employ%`.* TO 'gopher'@'%'"},
+			TargetSchema("employees"),
+			[]Requirement{},
+		},
+		{
+			"escaped wildcard",
+			[]string{"GRANT SELECT ON `employ\\_es`.* TO 'gopher'@'%'"},
+			TargetSchema("employees"),
+			TargetSchema("employees").Requires,
+		},
+		{
+			"table privilege",
+			[]string{"GRANT SELECT ON `performance_schema`.`events_statements_summary_by_digest` TO `gopher`@`%`"},
+			PerformanceSchema,
+			PerformanceSchema.Requires[1:],
+		},
+		{
+			"column privileges do not cover the table",
+			[]string{"GRANT SELECT (`DIGEST`, `COUNT_STAR`), UPDATE ON `performance_schema`.* TO `gopher`@`%`"},
+			PerformanceSchema,
+			PerformanceSchema.Requires,
+		},
+		{
+			"partial revoke",
+			[]string{"GRANT SELECT, PROCESS ON *.* TO `gopher`@`%`", "REVOKE SELECT ON `employees`.* FROM `gopher`@`%`"},
+			TargetSchema("employees"),
+			TargetSchema("employees").Requires,
+		},
+		{
+			"partial revoke of another database",
+			[]string{"GRANT SELECT, PROCESS ON *.* TO `gopher`@`%`", "REVOKE SELECT ON `mysql`.* FROM `gopher`@`%`"},
+			TargetSchema("employees"),
+			[]Requirement{},
+		},
+		{
+			"usage only",
+			[]string{"GRANT USAGE ON *.* TO `gopher`@`%`"},
+			Processlist,
+			Processlist.Requires,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := ParseGrants(tc.grants).Missing(tc.feature)
+
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("Missing(%s) should be %v, but got %v", tc.feature.Name, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseGrantsRoles(t *testing.T) {
+	g := ParseGrants([]string{
+		"GRANT USAGE ON *.* TO `gopher`@`%`",
+		"GRANT PROXY ON ``@`` TO `gopher`@`%`",
+		"GRANT `monitor`@`%`,`reader`@`%` TO `gopher`@`%`",
+	})
+
+	if g.Account != "`gopher`@`%`" {
+		t.Errorf("ParseGrants should read the account from the grants, but got %s", g.Account)
+	}
+
+	if expected := []string{"`monitor`@`%`", "`reader`@`%`"}; !reflect.DeepEqual(g.Roles, expected) {
+		t.Errorf("ParseGrants should read the granted roles %v, but got %v", expected, g.Roles)
+	}
+}
+
+func TestCheckPrivileges(t *testing.T) {
+	db, srv := mysqltest.NewDB(t, map[string]mysqltest.Response{
+		"SHOW GRANTS FOR CURRENT_USER()": grantsResponse(
+			"GRANT USAGE ON *.* TO `gopher`@`%`",
+			"GRANT `monitor`@`%` TO `gopher`@`%`",
+		),
+		"SHOW GRANTS FOR CURRENT_USER() USING": grantsResponse(
+			"GRANT PROCESS ON *.* TO `gopher`@`%`",
+			"GRANT SELECT ON `performance_schema`.* TO `gopher`@`%`",
+			"GRANT `monitor`@`%` TO `gopher`@`%`",
+		),
+	})
+
+	defer db.Close()
+
+	privs, err := CheckPrivileges(db, PerformanceSchema, Processlist)
+
+	if err != nil {
+		t.Fatalf("CheckPrivileges should include the privileges of roles, but got %s", err)
+	}
+
+	if statements := srv.Statements(); len(statements) != 2 || statements[1] != "SHOW GRANTS FOR CURRENT_USER() USING `monitor`@`%`" {
+		t.Errorf("CheckPrivileges should list the grants using the account's roles, but got %q", statements)
+	}
+
+	if len(privs.Features) != 2 || len(privs.Errors) != 0 {
+		t.Errorf("CheckPrivileges should report every feature as met, but got %+v", privs)
+	}
+
+	privs, err = CheckPrivileges(db, SlowQueryLog, TargetSchema("employees"), Processlist)
+
+	if err == nil {
+		t.Fatalf("CheckPrivileges should return an error when a feature is missing privileges")
+	}
+
+	if !strings.Contains(err.Error(), "slow query log, target schema employees") {
+		t.Errorf("the error should name the features missing privileges, but got %s", err)
+	}
+
+	if len(privs.Errors) != 2 || !strings.Contains(privs.Features[SlowQueryLog.Name], "SYSTEM_VARIABLES_ADMIN or SUPER on *.*") {
+		t.Errorf("CheckPrivileges should report the missing privileges of each feature, but got %+v", privs)
+	}
+}
@@ -1,12 +1,14 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"gopherDigest/pkg/config"
-	"log"
+	"net"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/fatih/color"
@@ -18,95 +20,138 @@ type MySQL struct {
 	password       string
 	host           string
 	port           int
+	socket         string
 	database       string
 	maxConnections int
+	readOnly       bool
 }
 
-func enableSlowQueryLogs(db *sql.DB) error {
-	_, err := printExec(db, []string{
-		"USE mysql",
-		"SET @@GLOBAL.slow_query_log = 'ON'",
-		"SET long_query_time = 0",
-		"SET @@GLOBAL.long_query_time = 0",
-		"SET @@GLOBAL.log_slow_admin_statements = 'ON'",
-		"SET @@GLOBAL.log_slow_slave_statements = 'ON'",
-		"SET sql_log_off = 'ON'",
-		"SET @@GLOBAL.sql_log_off = 'ON'",
-		"SET @@GLOBAL.log_queries_not_using_indexes = 'ON'",
-	})
+// slowQueryGlobals are the global variables that log every statement to the slow query log
+var slowQueryGlobals = [][2]string{
+	{"slow_query_log", "'ON'"},
+	{"long_query_time", "0"},
+	{"log_slow_admin_statements", "'ON'"},
+	{"log_slow_slave_statements", "'ON'"},
+	{"sql_log_off", "'ON'"},
+	{"log_queries_not_using_indexes", "'ON'"},
+}
 
-	return err
+// slowQuerySession are the statements that log every statement of the session to the slow query log
+var slowQuerySession = []string{
+	"USE mysql",
+	"SET long_query_time = 0",
+	"SET sql_log_off = 'ON'",
 }
 
-// New creates a new MySQL Database configuration
-func New(dbname string, args ...string) MySQL {
-	port, _ := strconv.Atoi(args[3])
-	maxConn, _ := strconv.Atoi(args[4])
+// enableSlowQueryLogs logs every statement to the slow query log, recording the original value
+// of each global variable in a snapshot before it is changed
+func enableSlowQueryLogs(db *sql.DB, snap *Snapshot) error {
+	_, err := printExec(db, slowQuerySession)
+
+	if err != nil {
+		return err
+	}
+
+	for _, global := range slowQueryGlobals {
+		if err := snap.SetGlobal(db, global[0], global[1]); err != nil {
+			return err
+		}
+	}
 
+	return nil
+}
+
+// New creates a new MySQL Database configuration for a database of a target server
+func New(dbname string, t config.MySQLTarget) MySQL {
 	return MySQL{
-		user: args[0], password: args[1], host: args[2], port: port, database: dbname, maxConnections: maxConn,
+		user: t.User, password: t.Password, host: t.Host, port: t.Port, socket: t.Socket, database: dbname,
+		maxConnections: t.MaxConnections, readOnly: t.ReadOnly,
 	}
 }
 
-// CheckConnection checks for connectivity to external services with the ability to retry connections
-func CheckConnection(d *sql.DB, totalRetries, remainingRetries int) (*config.Health, error) {
-	stat := &config.Health{}
-	red := color.New(color.FgRed, color.Bold)
-	green := color.New(color.FgGreen, color.Bold)
+// CheckConnection checks for connectivity to the server of a configuration, retrying the connection
+// with a backoff, and reports the server's address, port, socket, version and TLS state. A
+// configured socket that is not found locally is reported in the errors of the check, but does
+// not fail it.
+func CheckConnection(ctx context.Context, m MySQL, d *sql.DB, b config.Backoff) (*config.Health, error) {
+	stat, err := b.Retry(ctx, "MySQL", func(ctx context.Context) (*config.Health, error) {
+		stat := &config.Health{Service: "MySQL", Endpoint: m.Address()}
+		addrs, err := net.DefaultResolver.LookupHost(ctx, m.host)
 
-	color.New(color.Bold).Println("Checking MySQL Connection Status")
+		if err != nil {
+			return stat, err
+		}
 
-	defer func() {
-		if r := recover(); r != nil {
-			next := remainingRetries - 1
+		stat.Address = addrs[0]
+		start := time.Now()
 
-			fmt.Printf("could not connect to MySQL on the first attempt, attempting to reconnect:\n%d retry attempt(s) remaining\n\n", next+1)
+		if err := d.PingContext(ctx); err != nil {
+			return stat, err
+		}
 
-			time.Sleep(15 * time.Second)
+		stat.Latency = time.Since(start)
 
-			if next < 0 {
-				log.Fatal("could not connect to MySQL database")
-			}
+		return stat, nil
+	})
 
-			CheckConnection(d, totalRetries, next)
+	if err != nil {
+		return stat, err
+	}
+
+	if err := serverMetadata(ctx, d, stat); err != nil {
+		stat.Errors = append(stat.Errors, config.NewCheckError(config.ErrMetadata, 0, err))
+	}
+
+	// the socket belongs to mysqld, which often runs in another container or host, and gopherDigest
+	// connects over TCP, so a socket missing here is reported without failing the check
+	if m.socket != "" {
+		if _, err := os.Stat(m.socket); err != nil {
+			stat.Errors = append(stat.Errors, config.NewCheckError(config.ErrSocket, 0, err))
 		}
-	}()
+	}
+
+	return stat, nil
+}
+
+// serverMetadata reads the name, port, socket, version and TLS cipher the server reports
+func serverMetadata(ctx context.Context, d *sql.DB, stat *config.Health) error {
+	row := d.QueryRowContext(ctx, "SELECT @@hostname, @@port, @@socket, VERSION()")
 
-	if err := d.Ping(); err != nil {
-		stat.Conn = red.Sprint(" CLOSED")
-		stat.Port = red.Sprint(" NOT FOUND")
-		stat.Errors = append(stat.Errors, fmt.Errorf("could not connect to DB\n%v", d.Stats()))
-		panic(fmt.Sprintf("could not connect to database on attempt #%d %v", remainingRetries, err))
+	if err := row.Scan(&stat.Server, &stat.Port, &stat.Socket, &stat.Version); err != nil {
+		return fmt.Errorf("could not read the server variables\n%s", err)
 	}
 
-	sock, err := os.Stat(os.Getenv("MYSQL_SOCKET"))
+	var name, cipher string
 
-	if err != nil {
-		stat.Errors = append(stat.Errors, fmt.Errorf("could not connect to DB\n%v", err))
-		stat.Socket = red.Sprintf(" %s", err)
-		return stat, fmt.Errorf("could not locate the MySQL file\n%s", err)
+	if err := d.QueryRowContext(ctx, "SHOW SESSION STATUS LIKE 'Ssl_cipher'").Scan(&name, &cipher); err != nil {
+		return fmt.Errorf("could not read the TLS state of the connection\n%s", err)
 	}
 
-	stat.Conn = green.Sprint(" OPEN")
-	stat.Port = green.Sprint(" 3306")
-	stat.Socket = green.Sprintf(" %s", sock.Name())
+	stat.TLS = cipher
 
-	return stat, nil
+	if cipher == "" {
+		stat.TLS = "disabled"
+	}
+
+	return nil
 }
 
-// Init initializes the MySQL Database connection
-func Init(m MySQL) (*sql.DB, error) {
+// Init initializes the MySQL Database connection and enables slow query logging, recording the
+// original values of the global variables it changes in a snapshot. If it fails after changing
+// any of them, they are restored. In read-only mode the statements that would change the server
+// are printed instead, and the snapshot is not used.
+func Init(ctx context.Context, m MySQL, snap *Snapshot) (*sql.DB, error) {
 
 	db, err := Connect(m)
-	defer db.Close()
 
 	if err != nil {
 		return nil, fmt.Errorf("could not open database connection\n%s", err)
 	}
 
-	conn, err := CheckConnection(db, 10, 10)
+	conn, err := CheckConnection(ctx, m, db, config.DefaultBackoff)
 
 	if err != nil {
+		db.Close()
 		return nil, fmt.Errorf("could not maintain database connection\n%s", err)
 	}
 
@@ -115,10 +160,39 @@ func Init(m MySQL) (*sql.DB, error) {
 	})
 
 	if err != nil {
+		db.Close()
 		return nil, fmt.Errorf("could not find the 'mysql' database schema\n%s", err)
 	}
 
-	_, err = db.Exec(fmt.Sprintf("SET global max_connections = %d", m.maxConnections))
+	if m.readOnly {
+		PrintSkipped(fmt.Sprintf("SET @@GLOBAL.max_connections = %d", m.maxConnections))
+		conn.PrintStatus(os.Stdout)
+
+		for _, s := range slowQuerySession {
+			PrintSkipped(s)
+		}
+
+		for _, global := range slowQueryGlobals {
+			PrintSkipped(fmt.Sprintf("SET @@GLOBAL.%s = %s", global[0], global[1]))
+		}
+
+		return db, nil
+	}
+
+	// fail before changing any global, rather than part way through
+	color.New(color.Bold).Println("Checking MySQL Account Privileges")
+	privs, err := CheckPrivileges(db, SlowQueryLog)
+
+	if privs != nil {
+		privs.PrintStatus(os.Stdout)
+	}
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	err = snap.SetGlobal(db, "max_connections", strconv.Itoa(m.maxConnections))
 
 	if err != nil {
 		fmt.Printf("could not set max_connections for SQL database\n%s\n", err)
@@ -126,9 +200,14 @@ func Init(m MySQL) (*sql.DB, error) {
 
 	conn.PrintStatus(os.Stdout)
 
-	err = enableSlowQueryLogs(db)
+	err = enableSlowQueryLogs(db, snap)
 
 	if err != nil {
+		if rerr := snap.Restore(db); rerr != nil {
+			err = fmt.Errorf("%s\n%s", err, rerr)
+		}
+
+		db.Close()
 		return nil, fmt.Errorf("could not enable slow query logs\n%s", err)
 	}
 
@@ -180,7 +259,38 @@ func Connect(m MySQL) (*sql.DB, error) {
 	return db, nil
 }
 
+// Address identifies the server of the configuration as host:port
+func (m MySQL) Address() string {
+	return fmt.Sprintf("%s:%d", m.host, m.port)
+}
+
+// ReadOnly determines whether statements that change the server must not be executed
+func (m MySQL) ReadOnly() bool {
+	return m.readOnly
+}
+
 // GetMaxConns gets the maximum number of open connections allowed for the database configuration.
 func (m MySQL) GetMaxConns() int {
 	return m.maxConnections
 }
+
+// Password gets the password of the MySQL user
+func (m MySQL) Password() string {
+	return m.password
+}
+
+// PerconaDSN formats the configuration as a Percona Toolkit DSN, such as h=localhost,P=3306,u=root.
+// The password is left out since the DSN is passed on the command line, where other users can read it.
+func (m MySQL) PerconaDSN() string {
+	parts := []string{"h=" + m.host, "P=" + strconv.Itoa(m.port)}
+
+	if m.user != "" {
+		parts = append(parts, "u="+m.user)
+	}
+
+	if m.database != "" {
+		parts = append(parts, "D="+m.database)
+	}
+
+	return strings.Join(parts, ",")
+}
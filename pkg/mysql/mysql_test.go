@@ -1 +1,131 @@
 package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"gopherDigest/pkg/config"
+	"gopherDigest/pkg/mysql/mysqltest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPerconaDSN(t *testing.T) {
+	tt := []struct {
+		name     string
+		config   MySQL
+		expected string
+	}{
+		{"Full", New("employees", config.MySQLTarget{User: "root", Password: "secret", Host: "127.0.0.1", Port: 3306, MaxConnections: 151}),
+			"h=127.0.0.1,P=3306,u=root,D=employees"},
+		{"Without Credentials Or Database", New("", config.MySQLTarget{Host: "localhost", Port: 3307}), "h=localhost,P=3307"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := tc.config.PerconaDSN(); actual != tc.expected {
+				t.Errorf("PerconaDSN should be %q, but got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestNewReadOnly(t *testing.T) {
+	tt := []struct {
+		name     string
+		target   config.MySQLTarget
+		expected bool
+	}{
+		{"Unset", config.MySQLTarget{User: "root", Host: "localhost", Port: 3306, MaxConnections: 151}, false},
+		{"True", config.MySQLTarget{User: "root", Host: "localhost", Port: 3306, MaxConnections: 151, ReadOnly: true}, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := New("employees", tc.target).ReadOnly(); actual != tc.expected {
+				t.Errorf("ReadOnly should be %t, but got %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCheckConnection(t *testing.T) {
+	sock, err := ioutil.TempFile("", "mysqld.sock")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(sock.Name())
+	sock.Close()
+
+	db, _ := mysqltest.NewDB(t, map[string]mysqltest.Response{
+		"SELECT @@hostname": {
+			Columns: []string{"@@hostname", "@@port", "@@socket", "VERSION()"},
+			Rows:    [][]driver.Value{{[]byte("db"), int64(3306), []byte("/var/run/mysqld/mysqld.sock"), []byte("8.0.21")}},
+		},
+		"SHOW SESSION STATUS LIKE 'Ssl_cipher'": {
+			Columns: []string{"Variable_name", "Value"},
+			Rows:    [][]driver.Value{{[]byte("Ssl_cipher"), []byte("")}},
+		},
+	})
+
+	defer db.Close()
+
+	stat, err := CheckConnection(context.Background(), New("", config.MySQLTarget{User: "root", Host: "127.0.0.1", Port: 3306, Socket: sock.Name(), MaxConnections: 151}), db, config.Backoff{MaxAttempts: 1})
+
+	if err != nil {
+		t.Fatalf("CheckConnection should not return an error, but got %s", err)
+	}
+
+	expected := config.Health{
+		Service: "MySQL", Endpoint: "127.0.0.1:3306", Address: "127.0.0.1", Port: 3306, Socket: "/var/run/mysqld/mysqld.sock",
+		Server: "db", Version: "8.0.21", TLS: "disabled", Connected: true, Errors: []error{},
+	}
+
+	stat.Latency = 0
+
+	if !reflect.DeepEqual(*stat, expected) {
+		t.Errorf("CheckConnection should report %+v, but got %+v", expected, *stat)
+	}
+}
+
+func TestCheckConnectionSocket(t *testing.T) {
+	tt := []struct {
+		name   string
+		socket string
+		errors int
+	}{
+		{"Empty", "", 0},
+		{"Missing", filepath.Join(os.TempDir(), "gopherDigest-missing", "mysqld.sock"), 1},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			db, _ := mysqltest.NewDB(t, map[string]mysqltest.Response{
+				"SELECT @@hostname": {
+					Columns: []string{"@@hostname", "@@port", "@@socket", "VERSION()"},
+					Rows:    [][]driver.Value{{[]byte("db"), int64(3306), []byte("/var/run/mysqld/mysqld.sock"), []byte("8.0.21")}},
+				},
+				"SHOW SESSION STATUS LIKE 'Ssl_cipher'": {
+					Columns: []string{"Variable_name", "Value"},
+					Rows:    [][]driver.Value{{[]byte("Ssl_cipher"), []byte("")}},
+				},
+			})
+
+			defer db.Close()
+
+			stat, err := CheckConnection(context.Background(), New("", config.MySQLTarget{User: "root", Host: "127.0.0.1", Port: 3306, Socket: tc.socket}), db, config.Backoff{MaxAttempts: 1})
+
+			if err != nil || !stat.Connected {
+				t.Fatalf("CheckConnection should not fail on a socket that is not found locally, but got %+v, %v", stat, err)
+			}
+
+			if len(stat.Errors) != tc.errors {
+				t.Errorf("CheckConnection should report %d socket errors, but got %v", tc.errors, stat.Errors)
+			}
+		})
+	}
+}
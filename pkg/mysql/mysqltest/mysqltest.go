@@ -0,0 +1,193 @@
+// Package mysqltest provides a fake MySQL database/sql driver, answering statements with canned
+// responses, so code reading from MySQL can be tested without a server
+package mysqltest
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Response is the canned result of a statement sent to a Server
+type Response struct {
+	Columns []string
+	Rows    [][]driver.Value
+	Err     error
+}
+
+// Server answers statements with canned responses and records every statement it receives
+type Server struct {
+	mu        sync.Mutex
+	responses map[string]Response
+	executed  []string
+}
+
+// fakeDriver is a database/sql driver that routes connections to registered Servers by DSN
+type fakeDriver struct {
+	mu      sync.Mutex
+	servers map[string]*Server
+}
+
+var fakes = &fakeDriver{servers: map[string]*Server{}}
+
+func init() {
+	sql.Register("fakemysql", fakes)
+}
+
+// NewDB opens a database handle whose statements are answered from a set of responses keyed by
+// statement prefix, with whitespace collapsed
+func NewDB(t testing.TB, responses map[string]Response) (*sql.DB, *Server) {
+	srv := &Server{responses: map[string]Response{}}
+
+	for stmt, res := range responses {
+		srv.responses[normalizeSpace(stmt)] = res
+	}
+
+	fakes.mu.Lock()
+	fakes.servers[t.Name()] = srv
+	fakes.mu.Unlock()
+
+	db, err := sql.Open("fakemysql", t.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	return db, srv
+}
+
+// Statements returns every statement the server has received
+func (s *Server) Statements() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.executed...)
+}
+
+// SetResponse replaces the response to a statement prefix, so later queries see new results
+func (s *Server) SetResponse(stmt string, res Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses[normalizeSpace(stmt)] = res
+}
+
+// respond finds the response to the longest matching statement prefix
+func (s *Server) respond(query string) (Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query = normalizeSpace(query)
+	s.executed = append(s.executed, query)
+
+	match, found := "", false
+
+	for prefix := range s.responses {
+		if strings.HasPrefix(query, prefix) && len(prefix) >= len(match) {
+			match, found = prefix, true
+		}
+	}
+
+	if !found {
+		return Response{}, fmt.Errorf("unexpected statement %q", query)
+	}
+
+	res := s.responses[match]
+
+	return res, res.Err
+}
+
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	srv, ok := d.servers[dsn]
+
+	if !ok {
+		return nil, fmt.Errorf("no fake server registered for %s", dsn)
+	}
+
+	return &fakeConn{srv: srv}, nil
+}
+
+type fakeConn struct {
+	srv *Server
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{srv: c.srv, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("transactions are not supported")
+}
+
+type fakeStmt struct {
+	srv   *Server
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if _, err := s.srv.respond(s.query); err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(0), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	res, err := s.srv.respond(s.query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &fakeRows{columns: res.Columns, rows: res.Rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[r.next])
+	r.next++
+
+	return nil
+}
@@ -0,0 +1,203 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gopherDigest/pkg/slowlog"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Tables the processlist can be sampled from
+const (
+	ProcesslistTable = "processlist"
+	ThreadsTable     = "threads"
+)
+
+// processlistQueries select the running statements of other connections, in the same column
+// order, from information_schema.PROCESSLIST and performance_schema.threads
+var processlistQueries = map[string]string{
+	ProcesslistTable: `
+		SELECT ID, USER, HOST, DB, COMMAND, TIME, STATE, INFO
+		FROM information_schema.PROCESSLIST
+		WHERE INFO IS NOT NULL AND COMMAND NOT IN ('Sleep', 'Daemon', 'Binlog Dump') AND ID <> CONNECTION_ID()`,
+	ThreadsTable: `
+		SELECT PROCESSLIST_ID, PROCESSLIST_USER, PROCESSLIST_HOST, PROCESSLIST_DB, PROCESSLIST_COMMAND,
+			PROCESSLIST_TIME, PROCESSLIST_STATE, PROCESSLIST_INFO
+		FROM performance_schema.threads
+		WHERE TYPE = 'FOREGROUND' AND PROCESSLIST_INFO IS NOT NULL
+			AND PROCESSLIST_COMMAND NOT IN ('Sleep', 'Daemon', 'Binlog Dump') AND PROCESSLIST_ID <> CONNECTION_ID()`,
+}
+
+// ProcesslistSampler polls the processlist and turns the statements it observes into the
+// query events a slow query log would record for them
+type ProcesslistSampler struct {
+	db      *sql.DB
+	query   string
+	running map[int64]*observation
+	now     func() time.Time
+}
+
+// observation tracks a statement seen running on a connection across samples
+type observation struct {
+	event    slowlog.Event
+	elapsed  int64
+	lastSeen time.Time
+	samples  int
+}
+
+// processRow represents a row of the processlist
+type processRow struct {
+	id                                   int64
+	user, host, db, command, state, info sql.NullString
+	elapsed                              sql.NullInt64
+}
+
+// NewProcesslistSampler creates a sampler polling either ProcesslistTable or ThreadsTable
+func NewProcesslistSampler(db *sql.DB, table string) (*ProcesslistSampler, error) {
+	query, ok := processlistQueries[table]
+
+	if !ok {
+		return nil, fmt.Errorf("cannot sample unknown processlist table %q, expected %s or %s", table, ProcesslistTable, ThreadsTable)
+	}
+
+	return &ProcesslistSampler{db: db, query: query, running: map[int64]*observation{}, now: time.Now}, nil
+}
+
+// Sample polls the processlist once and returns the statements that finished since the
+// previous sample. A statement has finished once its connection runs a different statement,
+// restarts the same one or is no longer running anything. Its start is estimated from the
+// TIME column when it was first seen, and it is assumed to finish halfway between the last
+// sample it was seen in and the sample that noticed it was gone.
+func (s *ProcesslistSampler) Sample() ([]slowlog.Event, error) {
+	rows, err := s.db.Query(s.query)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not sample the processlist\n%s", err)
+	}
+
+	defer rows.Close()
+
+	at := s.now().UTC()
+	finished := []slowlog.Event{}
+	seen := map[int64]bool{}
+
+	for rows.Next() {
+		var p processRow
+
+		if err := rows.Scan(&p.id, &p.user, &p.host, &p.db, &p.command, &p.elapsed, &p.state, &p.info); err != nil {
+			return nil, fmt.Errorf("could not scan the processlist\n%s", err)
+		}
+
+		seen[p.id] = true
+		o, ok := s.running[p.id]
+
+		if ok && o.event.Query == p.info.String && p.elapsed.Int64 >= o.elapsed {
+			o.elapsed = p.elapsed.Int64
+			o.lastSeen = at
+			o.samples++
+			o.event.Attributes["State"] = p.state.String
+			continue
+		}
+
+		if ok {
+			finished = append(finished, o.finish(at))
+		}
+
+		s.running[p.id] = newObservation(p, at)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not sample the processlist\n%s", err)
+	}
+
+	for id, o := range s.running {
+		if !seen[id] {
+			finished = append(finished, o.finish(at))
+			delete(s.running, id)
+		}
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		if finished[i].Time.Equal(finished[j].Time) {
+			return finished[i].ThreadID < finished[j].ThreadID
+		}
+
+		return finished[i].Time.Before(finished[j].Time)
+	})
+
+	return finished, nil
+}
+
+// Run samples the processlist every interval until the context is done, passing each
+// finished statement to emit
+func (s *ProcesslistSampler) Run(ctx context.Context, every time.Duration, emit func(slowlog.Event) error) error {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		events, err := s.Sample()
+
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			if err := emit(e); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// newObservation starts tracking a statement first seen at a sample
+func newObservation(p processRow, at time.Time) *observation {
+	host, ip := p.host.String, ""
+
+	// remote connections are reported as host:port
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if net.ParseIP(host) != nil {
+		ip = host
+	}
+
+	return &observation{
+		event: slowlog.Event{
+			Time:     at.Add(-time.Duration(p.elapsed.Int64) * time.Second),
+			User:     p.user.String,
+			Host:     host,
+			IP:       ip,
+			ThreadID: p.id,
+			Database: p.db.String,
+			Query:    p.info.String,
+			Attributes: map[string]string{
+				"Source": "processlist", "Command": p.command.String, "State": p.state.String,
+			},
+		},
+		elapsed:  p.elapsed.Int64,
+		lastSeen: at,
+		samples:  1,
+	}
+}
+
+// finish completes the event of a statement that was noticed to be gone at a sample
+func (o *observation) finish(at time.Time) slowlog.Event {
+	e := o.event
+	end := o.lastSeen.Add(at.Sub(o.lastSeen) / 2)
+
+	e.QueryTime = end.Sub(e.Time).Seconds()
+	e.Attributes["Samples"] = strconv.Itoa(o.samples)
+
+	return e
+}
@@ -0,0 +1,132 @@
+package mysql
+
+import (
+	"database/sql/driver"
+	"gopherDigest/pkg/mysql/mysqltest"
+	"gopherDigest/pkg/slowlog"
+	"testing"
+	"time"
+)
+
+// processlistColumns are the columns selected from information_schema.PROCESSLIST
+var processlistColumns = []string{"ID", "USER", "HOST", "DB", "COMMAND", "TIME", "STATE", "INFO"}
+
+// processRowValues builds a processlist row as the MySQL driver would return it
+func processRowValues(id int64, host string, elapsed int64, state, info string) []driver.Value {
+	return []driver.Value{id, []byte("app"), []byte(host), []byte("employees"), []byte("Query"), elapsed, []byte(state), []byte(info)}
+}
+
+func TestProcesslistSampler(t *testing.T) {
+	query := processlistQueries[ProcesslistTable]
+	db, srv := mysqltest.NewDB(t, map[string]mysqltest.Response{query: {Columns: processlistColumns}})
+
+	defer db.Close()
+
+	s, err := NewProcesslistSampler(db, ProcesslistTable)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Date(2023, 11, 2, 14, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return at }
+
+	samples := []struct {
+		rows     [][]driver.Value
+		finished []slowlog.Event
+	}{
+		{
+			rows: [][]driver.Value{
+				processRowValues(7, "10.0.0.12:53422", 2, "Sending data", "SELECT * FROM salaries"),
+				processRowValues(8, "localhost", 0, "starting", "SELECT 1"),
+			},
+		},
+		{
+			rows: [][]driver.Value{
+				processRowValues(7, "10.0.0.12:53422", 3, "Sending data", "SELECT * FROM salaries"),
+				processRowValues(8, "localhost", 0, "starting", "SELECT 2"),
+			},
+			finished: []slowlog.Event{{ThreadID: 8, Query: "SELECT 1", Time: at, QueryTime: 0.5}},
+		},
+		{
+			rows: [][]driver.Value{
+				processRowValues(7, "10.0.0.12:53422", 0, "starting", "SELECT * FROM salaries"),
+			},
+			finished: []slowlog.Event{
+				{ThreadID: 7, Query: "SELECT * FROM salaries", Time: at.Add(-2 * time.Second), QueryTime: 3.5},
+				{ThreadID: 8, Query: "SELECT 2", Time: at.Add(time.Second), QueryTime: 0.5},
+			},
+		},
+	}
+
+	for i, sample := range samples {
+		srv.SetResponse(query, mysqltest.Response{Columns: processlistColumns, Rows: sample.rows})
+
+		actual, err := s.Sample()
+
+		if err != nil {
+			t.Fatalf("Sample %d should not return an error, but got %s", i+1, err)
+		}
+
+		if len(actual) != len(sample.finished) {
+			t.Fatalf("Sample %d should finish %d statements, but got %+v", i+1, len(sample.finished), actual)
+		}
+
+		for j, expected := range sample.finished {
+			e := actual[j]
+
+			if e.ThreadID != expected.ThreadID || e.Query != expected.Query || !e.Time.Equal(expected.Time) || e.QueryTime != expected.QueryTime {
+				t.Errorf("Sample %d statement %d should be %+v, but got %+v", i+1, j+1, expected, e)
+			}
+		}
+
+		at = at.Add(time.Second)
+	}
+
+	srv.SetResponse(query, mysqltest.Response{Columns: processlistColumns})
+
+	finished, err := s.Sample()
+
+	if err != nil || len(finished) != 1 || finished[0].ThreadID != 7 || finished[0].Attributes["Samples"] != "1" {
+		t.Errorf("a restarted statement should be tracked separately from the first run, but got %+v, %v", finished, err)
+	}
+}
+
+func TestProcesslistSamplerEvent(t *testing.T) {
+	query := processlistQueries[ThreadsTable]
+	db, srv := mysqltest.NewDB(t, map[string]mysqltest.Response{
+		query: {Columns: processlistColumns, Rows: [][]driver.Value{
+			processRowValues(7, "10.0.0.12:53422", 1, "Sending data", "SELECT * FROM salaries"),
+		}},
+	})
+
+	defer db.Close()
+
+	s, err := NewProcesslistSampler(db, ThreadsTable)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Sample()
+	srv.SetResponse(query, mysqltest.Response{Columns: processlistColumns})
+
+	finished, err := s.Sample()
+
+	if err != nil || len(finished) != 1 {
+		t.Fatalf("Sample should finish the statement once it is gone, but got %+v, %v", finished, err)
+	}
+
+	e := finished[0]
+
+	if e.User != "app" || e.Host != "10.0.0.12" || e.IP != "10.0.0.12" || e.Database != "employees" ||
+		e.Attributes["Source"] != "processlist" || e.Attributes["Samples"] != "1" || e.Attributes["State"] != "Sending data" {
+		t.Errorf("Sample should describe the statement like a slow log event, but got %+v", e)
+	}
+}
+
+func TestNewProcesslistSamplerUnknownTable(t *testing.T) {
+	if _, err := NewProcesslistSampler(nil, "sys.session"); err == nil {
+		t.Errorf("NewProcesslistSampler should reject an unknown table")
+	}
+}
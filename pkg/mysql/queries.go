@@ -3,7 +3,9 @@ package mysql
 import (
 	"database/sql"
 	"fmt"
-	"gopherDigest/pkg/rethinkdb"
+	"gopherDigest/pkg/types"
+	"strings"
+	"unicode"
 )
 
 //CommandQueryVerifier ...
@@ -45,23 +47,23 @@ func ExplainScanRow(db *sql.DB, q string) string {
 }
 
 // ExplainScanRows runs a MySQL explain statement on a given query and scans
-// the results onto a destination
-func ExplainScanRows(db *sql.DB, query string) (*rethinkdb.SQLExplainRow, error) {
-	se := &rethinkdb.SQLExplainRow{}
-
+// every plan row, in plan order, onto a destination
+func ExplainScanRows(db *sql.DB, query string) ([]types.SQLExplainRow, error) {
 	rows, err := Explain(db, query)
-	// defer rows.Close()
 
 	if err != nil {
-		return se, fmt.Errorf("%s", err)
+		return []types.SQLExplainRow{}, fmt.Errorf("%s", err)
 	}
 
-	if err := ScanRows(rows, se); err != nil {
-		return se, fmt.Errorf("%s", err)
-	}
+	defer rows.Close()
 
-	return se, nil
+	seq, err := ScanRows(rows)
+
+	if err != nil {
+		return seq, fmt.Errorf("%s", err)
+	}
 
+	return seq, nil
 }
 
 // VerifyScan queries the database to determine if an assertion about a
@@ -73,16 +75,108 @@ func VerifyScan(db *sql.DB, statement, assertion string) (bool, error) {
 	return result == assertion, nil
 }
 
-// ScanRows scans a collections of rows onto a given destination
-func ScanRows(r *sql.Rows, se *rethinkdb.SQLExplainRow) error {
+// ScanRows scans a collection of explain rows onto a slice with one entry per row
+func ScanRows(r *sql.Rows) ([]types.SQLExplainRow, error) {
+	seq := []types.SQLExplainRow{}
+
 	for r.Next() {
+		se := types.SQLExplainRow{}
+
 		err := r.Scan(&se.ID, &se.SelectType, &se.Table,
 			&se.Partitions, &se.Ztype, &se.PossibleKeys, &se.Key,
 			&se.KeyLen, &se.Ref, &se.Rows, &se.Filtered, &se.Extra)
 		if err != nil {
-			return fmt.Errorf("failed to copy the row columns to the destination \n%s", err)
+			return seq, fmt.Errorf("failed to copy the row columns to the destination \n%s", err)
 		}
+
+		seq = append(seq, se)
 	}
 
-	return nil
+	if err := r.Err(); err != nil {
+		return seq, fmt.Errorf("failed to read the explain rows \n%s", err)
+	}
+
+	return seq, nil
+}
+
+// SplitStatements splits a script of ';' separated SQL statements into individual
+// statements, ignoring delimiters within quoted strings, identifiers and comments
+func SplitStatements(script string) []string {
+	statements := []string{}
+	src := []rune(script)
+
+	var stmt strings.Builder
+	var quote rune
+	hasCode := false
+
+	flush := func() {
+		if hasCode {
+			statements = append(statements, strings.TrimSpace(stmt.String()))
+		}
+
+		stmt.Reset()
+		hasCode = false
+	}
+
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+
+		switch {
+		case quote != 0:
+			stmt.WriteRune(c)
+
+			if c == '\\' && quote != '`' && i+1 < len(src) {
+				i++
+				stmt.WriteRune(src[i])
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			hasCode = true
+			stmt.WriteRune(c)
+		case c == '#' || (c == '-' && i+1 < len(src) && src[i+1] == '-' && (i+2 == len(src) || unicode.IsSpace(src[i+2]))):
+			for ; i < len(src) && src[i] != '\n'; i++ {
+				stmt.WriteRune(src[i])
+			}
+
+			if i < len(src) {
+				stmt.WriteRune(src[i])
+			}
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := i + 2
+
+			for end+1 < len(src) && !(src[end] == '*' && src[end+1] == '/') {
+				end++
+			}
+
+			if end+1 >= len(src) {
+				stmt.WriteString(string(src[i:]))
+				i = len(src)
+				break
+			}
+
+			comment := string(src[i : end+2])
+
+			// optimizer hints and executable comments are part of the statement
+			if strings.HasPrefix(comment, "/*+") || strings.HasPrefix(comment, "/*!") {
+				hasCode = true
+			}
+
+			stmt.WriteString(comment)
+			i = end + 1
+		case c == ';':
+			flush()
+		default:
+			if !unicode.IsSpace(c) {
+				hasCode = true
+			}
+
+			stmt.WriteRune(c)
+		}
+	}
+
+	flush()
+
+	return statements
 }
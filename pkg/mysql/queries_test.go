@@ -1 +1,102 @@
 package mysql
+
+import (
+	"database/sql/driver"
+	"gopherDigest/pkg/mysql/mysqltest"
+	"reflect"
+	"testing"
+)
+
+// explainColumns are the columns of a traditional tabular EXPLAIN
+var explainColumns = []string{"id", "select_type", "table", "partitions", "type", "possible_keys",
+	"key", "key_len", "ref", "rows", "filtered", "Extra"}
+
+// explainRow builds a tabular EXPLAIN row as the MySQL driver would return it
+func explainRow(table, accessType, key string, rows int64) []driver.Value {
+	var k driver.Value
+
+	if key != "" {
+		k = []byte(key)
+	}
+
+	return []driver.Value{int64(1), []byte("SIMPLE"), []byte(table), nil, []byte(accessType), k,
+		k, nil, nil, rows, []byte("100.00"), nil}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tt := []struct {
+		name     string
+		script   string
+		expected []string
+	}{
+		{"Single Statement", "SELECT 1", []string{"SELECT 1"}},
+		{"Trailing Delimiter", "SELECT 1;\n", []string{"SELECT 1"}},
+		{"Multiple Statements", "SELECT 1;\nSELECT * FROM employees;\n\nSELECT 2 ;",
+			[]string{"SELECT 1", "SELECT * FROM employees", "SELECT 2"}},
+		{"Empty Script", " ;\n; ", []string{}},
+		{"Quoted Delimiters", `SELECT ';', ";", ` + "`a;b`" + ` FROM t; SELECT 'it''s;'`,
+			[]string{`SELECT ';', ";", ` + "`a;b`" + ` FROM t`, `SELECT 'it''s;'`}},
+		{"Escaped Quote", `SELECT 'a\';b'; SELECT 2`, []string{`SELECT 'a\';b'`, "SELECT 2"}},
+		{"Line Comments", "-- first; query\nSELECT 1; # trailing; comment\nSELECT 2;\n-- done;",
+			[]string{"-- first; query\nSELECT 1", "# trailing; comment\nSELECT 2"}},
+		{"Block Comments", "SELECT /* a; b */ 1; /* only a comment; */", []string{"SELECT /* a; b */ 1"}},
+		{"Optimizer Hint", "/*+ MAX_EXECUTION_TIME(1000) */ SELECT 1", []string{"/*+ MAX_EXECUTION_TIME(1000) */ SELECT 1"}},
+		{"Double Dash Without Space", "SELECT 1--1; SELECT 2", []string{"SELECT 1--1", "SELECT 2"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := SplitStatements(tc.script)
+
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("SplitStatements of %q should be %q, but got %q", tc.script, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExplainScanRows(t *testing.T) {
+	query := "SELECT * FROM salaries s LEFT JOIN employees e USING(emp_no) LEFT JOIN dept_emp d USING(emp_no)"
+
+	db, _ := mysqltest.NewDB(t, map[string]mysqltest.Response{
+		"EXPLAIN " + query: {Columns: explainColumns, Rows: [][]driver.Value{
+			explainRow("s", "ALL", "", 2838426),
+			explainRow("e", "eq_ref", "PRIMARY", 1),
+			explainRow("d", "ref", "PRIMARY", 1),
+		}},
+	})
+
+	defer db.Close()
+
+	seq, err := ExplainScanRows(db, query)
+
+	if err != nil {
+		t.Fatalf("ExplainScanRows should not return an error, but got %s", err)
+	}
+
+	expected := []string{"s", "e", "d"}
+
+	if len(seq) != len(expected) {
+		t.Fatalf("ExplainScanRows should return %d rows, but got %d", len(expected), len(seq))
+	}
+
+	for i, table := range expected {
+		if seq[i].Table == nil || *seq[i].Table != table {
+			t.Errorf("ExplainScanRows row %d should be table %s, but got %v", i, table, seq[i].Table)
+		}
+	}
+
+	if seq[0].Key != nil || *seq[1].Key != "PRIMARY" || seq[0].Rows != 2838426 || seq[2].Filtered == nil || *seq[2].Filtered != 100 {
+		t.Errorf("ExplainScanRows should scan every column of each row, but got %+v", seq)
+	}
+}
+
+func TestExplainScanRowsError(t *testing.T) {
+	db, _ := mysqltest.NewDB(t, map[string]mysqltest.Response{})
+
+	defer db.Close()
+
+	if _, err := ExplainScanRows(db, "SELECT 1"); err == nil {
+		t.Errorf("ExplainScanRows should return the EXPLAIN error")
+	}
+}
@@ -0,0 +1,64 @@
+package mysql
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	leadingNoise = regexp.MustCompile(`^(?:\s+|\(|/\*.*?\*/|(?:--\s|#)[^\n]*(?:\n|$))`)
+	firstWord    = regexp.MustCompile(`^[A-Za-z]+`)
+	writesFile   = regexp.MustCompile(`(?i)\bINTO\s+(?:OUTFILE|DUMPFILE)\b`)
+	locksRows    = regexp.MustCompile(`(?i)\bFOR\s+UPDATE\b`)
+	cteChanges   = regexp.MustCompile(`(?i)\)\s*(?:UPDATE|DELETE|INSERT|REPLACE)\b`)
+	analyzed     = regexp.MustCompile(`(?is)^ANALYZE\s+(?:FORMAT\s*=\s*\w+\s+)?(.*)$`)
+)
+
+// readOnlyStatements are the statements that never change data, variables or the schema
+var readOnlyStatements = map[string]bool{
+	"SELECT": true, "WITH": true, "TABLE": true, "VALUES": true,
+	"SHOW": true, "DESCRIBE": true, "DESC": true, "EXPLAIN": true, "HELP": true,
+}
+
+// IsReadOnly determines whether a statement only reads from the server, so it may run in
+// read-only mode. Statements that write files, lock rows or are executed by EXPLAIN ANALYZE
+// are judged by what they do, and anything unrecognized is assumed to write.
+func IsReadOnly(query string) bool {
+	for {
+		loc := leadingNoise.FindStringIndex(query)
+
+		if loc == nil {
+			break
+		}
+
+		query = query[loc[1]:]
+	}
+
+	keyword := strings.ToUpper(firstWord.FindString(query))
+
+	if !readOnlyStatements[keyword] {
+		return false
+	}
+
+	switch keyword {
+	case "EXPLAIN", "DESCRIBE", "DESC":
+		// only EXPLAIN ANALYZE executes the statement it explains
+		if m := analyzed.FindStringSubmatch(strings.TrimSpace(query[len(keyword):])); m != nil {
+			return IsReadOnly(m[1])
+		}
+
+		return true
+	case "WITH":
+		if cteChanges.MatchString(query) {
+			return false
+		}
+	}
+
+	return !writesFile.MatchString(query) && !locksRows.MatchString(query)
+}
+
+// PrintSkipped prints a statement that read-only mode does not execute, in the format of printExec
+func PrintSkipped(stmnt string) {
+	fmt.Printf("mysql> %s; -- skipped in read-only mode\n", stmnt)
+}
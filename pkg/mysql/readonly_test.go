@@ -0,0 +1,39 @@
+package mysql
+
+import "testing"
+
+func TestIsReadOnly(t *testing.T) {
+	tt := []struct {
+		name     string
+		query    string
+		expected bool
+	}{
+		{"Select", "SELECT * FROM employees", true},
+		{"Lowercase Select", "select 1", true},
+		{"Parenthesized Union", "(SELECT 1) UNION (SELECT 2)", true},
+		{"Leading Comments", "/* app:report */ -- nightly\n# tuned\nSELECT 1", true},
+		{"Show", "SHOW GLOBAL STATUS LIKE 'Uptime'", true},
+		{"Explain", "EXPLAIN UPDATE employees SET last_name = 'x'", true},
+		{"Explain Analyze Select", "EXPLAIN ANALYZE SELECT * FROM salaries", true},
+		{"Explain Analyze Delete", "EXPLAIN ANALYZE DELETE e FROM employees e JOIN salaries s USING(emp_no)", false},
+		{"Common Table Expression", "WITH s AS (SELECT emp_no FROM salaries) SELECT * FROM s", true},
+		{"Common Table Expression Update", "WITH s AS (SELECT emp_no FROM salaries) UPDATE employees e JOIN s USING(emp_no) SET e.hire_date = NOW()", false},
+		{"Select Into Outfile", "SELECT * FROM employees INTO OUTFILE '/tmp/employees.csv'", false},
+		{"Select For Update", "SELECT * FROM employees WHERE emp_no = 10001 FOR UPDATE", false},
+		{"Set Global", "SET @@GLOBAL.slow_query_log = 'ON'", false},
+		{"Set Session", "SET long_query_time = 0", false},
+		{"Use", "USE mysql", false},
+		{"Update", "UPDATE employees SET last_name = 'Facello'", false},
+		{"Create", "CREATE TABLE t (id INT)", false},
+		{"Call", "CALL current_managers()", false},
+		{"Empty", "", false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := IsReadOnly(tc.query); actual != tc.expected {
+				t.Errorf("IsReadOnly(%q) should be %t, but got %t", tc.query, tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package ptdigest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gopherDigest/pkg/types"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Input types accepted by pt-query-digest's --type option, besides the processlist
+const (
+	SlowLog     = "slowlog"
+	TCPDump     = "tcpdump"
+	Processlist = "processlist"
+)
+
+// Options defines how pt-query-digest is invoked and what it reads
+type Options struct {
+	// Binary is the pt-query-digest executable, looked up on the PATH by default
+	Binary string
+	// Type is the input type, one of SlowLog, TCPDump or Processlist
+	Type string
+	// Files are the slow logs or tcpdump captures to read, or stdin when empty
+	Files []string
+	// Stdin is read by pt-query-digest when no files are given
+	Stdin io.Reader
+	// DSN is the Percona DSN of the server whose processlist is polled, e.g. h=localhost,P=3306
+	DSN string
+	// Password is the password of the DSN's user, passed in a private defaults file instead of the DSN
+	Password string
+	// RunTime limits how long the processlist is polled
+	RunTime time.Duration
+	// Timeout kills pt-query-digest when it runs for longer, unless zero
+	Timeout time.Duration
+	// Args are passed to pt-query-digest after the generated arguments
+	Args []string
+}
+
+// args builds the pt-query-digest command line arguments for the options. The processlist
+// DSN reads the client options, such as the password, from the defaults file when set.
+func (o Options) args(defaultsFile string) ([]string, error) {
+	args := []string{"--output", "json"}
+
+	switch o.Type {
+	case SlowLog, TCPDump, "":
+		if o.DSN != "" {
+			return nil, fmt.Errorf("a DSN is only used when reading the processlist")
+		}
+
+		if o.Type != "" {
+			args = append(args, "--type", o.Type)
+		}
+	case Processlist:
+		if o.DSN == "" {
+			return nil, fmt.Errorf("reading the processlist requires a DSN")
+		}
+
+		if len(o.Files) > 0 {
+			return nil, fmt.Errorf("files cannot be read when reading the processlist")
+		}
+
+		dsn := o.DSN
+
+		if defaultsFile != "" {
+			dsn += ",F=" + defaultsFile
+		}
+
+		args = append(args, "--processlist", dsn)
+
+		if o.RunTime > 0 {
+			args = append(args, "--run-time", fmt.Sprintf("%ds", int(o.RunTime.Seconds())))
+		}
+	default:
+		return nil, fmt.Errorf("unknown pt-query-digest input type %q", o.Type)
+	}
+
+	args = append(args, o.Args...)
+
+	return append(args, o.Files...), nil
+}
+
+// source describes the input the options read
+func (o Options) source() string {
+	switch {
+	case o.Type == Processlist:
+		return "processlist"
+	case len(o.Files) > 0:
+		return strings.Join(o.Files, ",")
+	}
+
+	return "stdin"
+}
+
+// writeDefaults writes the password to a defaults file only readable by the current user
+func writeDefaults(password string) (string, error) {
+	f, err := ioutil.TempFile("", "pt-query-digest-*.cnf")
+
+	if err != nil {
+		return "", fmt.Errorf("could not create the pt-query-digest defaults file\n%s", err)
+	}
+
+	defer f.Close()
+
+	escaped := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(password)
+
+	if _, err := fmt.Fprintf(f, "[client]\npassword=\"%s\"\n", escaped); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("could not write the pt-query-digest defaults file\n%s", err)
+	}
+
+	return f.Name(), nil
+}
+
+// Run runs pt-query-digest and parses its JSON report. The run is killed when the
+// context is cancelled or the options' timeout elapses.
+func Run(ctx context.Context, o Options) (*types.DigestReport, error) {
+	defaultsFile := ""
+
+	if o.Type == Processlist && o.Password != "" {
+		path, err := writeDefaults(o.Password)
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer os.Remove(path)
+		defaultsFile = path
+	}
+
+	args, err := o.args(defaultsFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	binary := o.Binary
+
+	if binary == "" {
+		binary = "pt-query-digest"
+	}
+
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Stdin = o.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return nil, fmt.Errorf("pt-query-digest did not finish reading %s within %s", o.source(), o.Timeout)
+	case ctx.Err() == context.Canceled:
+		return nil, fmt.Errorf("pt-query-digest was cancelled while reading %s", o.source())
+	case err != nil:
+		return nil, fmt.Errorf("could not run pt-query-digest on %s\n%s\n%s", o.source(), err, strings.TrimSpace(stderr.String()))
+	}
+
+	report, err := Parse(stdout.Bytes())
+
+	if err != nil {
+		return nil, err
+	}
+
+	report.Source = o.source()
+
+	return report, nil
+}
+
+// Parse parses the report written by pt-query-digest --output json. Anything written
+// around the JSON document is ignored, and empty output is an empty report.
+func Parse(output []byte) (*types.DigestReport, error) {
+	report := &types.DigestReport{Classes: []types.DigestClass{}}
+
+	start := bytes.IndexByte(output, '{')
+
+	if start == -1 {
+		if len(bytes.TrimSpace(output)) > 0 {
+			return nil, fmt.Errorf("could not find a JSON report in the pt-query-digest output %q", output)
+		}
+
+		return report, nil
+	}
+
+	if err := json.NewDecoder(bytes.NewReader(output[start:])).Decode(report); err != nil {
+		return nil, fmt.Errorf("could not parse the pt-query-digest JSON report\n%s", err)
+	}
+
+	return report, nil
+}
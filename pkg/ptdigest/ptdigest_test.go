@@ -0,0 +1,274 @@
+package ptdigest
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useFakeBinary puts the fake pt-query-digest script in testdata first on the PATH
+func useFakeBinary(t *testing.T, mode string) (argsFile string, restore func()) {
+	dir, err := filepath.Abs("testdata")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmp, err := ioutil.TempDir("", "ptdigest")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := os.Getenv("PATH")
+	argsFile = filepath.Join(tmp, "args")
+
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	os.Setenv("FAKE_PT_MODE", mode)
+	os.Setenv("FAKE_PT_ARGS", argsFile)
+
+	return argsFile, func() {
+		os.Setenv("PATH", path)
+		os.Unsetenv("FAKE_PT_MODE")
+		os.Unsetenv("FAKE_PT_ARGS")
+		os.RemoveAll(tmp)
+	}
+}
+
+func TestRun(t *testing.T) {
+	argsFile, restore := useFakeBinary(t, "")
+	defer restore()
+
+	report, err := Run(context.Background(), Options{Type: SlowLog, Files: []string{"slow.log"}, Timeout: 5 * time.Second})
+
+	if err != nil {
+		t.Fatalf("Run should not return an error, but got %s", err)
+	}
+
+	if report.Source != "slow.log" || len(report.Classes) != 2 || report.Global.QueryCount != 2 {
+		t.Errorf("Run should return the report of slow.log with 2 classes, but got %+v", report)
+	}
+
+	args, err := ioutil.ReadFile(argsFile)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "--output json --type slowlog slow.log"; strings.TrimSpace(string(args)) != expected {
+		t.Errorf("Run should call pt-query-digest with %q, but got %q", expected, args)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tt := []struct {
+		name     string
+		mode     string
+		ctx      func() (context.Context, context.CancelFunc)
+		timeout  time.Duration
+		expected string
+	}{
+		{"Failure", "fail", func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+			0, "Cannot open slow.log"},
+		{"Timeout", "hang", func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+			100 * time.Millisecond, "did not finish reading slow.log within 100ms"},
+		{"Cancelled", "hang", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(100*time.Millisecond, cancel)
+			return ctx, cancel
+		}, 0, "was cancelled while reading slow.log"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, restore := useFakeBinary(t, tc.mode)
+			defer restore()
+
+			ctx, cancel := tc.ctx()
+			defer cancel()
+
+			start := time.Now()
+			_, err := Run(ctx, Options{Files: []string{"slow.log"}, Timeout: tc.timeout})
+
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Run should return an error containing %q, but got %v", tc.expected, err)
+			}
+
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("Run should stop pt-query-digest promptly, but took %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestRunEmpty(t *testing.T) {
+	_, restore := useFakeBinary(t, "empty")
+	defer restore()
+
+	report, err := Run(context.Background(), Options{})
+
+	if err != nil || len(report.Classes) != 0 || report.Source != "stdin" {
+		t.Errorf("Run without any events should return an empty report of stdin, but got %+v, %v", report, err)
+	}
+}
+
+func TestArgs(t *testing.T) {
+	tt := []struct {
+		name     string
+		options  Options
+		expected []string
+		isValid  bool
+	}{
+		{"Default", Options{}, []string{"--output", "json"}, true},
+		{"TCPDump", Options{Type: TCPDump, Files: []string{"a.txt", "b.txt"}, RunTime: time.Minute},
+			[]string{"--output", "json", "--type", "tcpdump", "a.txt", "b.txt"}, true},
+		{"Processlist", Options{Type: Processlist, DSN: "h=localhost,P=3306", RunTime: time.Minute, Args: []string{"--limit", "5"}},
+			[]string{"--output", "json", "--processlist", "h=localhost,P=3306", "--run-time", "60s", "--limit", "5"}, true},
+		{"Processlist Without DSN", Options{Type: Processlist}, nil, false},
+		{"Processlist With Files", Options{Type: Processlist, DSN: "h=localhost", Files: []string{"slow.log"}}, nil, false},
+		{"DSN Without Processlist", Options{Type: SlowLog, DSN: "h=localhost"}, nil, false},
+		{"Unknown Type", Options{Type: "binlog"}, nil, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := tc.options.args("")
+
+			if (err == nil) != tc.isValid {
+				t.Fatalf("args validity should be %v, but got error %v", tc.isValid, err)
+			}
+
+			if tc.isValid && !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("args should be %q, but got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestArgsPassword(t *testing.T) {
+	o := Options{Type: Processlist, DSN: "h=localhost,P=3306,u=root", Password: "secret"}
+	args, err := o.args("/tmp/pt-query-digest.cnf")
+
+	if err != nil {
+		t.Fatalf("args should not return an error, but got %s", err)
+	}
+
+	for _, arg := range args {
+		if strings.Contains(arg, o.Password) {
+			t.Errorf("args should not contain the password, but got %q", args)
+		}
+	}
+
+	if expected := "h=localhost,P=3306,u=root,F=/tmp/pt-query-digest.cnf"; args[3] != expected {
+		t.Errorf("args should read the password from the defaults file with the DSN %q, but got %q", expected, args[3])
+	}
+}
+
+func TestRunPassword(t *testing.T) {
+	argsFile, restore := useFakeBinary(t, "")
+	defer restore()
+
+	_, err := Run(context.Background(), Options{Type: Processlist, DSN: "h=localhost", Password: "secret"})
+
+	if err != nil {
+		t.Fatalf("Run should not return an error, but got %s", err)
+	}
+
+	args, err := ioutil.ReadFile(argsFile)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(args), "secret") || !strings.Contains(string(args), ",F=") {
+		t.Errorf("Run should pass the password in a defaults file, but got %q", args)
+	}
+
+	defaultsFile := strings.TrimSpace(string(args)[strings.Index(string(args), ",F=")+3:])
+
+	if _, err := os.Stat(defaultsFile); !os.IsNotExist(err) {
+		t.Errorf("Run should remove the defaults file %s, but got %v", defaultsFile, err)
+	}
+}
+
+func TestRunStdin(t *testing.T) {
+	argsFile, restore := useFakeBinary(t, "")
+	defer restore()
+
+	stdinFile := argsFile + ".stdin"
+	os.Setenv("FAKE_PT_STDIN", stdinFile)
+	defer os.Unsetenv("FAKE_PT_STDIN")
+
+	slowLog := "# Time: 2023-11-02T14:03:11.000000Z\n# Query_time: 2.345678  Lock_time: 0.000101 Rows_sent: 1  Rows_examined: 2844047\nSELECT COUNT(*) FROM salaries;\n"
+	report, err := Run(context.Background(), Options{Type: SlowLog, Stdin: strings.NewReader(slowLog)})
+
+	if err != nil || report.Source != "stdin" {
+		t.Fatalf("Run should return the report of stdin, but got %+v, %v", report, err)
+	}
+
+	input, err := ioutil.ReadFile(stdinFile)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(input) != slowLog {
+		t.Errorf("Run should pipe the slow log into pt-query-digest, but it read %q", input)
+	}
+}
+
+func TestParse(t *testing.T) {
+	output, err := ioutil.ReadFile("testdata/report.json")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Parse(output)
+
+	if err != nil {
+		t.Fatalf("Parse should not return an error, but got %s", err)
+	}
+
+	if report.Global.UniqueQueryCount != 2 || report.Global.Files[0].Name != "slow.log" || report.Global.Metrics["Query_time"].Sum != 2.345888 {
+		t.Errorf("Parse should read the global metrics, but got %+v", report.Global)
+	}
+
+	c := report.Classes[0]
+
+	if c.Checksum != "8FC15871FA4B3998" || c.QueryCount != 1 || c.Example.QueryTime != 2.345678 || c.TsMin != "2023-11-02 14:03:11" {
+		t.Errorf("Parse should read the class attributes, but got %+v", c)
+	}
+
+	if m := c.Metrics["Rows_examined"]; m.Max != 2844047 || m.Pct95 != 2844047 || m.Pct != 0.5 {
+		t.Errorf("Parse should read quoted metrics, but got %+v", m)
+	}
+
+	if c.Metrics["db"].Value != "employees" || len(c.Tables) != 2 || c.Histograms["Query_time"][5] != 1 {
+		t.Errorf("Parse should read string metrics, tables and histograms, but got %+v", c)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tt := []struct {
+		name   string
+		output string
+	}{
+		{"Text Report", "# 120ms user time, 10ms system time\n"},
+		{"Truncated", `{"classes": [{"checksum": "8FC1`},
+		{"Bad Metric", `{"classes": [{"metrics": {"Query_time": {"sum": "slow"}}}]}`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tc.output)); err == nil {
+				t.Errorf("Parse of %q should return an error", tc.output)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package rethinkdb
+
+import (
+	"fmt"
+	"gopherDigest/pkg/format"
+
+	r "gopkg.in/gorethink/gorethink.v4"
+)
+
+// migrationsTable records the schema version of a database, with a document per applied migration
+const migrationsTable = "Migrations"
+
+// table is a table of the schema and the fields it has secondary indexes on
+type table struct {
+	name    string
+	indexes []string
+}
+
+// migration is a change to the schema of a database, described by the tables and indexes it
+// results in, so applying it again only creates what is missing
+type migration struct {
+	description string
+	tables      []table
+}
+
+// migrations are the schema changes of the store, in order. The schema version of a database is
+// the number of migrations applied to it, so a migration must never be changed once released,
+// only followed by another.
+var migrations = []migration{
+	// 1: the tables gopherDigest created before its schema was versioned
+	{"create the Queries, Digests and DigestIntervals tables", []table{
+		{name: "Queries"}, {name: "Digests"}, {name: "DigestIntervals"},
+	}},
+
+	// 2: read captured plans by query, pt-query-digest runs by source and digest intervals by
+	// schema, each by time. Captured plans did not record the schema of their query yet, which
+	// migration 4 indexes.
+	{"index plans, runs and digest intervals", []table{
+		{"Queries", []string{"Fingerprint", "Checksum", "Timestamp"}},
+		{"Digests", []string{"Source", "Timestamp"}},
+		{"DigestIntervals", []string{"Schema", "Digest", "End"}},
+	}},
+
+	// 3: baseline digest loads that later runs are compared against, read by name
+	{"create the Baselines table", []table{
+		{"Baselines", []string{"Name", "Schema", "Digest", "Timestamp"}},
+	}},
+
+	// 4: read captured plans by the schema their query ran in
+	{"index plans by schema", []table{
+		{"Queries", []string{"Schema"}},
+	}},
+}
+
+// SchemaVersion is the schema version of the databases this version of gopherDigest writes
+var SchemaVersion = len(migrations)
+
+// schema applies migrations to a database, tracking the tables it holds
+type schema struct {
+	session r.QueryExecutor
+	db      string
+	tables  []string
+}
+
+// version reads the schema version of a database, the highest migration recorded in it, where a
+// database without migrations has version 0
+func version(s r.QueryExecutor, db string) (int, error) {
+	var v int
+
+	if err := r.DB(db).Table(migrationsTable).Max("id").Field("id").Default(0).ReadOne(&v, s); err != nil {
+		return 0, fmt.Errorf("could not read the schema version of the RethinkDB database %s\n%s", db, err)
+	}
+
+	return v, nil
+}
+
+// checkVersion verifies a database was migrated to the schema this version of gopherDigest writes
+func checkVersion(s r.QueryExecutor, db string) error {
+	v, err := version(s, db)
+
+	if err != nil {
+		return err
+	}
+
+	if v < SchemaVersion {
+		return fmt.Errorf("the RethinkDB database %s has schema version %d, run 'gopherDigest init' to migrate it to version %d", db, v, SchemaVersion)
+	}
+
+	if v > SchemaVersion {
+		return fmt.Errorf("the RethinkDB database %s has schema version %d, but this gopherDigest only knows version %d", db, v, SchemaVersion)
+	}
+
+	return nil
+}
+
+// migrate creates a database if it does not exist and applies the migrations it is missing,
+// recording each in the Migrations table, and returns the number applied
+func migrate(s r.QueryExecutor, db string) (int, error) {
+	var databases []string
+
+	if err := r.DBList().ReadAll(&databases, s); err != nil {
+		return 0, fmt.Errorf("could not load the RethinkDB databases\n%s", err)
+	}
+
+	if format.IndexOfString(db, databases) == -1 {
+		if err := r.DBCreate(db).Exec(s); err != nil {
+			return 0, fmt.Errorf("could not create the RethinkDB database %s\n%s", db, err)
+		}
+	}
+
+	sc := &schema{session: s, db: db}
+
+	if err := r.DB(db).TableList().ReadAll(&sc.tables, s); err != nil {
+		return 0, fmt.Errorf("could not load the tables of the RethinkDB database %s\n%s", db, err)
+	}
+
+	if err := sc.create(table{name: migrationsTable}); err != nil {
+		return 0, err
+	}
+
+	current, err := version(s, db)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if current > len(migrations) {
+		return 0, fmt.Errorf("the RethinkDB database %s has schema version %d, but this gopherDigest only knows version %d", db, current, len(migrations))
+	}
+
+	for i := current; i < len(migrations); i++ {
+		if err := sc.apply(i+1, migrations[i]); err != nil {
+			return i - current, err
+		}
+	}
+
+	return len(migrations) - current, nil
+}
+
+// apply creates the tables and indexes of a migration and records the schema version it results in
+func (sc *schema) apply(v int, m migration) error {
+	for _, t := range m.tables {
+		if err := sc.create(t); err != nil {
+			return fmt.Errorf("could not apply migration %d, %s\n%s", v, m.description, err)
+		}
+	}
+
+	err := r.DB(sc.db).Table(migrationsTable).Insert(map[string]interface{}{
+		"id":          v,
+		"Description": m.description,
+		"AppliedAt":   r.Now(),
+	}, r.InsertOpts{Conflict: "replace"}).Exec(sc.session)
+
+	if err != nil {
+		return fmt.Errorf("could not record schema version %d\n%s", v, err)
+	}
+
+	return nil
+}
+
+// create creates a table and its secondary indexes, skipping those that already exist, and waits
+// for the indexes to be ready
+func (sc *schema) create(t table) error {
+	if format.IndexOfString(t.name, sc.tables) == -1 {
+		if err := r.DB(sc.db).TableCreate(t.name).Exec(sc.session); err != nil {
+			return fmt.Errorf("failed to create the '%s' table\n%s", t.name, err)
+		}
+
+		sc.tables = append(sc.tables, t.name)
+	}
+
+	if len(t.indexes) == 0 {
+		return nil
+	}
+
+	var indexes []string
+
+	if err := r.DB(sc.db).Table(t.name).IndexList().ReadAll(&indexes, sc.session); err != nil {
+		return fmt.Errorf("could not load the indexes of the '%s' table\n%s", t.name, err)
+	}
+
+	for _, index := range t.indexes {
+		if format.IndexOfString(index, indexes) == -1 {
+			if err := r.DB(sc.db).Table(t.name).IndexCreate(index).Exec(sc.session); err != nil {
+				return fmt.Errorf("failed to create the '%s' index of the '%s' table\n%s", index, t.name, err)
+			}
+		}
+	}
+
+	if err := r.DB(sc.db).Table(t.name).IndexWait().Exec(sc.session); err != nil {
+		return fmt.Errorf("failed waiting for the indexes of the '%s' table\n%s", t.name, err)
+	}
+
+	return nil
+}
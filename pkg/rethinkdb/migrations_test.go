@@ -0,0 +1,149 @@
+package rethinkdb
+
+import (
+	"strings"
+	"testing"
+
+	r "gopkg.in/gorethink/gorethink.v4"
+)
+
+const testDB = "GopherDigest"
+
+// expectRecord expects a migration to be recorded in the Migrations table
+func expectRecord(m *r.Mock, v int) {
+	m.On(r.DB(testDB).Table(migrationsTable).Insert(map[string]interface{}{
+		"id":          v,
+		"Description": migrations[v-1].description,
+		"AppliedAt":   r.Now(),
+	}, r.InsertOpts{Conflict: "replace"})).Return(map[string]interface{}{"inserted": 1}, nil).Once()
+}
+
+// expectIndexes expects the indexes of a table to be listed, the missing ones created once and
+// all of them waited for
+func expectIndexes(m *r.Mock, name string, indexes []string, existing ...interface{}) {
+	m.On(r.DB(testDB).Table(name).IndexList()).Return(append([]interface{}{}, existing...), nil)
+	m.On(r.DB(testDB).Table(name).IndexWait()).Return(nil, nil)
+
+	for _, index := range indexes {
+		exists := false
+
+		for _, e := range existing {
+			exists = exists || e == index
+		}
+
+		if !exists {
+			m.On(r.DB(testDB).Table(name).IndexCreate(index)).Return(map[string]interface{}{"created": 1}, nil).Once()
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	m := r.NewMock()
+	m.On(r.DBList()).Return([]interface{}{"rethinkdb"}, nil)
+	m.On(r.DBCreate(testDB)).Return(map[string]interface{}{"dbs_created": 1}, nil).Once()
+	m.On(r.DB(testDB).TableList()).Return([]interface{}{}, nil)
+	m.On(r.DB(testDB).Table(migrationsTable).Max("id").Field("id").Default(0)).Return(0, nil)
+
+	for _, name := range []string{migrationsTable, "Queries", "Digests", "DigestIntervals", "Baselines"} {
+		m.On(r.DB(testDB).TableCreate(name)).Return(map[string]interface{}{"tables_created": 1}, nil).Once()
+	}
+
+	expectIndexes(m, "Queries", []string{"Fingerprint", "Checksum", "Timestamp", "Schema"})
+	expectIndexes(m, "Digests", []string{"Source", "Timestamp"})
+	expectIndexes(m, "DigestIntervals", []string{"Schema", "Digest", "End"})
+	expectIndexes(m, "Baselines", []string{"Name", "Schema", "Digest", "Timestamp"})
+
+	for v := 1; v <= SchemaVersion; v++ {
+		expectRecord(m, v)
+	}
+
+	applied, err := migrate(m, testDB)
+
+	if err != nil || applied != SchemaVersion {
+		t.Fatalf("migrate should apply all %d migrations to a new database, but applied %d, %v", SchemaVersion, applied, err)
+	}
+
+	m.AssertExpectations(t)
+}
+
+func TestMigrateLegacy(t *testing.T) {
+	m := r.NewMock()
+	m.On(r.DBList()).Return([]interface{}{"rethinkdb", testDB}, nil)
+	m.On(r.DB(testDB).TableList()).Return([]interface{}{"Queries", "Digests", "DigestIntervals"}, nil)
+	m.On(r.DB(testDB).Table(migrationsTable).Max("id").Field("id").Default(0)).Return(0, nil)
+
+	// the tables created before the schema was versioned are kept, with the indexes they have, as
+	// the mock panics on any other TableCreate or IndexCreate
+	m.On(r.DB(testDB).TableCreate(migrationsTable)).Return(map[string]interface{}{"tables_created": 1}, nil).Once()
+	m.On(r.DB(testDB).TableCreate("Baselines")).Return(map[string]interface{}{"tables_created": 1}, nil).Once()
+
+	expectIndexes(m, "Queries", []string{"Fingerprint", "Checksum", "Timestamp", "Schema"}, "Timestamp")
+	expectIndexes(m, "Digests", []string{"Source", "Timestamp"})
+	expectIndexes(m, "DigestIntervals", []string{"Schema", "Digest", "End"})
+	expectIndexes(m, "Baselines", []string{"Name", "Schema", "Digest", "Timestamp"})
+
+	for v := 1; v <= SchemaVersion; v++ {
+		expectRecord(m, v)
+	}
+
+	applied, err := migrate(m, testDB)
+
+	if err != nil || applied != SchemaVersion {
+		t.Fatalf("migrate should apply all %d migrations to a legacy database, but applied %d, %v", SchemaVersion, applied, err)
+	}
+
+	m.AssertExpectations(t)
+}
+
+func TestMigrateCurrent(t *testing.T) {
+	// any query other than these, such as creating a table, panics the mock
+	m := r.NewMock()
+	m.On(r.DBList()).Return([]interface{}{"rethinkdb", testDB}, nil)
+	m.On(r.DB(testDB).TableList()).Return([]interface{}{migrationsTable, "Queries", "Digests", "DigestIntervals", "Baselines"}, nil)
+	m.On(r.DB(testDB).Table(migrationsTable).Max("id").Field("id").Default(0)).Return(SchemaVersion, nil)
+
+	applied, err := migrate(m, testDB)
+
+	if err != nil || applied != 0 {
+		t.Errorf("migrate should not reapply migrations, but applied %d, %v", applied, err)
+	}
+
+	m = r.NewMock()
+	m.On(r.DBList()).Return([]interface{}{testDB}, nil)
+	m.On(r.DB(testDB).TableList()).Return([]interface{}{migrationsTable}, nil)
+	m.On(r.DB(testDB).Table(migrationsTable).Max("id").Field("id").Default(0)).Return(SchemaVersion+1, nil)
+
+	if _, err := migrate(m, testDB); err == nil || !strings.Contains(err.Error(), "only knows version") {
+		t.Errorf("migrate should refuse a database with a newer schema, but got %v", err)
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	tt := []struct {
+		name        string
+		version     int
+		expectedErr string
+	}{
+		{"Unmigrated", 0, "run 'gopherDigest init'"},
+		{"Older", SchemaVersion - 1, "run 'gopherDigest init'"},
+		{"Current", SchemaVersion, ""},
+		{"Newer", SchemaVersion + 1, "only knows version"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			m := r.NewMock()
+			m.On(r.DB(testDB).Table(migrationsTable).Max("id").Field("id").Default(0)).Return(tc.version, nil)
+
+			err := checkVersion(m, testDB)
+
+			if tc.expectedErr == "" && err != nil {
+				t.Errorf("checkVersion of version %d should not return an error, but got %s", tc.version, err)
+			}
+
+			if tc.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), tc.expectedErr)) {
+				t.Errorf("checkVersion of version %d should return an error containing %q, but got %v", tc.version, tc.expectedErr, err)
+			}
+		})
+	}
+}
@@ -1,38 +1,30 @@
 package rethinkdb
 
 import (
+	"context"
 	"fmt"
 	"gopherDigest/pkg/config"
-	"gopherDigest/pkg/format"
+	"gopherDigest/pkg/types"
+	"net"
 	"os"
+	"strconv"
+	"time"
 
 	r "gopkg.in/gorethink/gorethink.v4"
-
-	"github.com/fatih/color"
 )
 
 // queryDump represents a MySQL Query Performance Dump
 type queryDump struct {
-	Search         string          `gorethink:"Search"`
-	QueryTime      r.Term          `gorethink:"QueryTime"`
-	SQLExplainRows []SQLExplainRow `gorethink:"SQLExplainRows"`
-	Timestamp      int64           `gorethink:"Timestamp"`
-}
-
-// SQLExplainRow represents a MySQL Explain Result
-type SQLExplainRow struct {
-	ID           int     `gorethink:"ZID"`
-	SelectType   *string `gorethink:"SelectType"`
-	Table        *string `gorethink:"Table"`
-	Partitions   *string `gorethink:"Partitions"`
-	Ztype        *string `gorethink:"Ztype"`
-	PossibleKeys *string `gorethink:"PossibleKeys"`
-	Key          *string `gorethink:"Key"`
-	KeyLen       *string `gorethink:"KeyLen"`
-	Ref          *string `gorethink:"Ref"`
-	Rows         int     `gorethink:"Rows"`
-	Filtered     []byte  `gorethink:"Filtered"`
-	Extra        *string `gorethink:"Extra"`
+	Search         string                `gorethink:"Search"`
+	Fingerprint    string                `gorethink:"Fingerprint"`
+	Checksum       string                `gorethink:"Checksum"`
+	Schema         string                `gorethink:"Schema"`
+	QueryTime      r.Term                `gorethink:"QueryTime"`
+	SQLExplainRows []types.SQLExplainRow `gorethink:"SQLExplainRows"`
+	ExplainPlan    *types.ExplainPlan    `gorethink:"ExplainPlan,omitempty"`
+	ExplainAnalyze *types.AnalyzeNode    `gorethink:"ExplainAnalyze,omitempty"`
+	Metrics        *types.QueryMetrics   `gorethink:"Metrics,omitempty"`
+	Timestamp      int64                 `gorethink:"Timestamp"`
 }
 
 // RethinkDB defines the host machine's environment variables
@@ -41,19 +33,19 @@ type RethinkDB struct {
 }
 
 // New creates a new RethinkDB Database configuration
-func New(args ...string) *RethinkDB {
+func New(s config.RethinkDBSettings) *RethinkDB {
 	return &RethinkDB{
-		user: args[0], password: args[1], database: args[2], address: args[3],
+		user: s.Username, password: s.Password, database: s.Database, address: s.Address,
 	}
 }
 
 // Init initializes the connection
-func Init(rdb RethinkDB) (*r.Session, error) {
-	if err := executeAdminDuties(rdb); err != nil {
+func Init(ctx context.Context, rdb RethinkDB, b config.Backoff) (*r.Session, error) {
+	if err := executeAdminDuties(ctx, rdb, b); err != nil {
 		return nil, fmt.Errorf("%s", err)
 	}
 
-	RDBsession, err := Connect(rdb)
+	RDBsession, err := Connect(ctx, rdb, b)
 
 	if err != nil {
 		return nil, fmt.Errorf("%s", err)
@@ -62,108 +54,180 @@ func Init(rdb RethinkDB) (*r.Session, error) {
 	return RDBsession, err
 }
 
-func executeAdminDuties(rdb RethinkDB) error {
+// executeAdminDuties connects as the administrator to migrate the configured database to the
+// current schema and to create the configured user with read and write access to it. Running it
+// again only applies the migrations the database is missing.
+func executeAdminDuties(ctx context.Context, rdb RethinkDB, b config.Backoff) error {
 	// connect as admin
 	// TODO initialize DB with admin password and add to ENV
-	RDBsession, err := r.Connect(r.ConnectOpts{
+	RDBsession, _, err := dial(ctx, r.ConnectOpts{
 		Address: rdb.address,
-	})
+	}, b)
 
 	if err != nil {
 		return fmt.Errorf("%s", err)
 	}
 
-	var DBrow []string
-	res, err := r.DBList().Run(RDBsession)
+	defer RDBsession.Close()
+
+	applied, err := migrate(RDBsession, rdb.database)
 
 	if err != nil {
-		fmt.Println("could not load the RethinkDB databases")
+		return err
 	}
 
-	res.All(&DBrow)
-
-	// create Database if it doesn't already exist
-	if format.IndexOfString("GopherDigest", DBrow) == -1 {
-		r.DBCreate("GopherDigest").Exec(RDBsession)
+	if applied > 0 {
+		fmt.Printf("Migrated the RethinkDB database %s to schema version %d\n", rdb.database, SchemaVersion)
 	}
 
+	// an existing user keeps its id, so only its password is updated
 	err = r.DB("rethinkdb").Table("users").Insert(map[string]string{
 		"id":       rdb.user,
 		"password": rdb.password,
-	}).Exec(RDBsession)
+	}, r.InsertOpts{Conflict: "update"}).Exec(RDBsession)
 
 	if err != nil {
 		return fmt.Errorf("failed to create user %s", rdb.user)
 	}
 
-	var tblRow []string
-	tables, err := r.DB("GopherDigest").TableList().Run(RDBsession)
+	// then grant that user access to every table of the database, including those later migrations create
+	err = r.DB(rdb.database).Grant(rdb.user, map[string]bool{
+		"read":  true,
+		"write": true,
+	}).Exec(RDBsession)
 
-	tables.All(&tblRow)
+	if err != nil {
+		return fmt.Errorf("could not grant access to the %s database for user %s", rdb.database, rdb.user)
+	}
 
-	// create table if it doesn't already exist
-	if format.IndexOfString("Queries", tblRow) == -1 {
-		if err := r.DB("GopherDigest").TableCreate("Queries").Exec(RDBsession); err != nil {
-			return fmt.Errorf("failed to create the 'Queries' table")
-		}
+	return nil
+}
+
+// serverStatus is the part of a server's rethinkdb.server_status document reported in its health
+type serverStatus struct {
+	Process struct {
+		Version string `gorethink:"version"`
+	} `gorethink:"process"`
+	Network struct {
+		ReqlPort int `gorethink:"reql_port"`
+	} `gorethink:"network"`
+}
+
+// checkConnection reports the health of a session to a RethinkDB server
+func checkConnection(ctx context.Context, s *r.Session, opts r.ConnectOpts) (*config.Health, error) {
+	stat := &config.Health{Service: "RethinkDB", Endpoint: opts.Address, TLS: "disabled"}
+
+	if opts.TLSConfig != nil {
+		stat.TLS = "enabled"
 	}
 
+	host, port, err := net.SplitHostPort(opts.Address)
+
 	if err != nil {
-		fmt.Println("could not load the GopherDigest database tables")
+		return stat, err
 	}
 
-	// then grant that user access to any tables or databases they need access to
-	err = r.DB("GopherDigest").Table("Queries").Grant(rdb.user, map[string]bool{
-		"read":  true,
-		"write": true,
-	}).Exec(RDBsession)
+	stat.Port, _ = strconv.Atoi(port)
+
+	if addrs, err := net.DefaultResolver.LookupHost(ctx, host); err == nil {
+		stat.Address = addrs[0]
+	}
+
+	start := time.Now()
+	server, err := s.Server()
+
+	if err == nil && !s.IsConnected() {
+		err = r.ErrConnectionClosed
+	}
 
 	if err != nil {
-		return fmt.Errorf("could not grant administrative access to the 'Queries' table for user %s", rdb.user)
+		return stat, err
 	}
 
-	return nil
+	stat.Latency = time.Since(start)
+	stat.Server = server.Name
 
+	// server_status is only readable by administrators, so the version is reported when it can be
+	res, err := r.DB("rethinkdb").Table("server_status").Get(server.ID).Run(s)
+
+	if err == nil {
+		var status serverStatus
+
+		if res.One(&status) == nil {
+			stat.Version = status.Process.Version
+
+			if status.Network.ReqlPort > 0 {
+				stat.Port = status.Network.ReqlPort
+			}
+		}
+
+		res.Close()
+	}
+
+	return stat, nil
 }
 
-func checkConnection(s *r.Session) (*config.Health, error) {
-	stat := config.Health{}
-	red := color.New(color.FgRed, color.Bold)
-	green := color.New(color.FgGreen, color.Bold)
+// dial connects to a RethinkDB server, retrying with a backoff until the connection is healthy
+func dial(ctx context.Context, opts r.ConnectOpts, b config.Backoff) (*r.Session, *config.Health, error) {
+	var session *r.Session
 
-	color.New(color.Bold).Println("RethinkDB Connection Status")
+	stat, err := b.Retry(ctx, "RethinkDB", func(ctx context.Context) (*config.Health, error) {
+		s, err := r.Connect(opts)
 
-	server, err := s.Server()
+		if err != nil {
+			return &config.Health{Service: "RethinkDB", Endpoint: opts.Address}, err
+		}
+
+		stat, err := checkConnection(ctx, s, opts)
+
+		if err != nil {
+			s.Close()
+			return stat, err
+		}
+
+		session = s
+
+		return stat, nil
+	})
 
-	if err != nil || !s.IsConnected() {
-		stat.Conn = red.Sprint(" CLOSED")
-		stat.Port = red.Sprint(" NOT FOUND")
-		stat.Errors = append(stat.Errors, fmt.Errorf("could not connect to DB\n%v", err))
-		return &stat, err
+	if err != nil {
+		return nil, stat, err
 	}
 
-	stat.Conn = green.Sprint(" OPEN")
-	stat.Port = green.Sprint(" 28015")
-	stat.Socket = green.Sprintf(" %s", server.Name)
+	return session, stat, nil
+}
+
+// CheckConnection checks for connectivity to the server of a configuration, retrying the connection
+// with a backoff, and reports the server's address, port, name and version
+func CheckConnection(ctx context.Context, c RethinkDB, b config.Backoff) (*config.Health, error) {
+	db, stat, err := dial(ctx, c.connectOpts(), b)
+
+	if err != nil {
+		return stat, err
+	}
 
-	return &stat, nil
+	return stat, db.Close()
 }
 
-// Connect creates a connection to a RethinkDB database
-func Connect(c RethinkDB) (*r.Session, error) {
-	db, err := r.Connect(r.ConnectOpts{
+// connectOpts are the options that connect to the database of a configuration
+func (c RethinkDB) connectOpts() r.ConnectOpts {
+	return r.ConnectOpts{
 		Address:  c.address,
 		Database: c.database,
 		Username: c.user,
 		Password: c.password,
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("%s", err)
 	}
+}
+
+// Connect creates a connection to a RethinkDB database, retrying with a backoff until it is reachable
+func Connect(ctx context.Context, c RethinkDB, b config.Backoff) (*r.Session, error) {
+	db, conn, err := dial(ctx, c.connectOpts(), b)
 
-	conn, err := checkConnection(db)
 	conn.PrintStatus(os.Stdout)
 
+	if err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
@@ -0,0 +1,162 @@
+package rethinkdb
+
+import (
+	"context"
+	"fmt"
+	"gopherDigest/pkg/config"
+	"gopherDigest/pkg/store"
+	"gopherDigest/pkg/types"
+	"time"
+
+	r "gopkg.in/gorethink/gorethink.v4"
+)
+
+// Store saves results in the tables of a RethinkDB database
+type Store struct {
+	session *r.Session
+}
+
+var _ store.Store = (*Store)(nil)
+var _ store.BaselineStore = (*Store)(nil)
+
+// NewStore creates a store saving results through a RethinkDB session
+func NewStore(session *r.Session) *Store {
+	return &Store{session: session}
+}
+
+// Open connects to the database of a configuration, retrying with a backoff until it is reachable,
+// and returns a store saving results in it once the database has the current schema
+func Open(ctx context.Context, c RethinkDB, b config.Backoff) (*Store, error) {
+	session, err := Connect(ctx, c, b)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkVersion(session, c.database); err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	return NewStore(session), nil
+}
+
+// SavePlan inserts a query record's SQLExplain rows, JSON execution plan, and optional EXPLAIN
+// ANALYZE tree and metrics into the Queries table, timestamped by the server
+func (s *Store) SavePlan(rec types.QueryRecord) error {
+	err := r.Table("Queries").Insert(queryDump{
+		Search: rec.Search, Fingerprint: rec.Fingerprint, Checksum: rec.Checksum, Schema: rec.Schema,
+		Timestamp: time.Now().Unix(), QueryTime: r.Now(),
+		SQLExplainRows: rec.SQLExplainRows, ExplainPlan: rec.ExplainPlan, ExplainAnalyze: rec.ExplainAnalyze,
+		Metrics: rec.Metrics,
+	}).Exec(s.session)
+
+	if err != nil {
+		return fmt.Errorf("could not store the execution plan of %s\n%s", rec.Search, err)
+	}
+
+	return nil
+}
+
+// SaveRun inserts a pt-query-digest report into the Digests table, timestamped with the time it
+// was stored
+func (s *Store) SaveRun(report types.DigestReport) error {
+	report.Timestamp = time.Now().Unix()
+
+	if err := r.Table("Digests").Insert(report).Exec(s.session); err != nil {
+		return fmt.Errorf("could not store the pt-query-digest report of %s\n%s", report.Source, err)
+	}
+
+	return nil
+}
+
+// SaveDigestSnapshot inserts the per-interval load of performance_schema statement digests into
+// the DigestIntervals table
+func (s *Store) SaveDigestSnapshot(intervals []types.DigestInterval) error {
+	if len(intervals) == 0 {
+		return nil
+	}
+
+	if err := r.Table("DigestIntervals").Insert(intervals).Exec(s.session); err != nil {
+		return fmt.Errorf("could not store %d statement digest intervals\n%s", len(intervals), err)
+	}
+
+	return nil
+}
+
+// SaveBaseline inserts the load of every statement digest over a reference period into the
+// Baselines table, timestamped with the time it was stored
+func (s *Store) SaveBaseline(baselines []types.Baseline) error {
+	if len(baselines) == 0 {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	docs := make([]types.Baseline, len(baselines))
+
+	for i, b := range baselines {
+		b.Timestamp = now
+		docs[i] = b
+	}
+
+	if err := r.Table("Baselines").Insert(docs).Exec(s.session); err != nil {
+		return fmt.Errorf("could not store the baseline %s of %d statement digests\n%s", baselines[0].Name, len(baselines), err)
+	}
+
+	return nil
+}
+
+// Baseline fetches the digests of a named baseline from the Baselines table, ordered by digest,
+// limited to a single schema when one is given
+func (s *Store) Baseline(name, schema string) ([]types.Baseline, error) {
+	baselines := []types.Baseline{}
+	term := r.Table("Baselines").GetAllByIndex("Name", name)
+
+	if schema != "" {
+		term = r.Table("Baselines").GetAllByIndex("Schema", schema).Filter(map[string]interface{}{"Name": name})
+	}
+
+	res, err := term.OrderBy("Digest").Run(s.session)
+
+	if err != nil {
+		return baselines, fmt.Errorf("could not load the baseline %s\n%s", name, err)
+	}
+
+	defer res.Close()
+
+	if err := res.All(&baselines); err != nil {
+		return baselines, fmt.Errorf("could not decode the baseline %s\n%s", name, err)
+	}
+
+	return baselines, nil
+}
+
+// QueryHistory fetches the most recently inserted query dumps from the Queries table,
+// limited to a single query fingerprint when a checksum is given
+func (s *Store) QueryHistory(checksum string, limit int) ([]types.QueryRecord, error) {
+	records := []types.QueryRecord{}
+	term := r.Table("Queries").OrderBy(r.OrderByOpts{Index: r.Desc("Timestamp")})
+
+	if checksum != "" {
+		term = r.Table("Queries").GetAllByIndex("Checksum", checksum).OrderBy(r.Desc("Timestamp"))
+	}
+
+	res, err := term.Limit(limit).Run(s.session)
+
+	if err != nil {
+		return records, fmt.Errorf("could not load the stored queries\n%s", err)
+	}
+
+	defer res.Close()
+
+	if err := res.All(&records); err != nil {
+		return records, fmt.Errorf("could not decode the stored queries\n%s", err)
+	}
+
+	return records, nil
+}
+
+// Close closes the RethinkDB session
+func (s *Store) Close() error {
+	return s.session.Close()
+}
@@ -0,0 +1,283 @@
+package slowlog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event represents a single statement recorded in a MySQL slow query log
+type Event struct {
+	Time         time.Time
+	User         string
+	Host         string
+	IP           string
+	ThreadID     int64
+	Database     string
+	QueryTime    float64
+	LockTime     float64
+	RowsSent     int64
+	RowsExamined int64
+	Attributes   map[string]string
+	Query        string
+	Line         int
+}
+
+// Parser reads events from a MySQL slow query log one at a time
+type Parser struct {
+	r       *bufio.Reader
+	line    int
+	pending string
+	hasNext bool
+}
+
+var (
+	userHost  = regexp.MustCompile(`^# User@Host: (\S*?)\[\S*\] @ (\S*) \[(\S*)\](?:\s+Id:\s+(\d+))?`)
+	attribute = regexp.MustCompile(`(\w+): (\S+)`)
+	useDB     = regexp.MustCompile("(?i)^use `?([^`;\\s]+)`?;$")
+	timestamp = regexp.MustCompile(`^SET timestamp=(\d+);$`)
+	adminCmd  = regexp.MustCompile(`^# administrator command: (.*?);?$`)
+)
+
+// timeLayouts are the formats of the '# Time:' header in MySQL 5.7+ and in earlier versions
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"060102 15:04:05",
+}
+
+// NewParser creates a slow query log parser reading from a reader
+func NewParser(r io.Reader) *Parser {
+	return &Parser{r: bufio.NewReader(r)}
+}
+
+// Parse reads every event in a slow query log
+func Parse(r io.Reader) ([]Event, error) {
+	events := []Event{}
+	p := NewParser(r)
+
+	for {
+		e, err := p.Next()
+
+		if err == io.EOF {
+			return events, nil
+		}
+
+		if err != nil {
+			return events, err
+		}
+
+		events = append(events, *e)
+	}
+}
+
+// Next reads the next event from the log, returning io.EOF once the log is exhausted
+func (p *Parser) Next() (*Event, error) {
+	var e *Event
+	var query []string
+	inQuery := false
+
+	for {
+		line, err := p.readLine()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("could not read line %d of the slow query log\n%s", p.line, err)
+		}
+
+		if isServerHeader(line) {
+			if inQuery {
+				break
+			}
+
+			continue
+		}
+
+		if strings.HasPrefix(line, "# ") && !adminCmd.MatchString(line) {
+			// a header after the statement starts the next event
+			if inQuery {
+				p.unreadLine(line)
+				break
+			}
+
+			// '# Time' is followed by the rest of the same event's headers
+			if e != nil && strings.HasPrefix(line, "# Time:") && e.hasHeaders() {
+				p.unreadLine(line)
+				break
+			}
+
+			if e == nil {
+				e = &Event{Attributes: map[string]string{}, Line: p.line}
+			}
+
+			if err := e.parseHeader(line); err != nil {
+				return nil, fmt.Errorf("could not parse line %d of the slow query log\n%s", p.line, err)
+			}
+
+			continue
+		}
+
+		if e == nil {
+			// statements without headers, such as the log's preamble, are not events
+			continue
+		}
+
+		if !inQuery {
+			if m := useDB.FindStringSubmatch(line); m != nil {
+				e.Database = m[1]
+				continue
+			}
+
+			// the '# Time' header is more precise, when the server writes one
+			if m := timestamp.FindStringSubmatch(line); m != nil {
+				if e.Time.IsZero() {
+					ts, _ := strconv.ParseInt(m[1], 10, 64)
+					e.Time = time.Unix(ts, 0).UTC()
+				}
+				continue
+			}
+		}
+
+		if m := adminCmd.FindStringSubmatch(line); m != nil {
+			query = append(query, "administrator command: "+m[1])
+			inQuery = true
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" && !inQuery {
+			continue
+		}
+
+		query = append(query, line)
+		inQuery = true
+	}
+
+	if e == nil {
+		return nil, io.EOF
+	}
+
+	e.Query = strings.TrimSuffix(strings.TrimSpace(strings.Join(query, "\n")), ";")
+
+	if e.Database == "" {
+		e.Database = e.Attributes["Schema"]
+	}
+
+	return e, nil
+}
+
+// readLine reads the next line without its line ending
+func (p *Parser) readLine() (string, error) {
+	if p.hasNext {
+		p.hasNext = false
+		return p.pending, nil
+	}
+
+	line, err := p.r.ReadString('\n')
+
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	p.line++
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// unreadLine returns a line so the next event starts with it
+func (p *Parser) unreadLine(line string) {
+	p.pending = line
+	p.hasNext = true
+}
+
+// hasHeaders reports whether any header besides '# Time' has been read for an event
+func (e *Event) hasHeaders() bool {
+	return e.User != "" || e.Host != "" || len(e.Attributes) > 0
+}
+
+// parseHeader parses a '# Key: value' header line onto an event
+func (e *Event) parseHeader(line string) error {
+	switch {
+	case strings.HasPrefix(line, "# Time:"):
+		t, err := parseTime(strings.TrimSpace(strings.TrimPrefix(line, "# Time:")))
+
+		if err != nil {
+			return err
+		}
+
+		e.Time = t
+	case strings.HasPrefix(line, "# User@Host:"):
+		m := userHost.FindStringSubmatch(line)
+
+		if m == nil {
+			return fmt.Errorf("malformed User@Host header %q", line)
+		}
+
+		e.User, e.Host, e.IP = m[1], m[2], m[3]
+
+		if m[4] != "" {
+			e.ThreadID, _ = strconv.ParseInt(m[4], 10, 64)
+		}
+	default:
+		for _, m := range attribute.FindAllStringSubmatch(line, -1) {
+			if err := e.setAttribute(m[1], m[2]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// setAttribute sets a typed field for the standard slow log metrics and keeps any others as text
+func (e *Event) setAttribute(key, value string) error {
+	var err error
+
+	switch key {
+	case "Query_time":
+		e.QueryTime, err = strconv.ParseFloat(value, 64)
+	case "Lock_time":
+		e.LockTime, err = strconv.ParseFloat(value, 64)
+	case "Rows_sent":
+		e.RowsSent, err = strconv.ParseInt(value, 10, 64)
+	case "Rows_examined":
+		e.RowsExamined, err = strconv.ParseInt(value, 10, 64)
+	case "Thread_id":
+		e.ThreadID, err = strconv.ParseInt(value, 10, 64)
+	default:
+		e.Attributes[key] = value
+	}
+
+	if err != nil {
+		return fmt.Errorf("invalid %s value %q", key, value)
+	}
+
+	return nil
+}
+
+// parseTime parses the timestamp of a '# Time:' header
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized time %q", value)
+}
+
+// isServerHeader reports whether a line is part of the banner mysqld writes when it opens the log
+func isServerHeader(line string) bool {
+	return strings.Contains(line, ", Version: ") && strings.Contains(line, "started with:") ||
+		strings.HasPrefix(line, "Tcp port: ") ||
+		strings.HasPrefix(line, "Time                 Id Command    Argument")
+}
@@ -0,0 +1,138 @@
+package slowlog
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	f, err := os.Open("testdata/slow.log")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	events, err := Parse(f)
+
+	if err != nil {
+		t.Fatalf("Parse should not return an error, but got %s", err)
+	}
+
+	expected := []Event{
+		{
+			Time:         time.Date(2023, 11, 2, 14, 3, 11, 254153000, time.UTC),
+			User:         "root",
+			Host:         "localhost",
+			ThreadID:     8,
+			Database:     "employees",
+			QueryTime:    2.345678,
+			LockTime:     0.000123,
+			RowsSent:     10,
+			RowsExamined: 2844047,
+			Attributes:   map[string]string{},
+			Query:        "SELECT * FROM salaries s\n  LEFT JOIN employees e USING(emp_no)\n  WHERE s.salary > 100000",
+			Line:         4,
+		},
+		{
+			Time:         time.Date(2023, 11, 2, 14, 3, 12, 1000, time.UTC),
+			User:         "app",
+			Host:         "web-1.internal",
+			IP:           "10.0.0.12",
+			ThreadID:     15,
+			Database:     "employees",
+			QueryTime:    0.000210,
+			LockTime:     0.000051,
+			RowsSent:     1,
+			RowsExamined: 1,
+			Attributes: map[string]string{
+				"Schema": "employees", "Last_errno": "0", "Killed": "0",
+				"Bytes_sent": "251", "Tmp_tables": "0", "Tmp_disk_tables": "0",
+			},
+			Query: "SELECT first_name FROM employees WHERE emp_no = 10001 AND last_name = 'O''Brien; Jr'",
+			Line:  12,
+		},
+		{
+			Time:       time.Unix(1698933792, 0).UTC(),
+			User:       "app",
+			Host:       "web-1.internal",
+			IP:         "10.0.0.12",
+			ThreadID:   15,
+			QueryTime:  0.000011,
+			Attributes: map[string]string{},
+			Query:      "administrator command: Quit",
+			Line:       19,
+		},
+		{
+			Time:       time.Date(2023, 11, 2, 9, 3, 11, 0, time.UTC),
+			User:       "root",
+			Host:       "localhost",
+			Database:   "dept-archive",
+			QueryTime:  12,
+			Attributes: map[string]string{},
+			Query:      "SET GLOBAL long_query_time = 0",
+			Line:       26,
+		},
+	}
+
+	if len(events) != len(expected) {
+		t.Fatalf("Parse should return %d events, but got %d\n%+v", len(expected), len(events), events)
+	}
+
+	for i := range expected {
+		if !reflect.DeepEqual(events[i], expected[i]) {
+			t.Errorf("Parse event %d should be\n%+v\nbut got\n%+v", i, expected[i], events[i])
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	log := "# Time: 2023-11-02T14:03:11.000000Z\n" +
+		"# User@Host: root[root] @ localhost []  Id:     8\n" +
+		"# Query_time: 1.5  Lock_time: 0.1 Rows_sent: 1  Rows_examined: 1\n" +
+		"SELECT 1;\n"
+
+	p := NewParser(strings.NewReader(log))
+
+	e, err := p.Next()
+
+	if err != nil || e.Query != "SELECT 1" || e.QueryTime != 1.5 {
+		t.Fatalf("Next should return the first event, but got %+v, %v", e, err)
+	}
+
+	if _, err := p.Next(); err != io.EOF {
+		t.Errorf("Next should return io.EOF after the last event, but got %v", err)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tt := []struct {
+		name string
+		log  string
+	}{
+		{"Bad Time", "# Time: yesterday\nSELECT 1;\n"},
+		{"Bad Query Time", "# Query_time: slow  Lock_time: 0.1\nSELECT 1;\n"},
+		{"Bad User@Host", "# User@Host: nobody\nSELECT 1;\n"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Parse(strings.NewReader(tc.log)); err == nil {
+				t.Errorf("Parse of %q should return an error", tc.log)
+			}
+		})
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	events, err := Parse(strings.NewReader("/usr/sbin/mysqld, Version: 8.0.35 (MySQL Community Server - GPL). started with:\n"))
+
+	if err != nil || len(events) != 0 {
+		t.Errorf("Parse of a log without events should return no events, but got %+v, %v", events, err)
+	}
+}
@@ -0,0 +1,114 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migrations are the schema changes of the store, in order. The schema version of a database
+// file is the number of migrations applied to it, recorded in PRAGMA user_version, so a
+// migration must never be changed once released, only followed by another.
+var migrations = []string{
+	// 1: captured queries, as InsertSQLExplain stored them in RethinkDB
+	`CREATE TABLE queries (
+		id              INTEGER PRIMARY KEY,
+		search          TEXT NOT NULL,
+		fingerprint     TEXT NOT NULL,
+		checksum        TEXT NOT NULL,
+		query_time      TEXT NOT NULL,
+		timestamp       INTEGER NOT NULL,
+		explain_rows    TEXT NOT NULL,
+		explain_plan    TEXT,
+		explain_analyze TEXT
+	);
+	CREATE INDEX queries_timestamp ON queries (timestamp);
+	CREATE INDEX queries_checksum_timestamp ON queries (checksum, timestamp);`,
+
+	// 2: pt-query-digest reports
+	`CREATE TABLE digest_reports (
+		id        INTEGER PRIMARY KEY,
+		source    TEXT NOT NULL,
+		timestamp INTEGER NOT NULL,
+		report    TEXT NOT NULL
+	);`,
+
+	// 3: the per-interval load of performance_schema statement digests
+	`CREATE TABLE digest_intervals (
+		id             INTEGER PRIMARY KEY,
+		schema_name    TEXT NOT NULL,
+		digest         TEXT NOT NULL,
+		digest_text    TEXT NOT NULL,
+		start_time     TEXT NOT NULL,
+		end_time       TEXT NOT NULL,
+		reset          INTEGER NOT NULL,
+		count_star     INTEGER NOT NULL,
+		sum_timer_wait INTEGER NOT NULL,
+		counters       TEXT NOT NULL
+	);
+	CREATE INDEX digest_intervals_digest_end ON digest_intervals (digest, end_time);`,
+
+	// 4: the metrics of the latest execution of a captured query
+	`ALTER TABLE queries ADD COLUMN metrics TEXT;`,
+
+	// 5: the schema a captured query ran in
+	`ALTER TABLE queries ADD COLUMN schema_name TEXT NOT NULL DEFAULT '';
+	CREATE INDEX queries_schema_timestamp ON queries (schema_name, timestamp);`,
+}
+
+// SchemaVersion is the schema version of the databases this version of gopherDigest writes
+var SchemaVersion = len(migrations)
+
+// version reads the schema version of a database
+func version(db *sql.DB) (int, error) {
+	var v int
+
+	if err := db.QueryRow("PRAGMA user_version").Scan(&v); err != nil {
+		return 0, fmt.Errorf("could not read the schema version\n%s", err)
+	}
+
+	return v, nil
+}
+
+// migrate applies the migrations a database is missing, each in its own transaction with the
+// schema version it results in, returning the number applied
+func migrate(db *sql.DB) (int, error) {
+	current, err := version(db)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if current > len(migrations) {
+		return 0, fmt.Errorf("the database has schema version %d, but this gopherDigest only knows version %d", current, len(migrations))
+	}
+
+	for i := current; i < len(migrations); i++ {
+		if err := apply(db, i+1, migrations[i]); err != nil {
+			return i - current, err
+		}
+	}
+
+	return len(migrations) - current, nil
+}
+
+// apply runs a migration and records the schema version it results in
+func apply(db *sql.DB, v int, migration string) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return fmt.Errorf("could not begin migration %d\n%s", v, err)
+	}
+
+	if _, err := tx.Exec(migration); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("could not apply migration %d\n%s", v, err)
+	}
+
+	// PRAGMA statements cannot take parameters
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", v)); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("could not record schema version %d\n%s", v, err)
+	}
+
+	return tx.Commit()
+}
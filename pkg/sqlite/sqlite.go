@@ -0,0 +1,259 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"gopherDigest/pkg/config"
+	"gopherDigest/pkg/store"
+	"gopherDigest/pkg/types"
+	"net/url"
+	"time"
+
+	// registers the pure Go "sqlite" database/sql driver
+	_ "modernc.org/sqlite"
+)
+
+// Store saves results in a SQLite database file
+type Store struct {
+	db *sql.DB
+}
+
+var _ store.Store = (*Store)(nil)
+
+// dsn is the data source name of a database file, waiting for other processes' writes to finish
+// rather than failing
+func dsn(path string) string {
+	return "file:" + (&url.URL{Path: path}).EscapedPath() + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+}
+
+// Open opens a SQLite database file, creating it if it does not exist, and migrates it to the
+// current schema
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", dsn(path))
+
+	if err != nil {
+		return nil, fmt.Errorf("could not open the SQLite database %s\n%s", path, err)
+	}
+
+	// SQLite has a single writer, so writes are serialized rather than retried
+	db.SetMaxOpenConns(1)
+
+	if _, err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not migrate the SQLite database %s\n%s", path, err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// CheckConnection reports the health of a SQLite database file, migrating it if needed
+func CheckConnection(ctx context.Context, path string) (*config.Health, error) {
+	stat := &config.Health{Service: "SQLite", Endpoint: path, TLS: "disabled"}
+	start := time.Now()
+
+	s, err := Open(path)
+
+	if err != nil {
+		stat.Errors = append(stat.Errors, config.NewCheckError(config.ErrConnection, 1, err))
+		return stat, err
+	}
+
+	defer s.Close()
+
+	if err := s.db.QueryRowContext(ctx, "SELECT sqlite_version()").Scan(&stat.Version); err != nil {
+		stat.Errors = append(stat.Errors, config.NewCheckError(config.ErrMetadata, 1, err))
+		return stat, err
+	}
+
+	stat.Latency = time.Since(start)
+	stat.Connected = true
+
+	return stat, nil
+}
+
+// marshal encodes a value as JSON, storing a nil pointer as NULL
+func marshal(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+
+	if err != nil || string(b) == "null" {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+// SavePlan inserts a query record's SQLExplain rows, JSON execution plan, and optional EXPLAIN
+// ANALYZE tree and metrics into the queries table, timestamped with the time it was stored
+func (s *Store) SavePlan(rec types.QueryRecord) error {
+	now := time.Now()
+	rows, err := json.Marshal(rec.SQLExplainRows)
+
+	if err != nil {
+		return fmt.Errorf("could not encode the EXPLAIN rows of %s\n%s", rec.Search, err)
+	}
+
+	plan, err := marshal(rec.ExplainPlan)
+
+	if err != nil {
+		return fmt.Errorf("could not encode the execution plan of %s\n%s", rec.Search, err)
+	}
+
+	analyze, err := marshal(rec.ExplainAnalyze)
+
+	if err != nil {
+		return fmt.Errorf("could not encode the EXPLAIN ANALYZE tree of %s\n%s", rec.Search, err)
+	}
+
+	metrics, err := marshal(rec.Metrics)
+
+	if err != nil {
+		return fmt.Errorf("could not encode the metrics of %s\n%s", rec.Search, err)
+	}
+
+	_, err = s.db.Exec(`INSERT INTO queries
+		(search, fingerprint, checksum, schema_name, query_time, timestamp, explain_rows, explain_plan, explain_analyze, metrics)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.Search, rec.Fingerprint, rec.Checksum, rec.Schema, now.UTC().Format(time.RFC3339Nano), now.Unix(), string(rows), plan, analyze, metrics)
+
+	if err != nil {
+		return fmt.Errorf("could not store the execution plan of %s\n%s", rec.Search, err)
+	}
+
+	return nil
+}
+
+// SaveRun inserts a pt-query-digest report into the digest_reports table, timestamped with the
+// time it was stored
+func (s *Store) SaveRun(report types.DigestReport) error {
+	b, err := json.Marshal(report)
+
+	if err != nil {
+		return fmt.Errorf("could not encode the pt-query-digest report of %s\n%s", report.Source, err)
+	}
+
+	_, err = s.db.Exec("INSERT INTO digest_reports (source, timestamp, report) VALUES (?, ?, ?)",
+		report.Source, time.Now().Unix(), string(b))
+
+	if err != nil {
+		return fmt.Errorf("could not store the pt-query-digest report of %s\n%s", report.Source, err)
+	}
+
+	return nil
+}
+
+// SaveDigestSnapshot inserts the per-interval load of performance_schema statement digests into
+// the digest_intervals table, in a single transaction
+func (s *Store) SaveDigestSnapshot(intervals []types.DigestInterval) error {
+	if len(intervals) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+
+	if err != nil {
+		return fmt.Errorf("could not store %d statement digest intervals\n%s", len(intervals), err)
+	}
+
+	for _, i := range intervals {
+		counters, err := json.Marshal(i.DigestCounters)
+
+		if err == nil {
+			_, err = tx.Exec(`INSERT INTO digest_intervals
+				(schema_name, digest, digest_text, start_time, end_time, reset, count_star, sum_timer_wait, counters)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				i.Schema, i.Digest, i.DigestText, i.Start.UTC().Format(time.RFC3339Nano), i.End.UTC().Format(time.RFC3339Nano),
+				i.Reset, int64(i.CountStar), int64(i.SumTimerWait), string(counters))
+		}
+
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("could not store %d statement digest intervals\n%s", len(intervals), err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// QueryHistory fetches the most recently inserted queries, limited to a single query fingerprint
+// when a checksum is given
+func (s *Store) QueryHistory(checksum string, limit int) ([]types.QueryRecord, error) {
+	records := []types.QueryRecord{}
+	query := `SELECT search, fingerprint, checksum, schema_name, query_time, timestamp, explain_rows, explain_plan, explain_analyze, metrics
+		FROM queries WHERE ? = '' OR checksum = ? ORDER BY timestamp DESC, id DESC LIMIT ?`
+
+	rows, err := s.db.Query(query, checksum, checksum, limit)
+
+	if err != nil {
+		return records, fmt.Errorf("could not load the stored queries\n%s", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var rec types.QueryRecord
+		var queryTime, explainRows string
+		var plan, analyze, metrics sql.NullString
+
+		if err := rows.Scan(&rec.Search, &rec.Fingerprint, &rec.Checksum, &rec.Schema, &queryTime, &rec.Timestamp, &explainRows, &plan, &analyze, &metrics); err != nil {
+			return records, fmt.Errorf("could not load the stored queries\n%s", err)
+		}
+
+		if err := decode(&rec, queryTime, explainRows, plan, analyze, metrics); err != nil {
+			return records, fmt.Errorf("could not decode the stored query %s\n%s", rec.Search, err)
+		}
+
+		records = append(records, rec)
+	}
+
+	if err := rows.Err(); err != nil {
+		return records, fmt.Errorf("could not load the stored queries\n%s", err)
+	}
+
+	return records, nil
+}
+
+// decode decodes the stored time, EXPLAIN rows, plans and metrics of a query record
+func decode(rec *types.QueryRecord, queryTime, explainRows string, plan, analyze, metrics sql.NullString) error {
+	var err error
+
+	if rec.QueryTime, err = time.Parse(time.RFC3339Nano, queryTime); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal([]byte(explainRows), &rec.SQLExplainRows); err != nil {
+		return err
+	}
+
+	if plan.Valid {
+		rec.ExplainPlan = &types.ExplainPlan{}
+
+		if err := json.Unmarshal([]byte(plan.String), rec.ExplainPlan); err != nil {
+			return err
+		}
+	}
+
+	if analyze.Valid {
+		rec.ExplainAnalyze = &types.AnalyzeNode{}
+
+		if err := json.Unmarshal([]byte(analyze.String), rec.ExplainAnalyze); err != nil {
+			return err
+		}
+	}
+
+	if metrics.Valid {
+		rec.Metrics = &types.QueryMetrics{}
+
+		if err := json.Unmarshal([]byte(metrics.String), rec.Metrics); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close closes the database file
+func (s *Store) Close() error {
+	return s.db.Close()
+}
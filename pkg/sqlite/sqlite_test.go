@@ -0,0 +1,178 @@
+package sqlite
+
+import (
+	"context"
+	"gopherDigest/pkg/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTemp(t *testing.T) (*Store, string, func()) {
+	dir, err := ioutil.TempDir("", "sqlite")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "gopherDigest.db")
+	s, err := Open(path)
+
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Open should not return an error, but got %s", err)
+	}
+
+	return s, path, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func count(t *testing.T, s *Store, table string) int {
+	var n int
+
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
+
+func TestMigrate(t *testing.T) {
+	s, path, cleanup := openTemp(t)
+	defer cleanup()
+
+	if v, err := version(s.db); err != nil || v != SchemaVersion {
+		t.Errorf("Open should migrate the database to schema version %d, but got %d, %v", SchemaVersion, v, err)
+	}
+
+	if n, err := migrate(s.db); err != nil || n != 0 {
+		t.Errorf("migrate should not reapply migrations, but applied %d, %v", n, err)
+	}
+
+	if _, err := s.db.Exec("PRAGMA user_version = 1000"); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Close()
+
+	if _, err := Open(path); err == nil || !strings.Contains(err.Error(), "schema version 1000") {
+		t.Errorf("Open should refuse a database with a newer schema, but got %v", err)
+	}
+}
+
+func TestQueryHistory(t *testing.T) {
+	s, _, cleanup := openTemp(t)
+	defer cleanup()
+
+	table, extra, filtered := "employees", "Using where", 100.0
+	plan := &types.ExplainPlan{QueryBlock: types.QueryBlock{SelectID: 1, CostInfo: &types.CostInfo{QueryCost: 12.5}}}
+	analyze := &types.AnalyzeNode{Operation: "Table scan on employees", ActualRows: 10, Executed: true}
+	metrics := &types.QueryMetrics{Digest: "d1", QueryTime: 0.0025, RowsSent: 10, RowsExamined: 10, DigestCounters: types.DigestCounters{CountStar: 4}}
+
+	records := []types.QueryRecord{
+		{Search: "SELECT * FROM employees", Fingerprint: "select * from employees", Checksum: "A", Schema: "employees",
+			SQLExplainRows: []types.SQLExplainRow{{ID: 1, Table: &table, Rows: 10, Filtered: &filtered, Extra: &extra}},
+			ExplainPlan:    plan, ExplainAnalyze: analyze, Metrics: metrics},
+		{Search: "SELECT 1", Fingerprint: "select ?", Checksum: "B", SQLExplainRows: []types.SQLExplainRow{{ID: 1}}},
+		{Search: "SELECT 2", Fingerprint: "select ?", Checksum: "B", SQLExplainRows: []types.SQLExplainRow{{ID: 1}}},
+	}
+
+	for _, rec := range records {
+		if err := s.SavePlan(rec); err != nil {
+			t.Fatalf("SavePlan should not return an error, but got %s", err)
+		}
+	}
+
+	history, err := s.QueryHistory("", 2)
+
+	if err != nil {
+		t.Fatalf("QueryHistory should not return an error, but got %s", err)
+	}
+
+	if len(history) != 2 || history[0].Search != "SELECT 2" || history[1].Search != "SELECT 1" {
+		t.Errorf("QueryHistory should return the two newest queries, newest first, but got %+v", history)
+	}
+
+	history, err = s.QueryHistory("A", 10)
+
+	if err != nil || len(history) != 1 {
+		t.Fatalf("QueryHistory should return the single query with checksum A, but got %+v, %v", history, err)
+	}
+
+	rec := history[0]
+
+	if rec.Schema != "employees" {
+		t.Errorf("QueryHistory should return the schema of the query, but got %q", rec.Schema)
+	}
+
+	if time.Since(rec.QueryTime) > time.Minute || rec.Timestamp != rec.QueryTime.Unix() {
+		t.Errorf("SavePlan should timestamp the query with the time it was stored, but got %s, %d", rec.QueryTime, rec.Timestamp)
+	}
+
+	if !reflect.DeepEqual(rec.SQLExplainRows, records[0].SQLExplainRows) || !reflect.DeepEqual(rec.ExplainPlan, plan) || !reflect.DeepEqual(rec.ExplainAnalyze, analyze) || !reflect.DeepEqual(rec.Metrics, metrics) {
+		t.Errorf("QueryHistory should return the stored EXPLAIN rows, plans and metrics, but got %+v", rec)
+	}
+
+	history, _ = s.QueryHistory("B", 10)
+
+	if len(history) != 2 || history[0].ExplainPlan != nil || history[0].ExplainAnalyze != nil || history[0].Metrics != nil {
+		t.Errorf("QueryHistory should return queries without plans or metrics as nil, but got %+v", history)
+	}
+}
+
+func TestSaveDigests(t *testing.T) {
+	s, _, cleanup := openTemp(t)
+	defer cleanup()
+
+	report := types.DigestReport{Source: "slow.log", Classes: []types.DigestClass{{Checksum: "A", QueryCount: 3}}}
+
+	if err := s.SaveRun(report); err != nil {
+		t.Fatalf("SaveRun should not return an error, but got %s", err)
+	}
+
+	end := time.Now()
+	intervals := []types.DigestInterval{
+		{Schema: "employees", Digest: "d1", DigestText: "SELECT ?", Start: end.Add(-time.Minute), End: end, DigestCounters: types.DigestCounters{CountStar: 5, SumTimerWait: 100}},
+		{Schema: "employees", Digest: "d2", DigestText: "SELECT * FROM `t`", Start: end.Add(-time.Minute), End: end, Reset: true},
+	}
+
+	if err := s.SaveDigestSnapshot(intervals); err != nil {
+		t.Fatalf("SaveDigestSnapshot should not return an error, but got %s", err)
+	}
+
+	if err := s.SaveDigestSnapshot(nil); err != nil {
+		t.Errorf("SaveDigestSnapshot should accept an empty snapshot, but got %s", err)
+	}
+
+	if count(t, s, "digest_reports") != 1 || count(t, s, "digest_intervals") != 2 {
+		t.Errorf("the store should hold 1 report and 2 intervals, but got %d and %d", count(t, s, "digest_reports"), count(t, s, "digest_intervals"))
+	}
+
+	var countStar int64
+	var reset bool
+
+	if err := s.db.QueryRow("SELECT count_star, reset FROM digest_intervals WHERE digest = 'd1'").Scan(&countStar, &reset); err != nil || countStar != 5 || reset {
+		t.Errorf("SaveDigestSnapshot should store the counters of d1, but got %d, %v, %v", countStar, reset, err)
+	}
+}
+
+func TestCheckConnection(t *testing.T) {
+	_, path, cleanup := openTemp(t)
+	defer cleanup()
+
+	stat, err := CheckConnection(context.Background(), path)
+
+	if err != nil || !stat.Connected || stat.Version == "" || stat.Endpoint != path {
+		t.Errorf("CheckConnection should report the version of the database, but got %+v, %v", stat, err)
+	}
+
+	if _, err := CheckConnection(context.Background(), filepath.Join(path, "missing", "gopherDigest.db")); err == nil {
+		t.Errorf("CheckConnection should fail for a database that cannot be created")
+	}
+}
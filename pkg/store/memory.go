@@ -0,0 +1,272 @@
+package store
+
+import (
+	"errors"
+	"gopherDigest/pkg/types"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned when saving to or reading from a closed Memory store
+var ErrClosed = errors.New("the result store is closed")
+
+// Memory keeps results in memory, in the order they were saved, for tests and for runs whose
+// results do not need to outlive gopherDigest. It is safe for concurrent use, and results are
+// copied when they are saved and read, so callers may change them afterwards.
+type Memory struct {
+	mu        sync.RWMutex
+	queries   []types.QueryRecord
+	runs      []types.DigestReport
+	intervals []types.DigestInterval
+	baselines []types.Baseline
+	closed    bool
+
+	// Now timestamps saved plans and runs, defaulting to time.Now
+	Now func() time.Time
+}
+
+var _ Store = (*Memory)(nil)
+var _ BaselineStore = (*Memory)(nil)
+
+// NewMemory creates an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{Now: time.Now}
+}
+
+// SavePlan saves a captured query, timestamped with the time it was saved
+func (m *Memory) SavePlan(rec types.QueryRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	now := m.Now()
+	rec = copyRecord(rec)
+	rec.QueryTime, rec.Timestamp = now, now.Unix()
+	m.queries = append(m.queries, rec)
+
+	return nil
+}
+
+// SaveRun saves the report of a pt-query-digest run, timestamped with the time it was saved
+func (m *Memory) SaveRun(report types.DigestReport) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	report = clone(reflect.ValueOf(report)).Interface().(types.DigestReport)
+	report.Timestamp = m.Now().Unix()
+	m.runs = append(m.runs, report)
+
+	return nil
+}
+
+// SaveDigestSnapshot saves the load of every statement digest over an interval
+func (m *Memory) SaveDigestSnapshot(intervals []types.DigestInterval) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	m.intervals = append(m.intervals, intervals...)
+
+	return nil
+}
+
+// SaveBaseline saves the load of every statement digest over a reference period, timestamped with
+// the time it was saved
+func (m *Memory) SaveBaseline(baselines []types.Baseline) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	now := m.Now().Unix()
+
+	for _, b := range baselines {
+		b.Timestamp = now
+		m.baselines = append(m.baselines, b)
+	}
+
+	return nil
+}
+
+// Baseline returns the digests of a named baseline, in the order they were saved, limited to a
+// single schema when one is given
+func (m *Memory) Baseline(name, schema string) ([]types.Baseline, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	baselines := []types.Baseline{}
+
+	for _, b := range m.baselines {
+		if b.Name == name && (schema == "" || b.Schema == schema) {
+			baselines = append(baselines, b)
+		}
+	}
+
+	return baselines, nil
+}
+
+// QueryHistory returns the most recently saved queries, newest first, limited to a single query
+// fingerprint when a checksum is given
+func (m *Memory) QueryHistory(checksum string, limit int) ([]types.QueryRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	records := []types.QueryRecord{}
+
+	for i := len(m.queries) - 1; i >= 0 && len(records) < limit; i-- {
+		if checksum == "" || m.queries[i].Checksum == checksum {
+			records = append(records, copyRecord(m.queries[i]))
+		}
+	}
+
+	return records, nil
+}
+
+// Close closes the store, so later saves fail with ErrClosed. The saved results can still be read
+// with Plans, Runs and Intervals.
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closed = true
+
+	return nil
+}
+
+// Closed reports whether the store was closed
+func (m *Memory) Closed() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.closed
+}
+
+// Plans returns the saved queries, oldest first, limited to a single query fingerprint when a
+// checksum is given
+func (m *Memory) Plans(checksum string) []types.QueryRecord {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	records := []types.QueryRecord{}
+
+	for _, rec := range m.queries {
+		if checksum == "" || rec.Checksum == checksum {
+			records = append(records, copyRecord(rec))
+		}
+	}
+
+	return records
+}
+
+// Runs returns the saved pt-query-digest reports, oldest first, limited to a single source when
+// one is given
+func (m *Memory) Runs(source string) []types.DigestReport {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	reports := []types.DigestReport{}
+
+	for _, report := range m.runs {
+		if source == "" || report.Source == source {
+			reports = append(reports, clone(reflect.ValueOf(report)).Interface().(types.DigestReport))
+		}
+	}
+
+	return reports
+}
+
+// Intervals returns the saved statement digest intervals, oldest first, limited to a single
+// digest when one is given
+func (m *Memory) Intervals(digest string) []types.DigestInterval {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	intervals := []types.DigestInterval{}
+
+	for _, i := range m.intervals {
+		if digest == "" || i.Digest == digest {
+			intervals = append(intervals, i)
+		}
+	}
+
+	return intervals
+}
+
+// copyRecord copies a query record with its EXPLAIN rows, plans and metrics
+func copyRecord(rec types.QueryRecord) types.QueryRecord {
+	return clone(reflect.ValueOf(rec)).Interface().(types.QueryRecord)
+}
+
+// clone deep copies a value, following its pointers, slices and maps. Unexported fields, such as
+// those of time.Time, are copied as they are.
+func clone(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(clone(v.Elem()))
+
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(clone(v.Index(i)))
+		}
+
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+
+		for it := v.MapRange(); it.Next(); {
+			c.SetMapIndex(it.Key(), clone(it.Value()))
+		}
+
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(clone(v.Field(i)))
+			}
+		}
+
+		return c
+	}
+
+	return v
+}
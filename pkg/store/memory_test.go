@@ -0,0 +1,272 @@
+package store
+
+import (
+	"fmt"
+	"gopherDigest/pkg/types"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryQueryHistory(t *testing.T) {
+	m := NewMemory()
+	at := time.Date(2023, 11, 2, 14, 0, 0, 0, time.UTC)
+	m.Now = func() time.Time { return at }
+
+	records := []types.QueryRecord{
+		{Search: "SELECT * FROM employees", Fingerprint: "select * from employees", Checksum: "A"},
+		{Search: "SELECT 1", Fingerprint: "select ?", Checksum: "B"},
+		{Search: "SELECT 2", Fingerprint: "select ?", Checksum: "B"},
+	}
+
+	for _, rec := range records {
+		if err := m.SavePlan(rec); err != nil {
+			t.Fatalf("SavePlan should not return an error, but got %s", err)
+		}
+	}
+
+	history, err := m.QueryHistory("", 2)
+
+	if err != nil || len(history) != 2 || history[0].Search != "SELECT 2" || history[1].Search != "SELECT 1" {
+		t.Errorf("QueryHistory should return the two newest queries, newest first, but got %+v, %v", history, err)
+	}
+
+	history, _ = m.QueryHistory("A", 10)
+
+	if len(history) != 1 || !history[0].QueryTime.Equal(at) || history[0].Timestamp != at.Unix() {
+		t.Errorf("QueryHistory should return the query with checksum A, timestamped when it was saved, but got %+v", history)
+	}
+
+	if plans := m.Plans("B"); len(plans) != 2 || plans[0].Search != "SELECT 1" {
+		t.Errorf("Plans should return the queries with checksum B, oldest first, but got %+v", plans)
+	}
+
+	if plans := m.Plans(""); len(plans) != len(records) {
+		t.Errorf("Plans should return every saved query, but got %d", len(plans))
+	}
+}
+
+func TestMemoryDigests(t *testing.T) {
+	m := NewMemory()
+
+	for _, source := range []string{"slow.log", "tcpdump.txt"} {
+		if err := m.SaveRun(types.DigestReport{Source: source}); err != nil {
+			t.Fatalf("SaveRun should not return an error, but got %s", err)
+		}
+	}
+
+	intervals := []types.DigestInterval{
+		{Schema: "employees", Digest: "d1", DigestCounters: types.DigestCounters{CountStar: 5}},
+		{Schema: "employees", Digest: "d2"},
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := m.SaveDigestSnapshot(intervals); err != nil {
+			t.Fatalf("SaveDigestSnapshot should not return an error, but got %s", err)
+		}
+	}
+
+	if runs := m.Runs("slow.log"); len(runs) != 1 || runs[0].Timestamp == 0 {
+		t.Errorf("Runs should return the timestamped report of slow.log, but got %+v", runs)
+	}
+
+	if runs := m.Runs(""); len(runs) != 2 {
+		t.Errorf("Runs should return every saved report, but got %d", len(runs))
+	}
+
+	if d1 := m.Intervals("d1"); len(d1) != 2 || d1[0].CountStar != 5 {
+		t.Errorf("Intervals should return both intervals of d1, but got %+v", d1)
+	}
+
+	if all := m.Intervals(""); len(all) != 4 {
+		t.Errorf("Intervals should return every saved interval, but got %d", len(all))
+	}
+}
+
+func TestMemoryBaseline(t *testing.T) {
+	m := NewMemory()
+	at := time.Date(2023, 11, 2, 14, 0, 0, 0, time.UTC)
+	m.Now = func() time.Time { return at }
+
+	err := m.SaveBaseline([]types.Baseline{
+		{Name: "before", Schema: "employees", Digest: "d1", DigestCounters: types.DigestCounters{CountStar: 5}},
+		{Name: "before", Schema: "salaries", Digest: "d2"},
+		{Name: "after", Schema: "employees", Digest: "d1"},
+	})
+
+	if err != nil {
+		t.Fatalf("SaveBaseline should not return an error, but got %s", err)
+	}
+
+	if b, err := m.Baseline("before", ""); err != nil || len(b) != 2 || b[0].CountStar != 5 || b[0].Timestamp != at.Unix() {
+		t.Errorf("Baseline should return the timestamped digests of the baseline, but got %+v, %v", b, err)
+	}
+
+	if b, _ := m.Baseline("before", "salaries"); len(b) != 1 || b[0].Digest != "d2" {
+		t.Errorf("Baseline should return the digests of the baseline in the schema, but got %+v", b)
+	}
+
+	if b, _ := m.Baseline("missing", ""); len(b) != 0 {
+		t.Errorf("Baseline should return no digests for a baseline that was not saved, but got %+v", b)
+	}
+}
+
+func TestMemoryClose(t *testing.T) {
+	m := NewMemory()
+	m.SavePlan(types.QueryRecord{Search: "SELECT 1"})
+
+	if err := m.Close(); err != nil || !m.Closed() {
+		t.Fatalf("Close should close the store, but got %v", err)
+	}
+
+	if err := m.SavePlan(types.QueryRecord{Search: "SELECT 2"}); err != ErrClosed {
+		t.Errorf("SavePlan should return ErrClosed after Close, but got %v", err)
+	}
+
+	if err := m.SaveRun(types.DigestReport{}); err != ErrClosed {
+		t.Errorf("SaveRun should return ErrClosed after Close, but got %v", err)
+	}
+
+	if err := m.SaveDigestSnapshot(nil); err != ErrClosed {
+		t.Errorf("SaveDigestSnapshot should return ErrClosed after Close, but got %v", err)
+	}
+
+	if err := m.SaveBaseline(nil); err != ErrClosed {
+		t.Errorf("SaveBaseline should return ErrClosed after Close, but got %v", err)
+	}
+
+	if _, err := m.QueryHistory("", 10); err != ErrClosed {
+		t.Errorf("QueryHistory should return ErrClosed after Close, but got %v", err)
+	}
+
+	if plans := m.Plans(""); len(plans) != 1 {
+		t.Errorf("Plans should still return the results saved before Close, but got %+v", plans)
+	}
+}
+
+func TestMemoryConcurrency(t *testing.T) {
+	m := NewMemory()
+	var wg sync.WaitGroup
+
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < 50; i++ {
+				m.SavePlan(types.QueryRecord{Search: fmt.Sprintf("SELECT %d", i), Checksum: fmt.Sprint(w)})
+				m.SaveDigestSnapshot([]types.DigestInterval{{Digest: fmt.Sprint(w)}})
+				m.QueryHistory(fmt.Sprint(w), 5)
+				m.Intervals(fmt.Sprint(w))
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	if plans, intervals := m.Plans(""), m.Intervals(""); len(plans) != 400 || len(intervals) != 400 {
+		t.Errorf("concurrent saves should all be kept, but got %d plans and %d intervals", len(plans), len(intervals))
+	}
+
+	if plans := m.Plans("3"); len(plans) != 50 || plans[49].Search != "SELECT 49" {
+		t.Errorf("the saves of a goroutine should be kept in order, but got %d plans", len(plans))
+	}
+}
+
+// explainedRecord builds a query record with EXPLAIN rows, a plan, an EXPLAIN ANALYZE tree and
+// metrics, all referenced through slices and pointers
+func explainedRecord() types.QueryRecord {
+	table := "employees"
+
+	return types.QueryRecord{
+		Search:         "SELECT * FROM employees",
+		Checksum:       "A",
+		SQLExplainRows: []types.SQLExplainRow{{ID: 1, Table: &table, Rows: 10}},
+		ExplainPlan:    &types.ExplainPlan{QueryBlock: types.QueryBlock{Table: &types.PlanTable{TableName: table, UsedColumns: []string{"emp_no"}}}},
+		ExplainAnalyze: &types.AnalyzeNode{Operation: "Filter", Children: []*types.AnalyzeNode{{Operation: "Table scan on employees", ActualRows: 10}}},
+		Metrics:        &types.QueryMetrics{RowsExamined: 10},
+	}
+}
+
+func TestMemoryCopies(t *testing.T) {
+	m := NewMemory()
+	rec := explainedRecord()
+
+	if err := m.SavePlan(rec); err != nil {
+		t.Fatalf("SavePlan should not return an error, but got %s", err)
+	}
+
+	report := types.DigestReport{Source: "slow.log", Classes: []types.DigestClass{{Checksum: "A", Metrics: map[string]types.DigestMetric{"Query_time": {Sum: 1}}}}}
+
+	if err := m.SaveRun(report); err != nil {
+		t.Fatalf("SaveRun should not return an error, but got %s", err)
+	}
+
+	// changing what was saved, or what was read, must not change the store
+	mutate := func(rec types.QueryRecord) {
+		*rec.SQLExplainRows[0].Table = "salaries"
+		rec.SQLExplainRows[0].Rows = 0
+		rec.ExplainPlan.QueryBlock.Table.UsedColumns[0] = "salary"
+		rec.ExplainAnalyze.Children[0].ActualRows = 0
+		rec.Metrics.RowsExamined = 0
+	}
+
+	mutate(rec)
+	report.Classes[0].Metrics["Query_time"] = types.DigestMetric{}
+	mutate(m.Plans("")[0])
+
+	history, _ := m.QueryHistory("", 1)
+	mutate(history[0])
+	m.Runs("")[0].Classes[0].Metrics["Query_time"] = types.DigestMetric{}
+
+	expected := explainedRecord()
+	saved := m.Plans("")[0]
+	saved.QueryTime, saved.Timestamp = expected.QueryTime, expected.Timestamp
+
+	if !reflect.DeepEqual(saved, expected) {
+		t.Errorf("the store should keep its own copy of a saved query, expected %+v, but got %+v", expected, saved)
+	}
+
+	if sum := m.Runs("")[0].Classes[0].Metrics["Query_time"].Sum; sum != 1 {
+		t.Errorf("the store should keep its own copy of a saved run, but the query time sum is %v", sum)
+	}
+}
+
+func TestMemoryConcurrentMutation(t *testing.T) {
+	m := NewMemory()
+	m.SavePlan(explainedRecord())
+
+	var wg sync.WaitGroup
+
+	// run with -race: the readers change the records they read while the others read theirs
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < 50; i++ {
+				rec := explainedRecord()
+				m.SavePlan(rec)
+				*rec.SQLExplainRows[0].Table = "salaries"
+
+				history, _ := m.QueryHistory("A", 5)
+
+				for _, h := range history {
+					*h.SQLExplainRows[0].Table = fmt.Sprint(i)
+					h.ExplainAnalyze.Children[0].ActualRows = float64(i)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	for _, rec := range m.Plans("A") {
+		if *rec.SQLExplainRows[0].Table != "employees" || rec.ExplainAnalyze.Children[0].ActualRows != 10 {
+			t.Fatalf("the records read from the store should be copies, but a saved record changed to %+v", rec)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package store
+
+import (
+	"gopherDigest/pkg/types"
+)
+
+// Store saves the results of gopherDigest's commands and reads back the history of captured queries
+type Store interface {
+	// SavePlan saves the EXPLAIN rows, JSON execution plan and optional EXPLAIN ANALYZE tree of
+	// a captured query
+	SavePlan(rec types.QueryRecord) error
+
+	// SaveRun saves the report of a pt-query-digest run
+	SaveRun(report types.DigestReport) error
+
+	// SaveDigestSnapshot saves the per-interval load of every statement digest, computed from the
+	// change between two snapshots of the performance_schema digest summary
+	SaveDigestSnapshot(intervals []types.DigestInterval) error
+
+	// QueryHistory returns the most recently captured queries, newest first, limited to a single
+	// query fingerprint when a checksum is given
+	QueryHistory(checksum string, limit int) ([]types.QueryRecord, error)
+
+	// Close releases the store's connection
+	Close() error
+}
+
+// BaselineStore is implemented by stores that keep named baselines of the statement digest load
+type BaselineStore interface {
+	// SaveBaseline saves the load of every statement digest over a reference period
+	SaveBaseline(baselines []types.Baseline) error
+
+	// Baseline returns the digests of a named baseline, limited to a single schema when one is
+	// given
+	Baseline(name, schema string) ([]types.Baseline, error)
+}
@@ -0,0 +1,236 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// ExplainPlan represents a MySQL EXPLAIN FORMAT=JSON execution plan
+type ExplainPlan struct {
+	QueryBlock QueryBlock `json:"query_block" gorethink:"QueryBlock"`
+}
+
+// QueryBlock represents a single SELECT within an execution plan
+type QueryBlock struct {
+	SelectID                int          `json:"select_id" gorethink:"SelectID"`
+	Message                 string       `json:"message,omitempty" gorethink:"Message,omitempty"`
+	CostInfo                *CostInfo    `json:"cost_info,omitempty" gorethink:"CostInfo,omitempty"`
+	Table                   *PlanTable   `json:"table,omitempty" gorethink:"Table,omitempty"`
+	NestedLoop              []NestedLoop `json:"nested_loop,omitempty" gorethink:"NestedLoop,omitempty"`
+	OrderingOperation       *Operation   `json:"ordering_operation,omitempty" gorethink:"OrderingOperation,omitempty"`
+	GroupingOperation       *Operation   `json:"grouping_operation,omitempty" gorethink:"GroupingOperation,omitempty"`
+	DuplicatesRemoval       *Operation   `json:"duplicates_removal,omitempty" gorethink:"DuplicatesRemoval,omitempty"`
+	UnionResult             *UnionResult `json:"union_result,omitempty" gorethink:"UnionResult,omitempty"`
+	SelectListSubqueries    []Subquery   `json:"select_list_subqueries,omitempty" gorethink:"SelectListSubqueries,omitempty"`
+	OptimizedAwaySubqueries []Subquery   `json:"optimized_away_subqueries,omitempty" gorethink:"OptimizedAwaySubqueries,omitempty"`
+}
+
+// NestedLoop represents one table joined by a nested loop
+type NestedLoop struct {
+	Table PlanTable `json:"table" gorethink:"Table"`
+}
+
+// Operation represents a sort, grouping or DISTINCT step wrapping the tables it reads
+type Operation struct {
+	UsingFilesort       bool         `json:"using_filesort,omitempty" gorethink:"UsingFilesort,omitempty"`
+	UsingTemporaryTable bool         `json:"using_temporary_table,omitempty" gorethink:"UsingTemporaryTable,omitempty"`
+	CostInfo            *CostInfo    `json:"cost_info,omitempty" gorethink:"CostInfo,omitempty"`
+	Table               *PlanTable   `json:"table,omitempty" gorethink:"Table,omitempty"`
+	NestedLoop          []NestedLoop `json:"nested_loop,omitempty" gorethink:"NestedLoop,omitempty"`
+	GroupingOperation   *Operation   `json:"grouping_operation,omitempty" gorethink:"GroupingOperation,omitempty"`
+	DuplicatesRemoval   *Operation   `json:"duplicates_removal,omitempty" gorethink:"DuplicatesRemoval,omitempty"`
+}
+
+// UnionResult represents the temporary table collecting the members of a UNION
+type UnionResult struct {
+	UsingTemporaryTable bool       `json:"using_temporary_table,omitempty" gorethink:"UsingTemporaryTable,omitempty"`
+	TableName           string     `json:"table_name,omitempty" gorethink:"TableName,omitempty"`
+	AccessType          string     `json:"access_type,omitempty" gorethink:"AccessType,omitempty"`
+	QuerySpecifications []Subquery `json:"query_specifications,omitempty" gorethink:"QuerySpecifications,omitempty"`
+}
+
+// Subquery represents a subquery, derived table or UNION member and its query block
+type Subquery struct {
+	Dependent           bool       `json:"dependent" gorethink:"Dependent"`
+	Cacheable           bool       `json:"cacheable" gorethink:"Cacheable"`
+	UsingTemporaryTable bool       `json:"using_temporary_table,omitempty" gorethink:"UsingTemporaryTable,omitempty"`
+	QueryBlock          QueryBlock `json:"query_block" gorethink:"QueryBlock"`
+}
+
+// PlanTable represents a table access within an execution plan
+type PlanTable struct {
+	TableName                string     `json:"table_name" gorethink:"TableName"`
+	AccessType               string     `json:"access_type" gorethink:"AccessType"`
+	PossibleKeys             []string   `json:"possible_keys,omitempty" gorethink:"PossibleKeys,omitempty"`
+	Key                      string     `json:"key,omitempty" gorethink:"Key,omitempty"`
+	UsedKeyParts             []string   `json:"used_key_parts,omitempty" gorethink:"UsedKeyParts,omitempty"`
+	KeyLength                string     `json:"key_length,omitempty" gorethink:"KeyLength,omitempty"`
+	Ref                      []string   `json:"ref,omitempty" gorethink:"Ref,omitempty"`
+	RowsExaminedPerScan      int64      `json:"rows_examined_per_scan" gorethink:"RowsExaminedPerScan"`
+	RowsProducedPerJoin      int64      `json:"rows_produced_per_join" gorethink:"RowsProducedPerJoin"`
+	Filtered                 Cost       `json:"filtered" gorethink:"Filtered"`
+	UsingIndex               bool       `json:"using_index,omitempty" gorethink:"UsingIndex,omitempty"`
+	UsingJoinBuffer          string     `json:"using_join_buffer,omitempty" gorethink:"UsingJoinBuffer,omitempty"`
+	CostInfo                 *CostInfo  `json:"cost_info,omitempty" gorethink:"CostInfo,omitempty"`
+	UsedColumns              []string   `json:"used_columns,omitempty" gorethink:"UsedColumns,omitempty"`
+	AttachedCondition        string     `json:"attached_condition,omitempty" gorethink:"AttachedCondition,omitempty"`
+	IndexCondition           string     `json:"index_condition,omitempty" gorethink:"IndexCondition,omitempty"`
+	MaterializedFromSubquery *Subquery  `json:"materialized_from_subquery,omitempty" gorethink:"MaterializedFromSubquery,omitempty"`
+	AttachedSubqueries       []Subquery `json:"attached_subqueries,omitempty" gorethink:"AttachedSubqueries,omitempty"`
+}
+
+// CostInfo represents the optimizer's cost estimates for a query block or table
+type CostInfo struct {
+	QueryCost       Cost   `json:"query_cost,omitempty" gorethink:"QueryCost,omitempty"`
+	ReadCost        Cost   `json:"read_cost,omitempty" gorethink:"ReadCost,omitempty"`
+	EvalCost        Cost   `json:"eval_cost,omitempty" gorethink:"EvalCost,omitempty"`
+	PrefixCost      Cost   `json:"prefix_cost,omitempty" gorethink:"PrefixCost,omitempty"`
+	SortCost        Cost   `json:"sort_cost,omitempty" gorethink:"SortCost,omitempty"`
+	DataReadPerJoin string `json:"data_read_per_join,omitempty" gorethink:"DataReadPerJoin,omitempty"`
+}
+
+// Cost is an optimizer estimate, which MySQL encodes as a quoted decimal
+type Cost float64
+
+// UnmarshalJSON decodes a cost from either a quoted or a bare JSON number
+func (c *Cost) UnmarshalJSON(b []byte) error {
+	f, err := parseNumber(b)
+
+	if err != nil {
+		return fmt.Errorf("could not parse the cost %s\n%s", b, err)
+	}
+
+	*c = Cost(f)
+
+	return nil
+}
+
+// parseNumber parses a quoted or bare JSON number, treating null and empty strings as zero
+func parseNumber(b []byte) (float64, error) {
+	b = bytes.Trim(b, `"`)
+
+	if len(b) == 0 || string(b) == "null" {
+		return 0, nil
+	}
+
+	return strconv.ParseFloat(string(b), 64)
+}
+
+// Tables returns every table access in the plan in join order, with the tables of
+// materialized and attached subqueries following the table that uses them
+func (p *ExplainPlan) Tables() []PlanTable {
+	return p.QueryBlock.tables()
+}
+
+// tables flattens the table accesses of a query block
+func (qb *QueryBlock) tables() []PlanTable {
+	tables := []PlanTable{}
+
+	if qb.Table != nil {
+		tables = append(tables, qb.Table.tables()...)
+	}
+
+	for _, nl := range qb.NestedLoop {
+		tables = append(tables, nl.Table.tables()...)
+	}
+
+	for _, op := range []*Operation{qb.OrderingOperation, qb.GroupingOperation, qb.DuplicatesRemoval} {
+		tables = append(tables, op.tables()...)
+	}
+
+	if qb.UnionResult != nil {
+		for _, sq := range qb.UnionResult.QuerySpecifications {
+			tables = append(tables, sq.QueryBlock.tables()...)
+		}
+	}
+
+	for _, sq := range qb.SelectListSubqueries {
+		tables = append(tables, sq.QueryBlock.tables()...)
+	}
+
+	for _, sq := range qb.OptimizedAwaySubqueries {
+		tables = append(tables, sq.QueryBlock.tables()...)
+	}
+
+	return tables
+}
+
+// tables flattens the table accesses of a sort, grouping or DISTINCT operation
+func (op *Operation) tables() []PlanTable {
+	tables := []PlanTable{}
+
+	if op == nil {
+		return tables
+	}
+
+	if op.Table != nil {
+		tables = append(tables, op.Table.tables()...)
+	}
+
+	for _, nl := range op.NestedLoop {
+		tables = append(tables, nl.Table.tables()...)
+	}
+
+	tables = append(tables, op.GroupingOperation.tables()...)
+
+	return append(tables, op.DuplicatesRemoval.tables()...)
+}
+
+// tables returns a table followed by the tables of the subqueries it reads
+func (t PlanTable) tables() []PlanTable {
+	tables := []PlanTable{t}
+
+	if t.MaterializedFromSubquery != nil {
+		tables = append(tables, t.MaterializedFromSubquery.QueryBlock.tables()...)
+	}
+
+	for _, sq := range t.AttachedSubqueries {
+		tables = append(tables, sq.QueryBlock.tables()...)
+	}
+
+	return tables
+}
+
+// AnalyzeNode represents an iterator within a MySQL EXPLAIN ANALYZE tree
+type AnalyzeNode struct {
+	Operation       string         `json:"operation" gorethink:"Operation"`
+	EstimatedCost   float64        `json:"estimated_cost" gorethink:"EstimatedCost"`
+	EstimatedRows   float64        `json:"estimated_rows" gorethink:"EstimatedRows"`
+	HasEstimate     bool           `json:"has_estimate" gorethink:"HasEstimate"`
+	ActualFirstRow  float64        `json:"actual_first_row" gorethink:"ActualFirstRow"`
+	ActualLastRow   float64        `json:"actual_last_row" gorethink:"ActualLastRow"`
+	ActualRows      float64        `json:"actual_rows" gorethink:"ActualRows"`
+	Loops           int64          `json:"loops" gorethink:"Loops"`
+	Executed        bool           `json:"executed" gorethink:"Executed"`
+	Misestimated    bool           `json:"misestimated" gorethink:"Misestimated"`
+	MisestimateRate float64        `json:"misestimate_rate" gorethink:"MisestimateRate"`
+	Children        []*AnalyzeNode `json:"children,omitempty" gorethink:"Children,omitempty"`
+}
+
+// FlagMisestimates marks every executed node whose estimated and actual row counts
+// differ by more than a factor and returns the marked nodes in tree order
+func (n *AnalyzeNode) FlagMisestimates(factor float64) []*AnalyzeNode {
+	flagged := []*AnalyzeNode{}
+
+	if n.HasEstimate && n.Executed {
+		n.MisestimateRate = math.Max(n.EstimatedRows, 1) / math.Max(n.ActualRows, 1)
+
+		if n.MisestimateRate < 1 {
+			n.MisestimateRate = 1 / n.MisestimateRate
+		}
+
+		n.Misestimated = n.MisestimateRate > factor
+	}
+
+	if n.Misestimated {
+		flagged = append(flagged, n)
+	}
+
+	for _, child := range n.Children {
+		flagged = append(flagged, child.FlagMisestimates(factor)...)
+	}
+
+	return flagged
+}
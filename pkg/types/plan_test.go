@@ -0,0 +1,67 @@
+package types
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCostUnmarshalJSON(t *testing.T) {
+	tt := []struct {
+		name     string
+		input    string
+		expected Cost
+	}{
+		{"Quoted", `"1283030.45"`, 1283030.45},
+		{"Bare", `12.5`, 12.5},
+		{"Null", `null`, 0},
+		{"Empty", `""`, 0},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var actual Cost
+
+			if err := json.Unmarshal([]byte(tc.input), &actual); err != nil {
+				t.Fatalf("unmarshal of %s should not return an error, but got %s", tc.input, err)
+			}
+
+			if actual != tc.expected {
+				t.Errorf("unmarshal of %s should be %v, but got %v", tc.input, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestTables(t *testing.T) {
+	subquery := func(name string) Subquery {
+		return Subquery{QueryBlock: QueryBlock{Table: &PlanTable{TableName: name}}}
+	}
+
+	derived := subquery("derived")
+	attached := PlanTable{TableName: "attached", AttachedSubqueries: []Subquery{subquery("inner")}}
+
+	plan := ExplainPlan{QueryBlock: QueryBlock{
+		NestedLoop: []NestedLoop{
+			{Table: PlanTable{TableName: "first", MaterializedFromSubquery: &derived}},
+			{Table: PlanTable{TableName: "second"}},
+		},
+		OrderingOperation: &Operation{
+			Table:             &attached,
+			DuplicatesRemoval: &Operation{NestedLoop: []NestedLoop{{Table: PlanTable{TableName: "distinct"}}}},
+		},
+		UnionResult:          &UnionResult{QuerySpecifications: []Subquery{subquery("union")}},
+		SelectListSubqueries: []Subquery{subquery("select_list")},
+	}}
+
+	expected := []string{"first", "derived", "second", "attached", "inner", "distinct", "union", "select_list"}
+	actual := []string{}
+
+	for _, table := range plan.Tables() {
+		actual = append(actual, table.TableName)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Tables should be %v, but got %v", expected, actual)
+	}
+}
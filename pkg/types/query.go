@@ -0,0 +1,48 @@
+package types
+
+import (
+	"time"
+)
+
+// QueryRecord represents a stored MySQL Query Performance Dump, keyed by the
+// fingerprint of its query and that fingerprint's checksum, and the schema it ran in
+type QueryRecord struct {
+	Search         string          `json:"query" gorethink:"Search"`
+	Fingerprint    string          `json:"fingerprint" gorethink:"Fingerprint"`
+	Checksum       string          `json:"checksum" gorethink:"Checksum"`
+	Schema         string          `json:"schema" gorethink:"Schema"`
+	QueryTime      time.Time       `json:"query_time" gorethink:"QueryTime"`
+	SQLExplainRows []SQLExplainRow `json:"explain_rows" gorethink:"SQLExplainRows"`
+	ExplainPlan    *ExplainPlan    `json:"explain_plan,omitempty" gorethink:"ExplainPlan,omitempty"`
+	ExplainAnalyze *AnalyzeNode    `json:"explain_analyze,omitempty" gorethink:"ExplainAnalyze,omitempty"`
+	Metrics        *QueryMetrics   `json:"metrics,omitempty" gorethink:"Metrics,omitempty"`
+	Timestamp      int64           `json:"timestamp" gorethink:"Timestamp"`
+}
+
+// QueryMetrics represents the latest execution of a query in
+// performance_schema.events_statements_history, with the counters of its digest. Times are in
+// seconds.
+type QueryMetrics struct {
+	Digest       string  `json:"digest" gorethink:"Digest"`
+	QueryTime    float64 `json:"query_time" gorethink:"QueryTime"`
+	LockTime     float64 `json:"lock_time" gorethink:"LockTime"`
+	RowsSent     uint64  `json:"rows_sent" gorethink:"RowsSent"`
+	RowsExamined uint64  `json:"rows_examined" gorethink:"RowsExamined"`
+	DigestCounters
+}
+
+// SQLExplainRow represents a MySQL Explain Result
+type SQLExplainRow struct {
+	ID           int      `json:"id" gorethink:"ZID"`
+	SelectType   *string  `json:"select_type" gorethink:"SelectType"`
+	Table        *string  `json:"table" gorethink:"Table"`
+	Partitions   *string  `json:"partitions" gorethink:"Partitions"`
+	Ztype        *string  `json:"type" gorethink:"Ztype"`
+	PossibleKeys *string  `json:"possible_keys" gorethink:"PossibleKeys"`
+	Key          *string  `json:"key" gorethink:"Key"`
+	KeyLen       *string  `json:"key_len" gorethink:"KeyLen"`
+	Ref          *string  `json:"ref" gorethink:"Ref"`
+	Rows         int      `json:"rows" gorethink:"Rows"`
+	Filtered     *float64 `json:"filtered" gorethink:"Filtered"`
+	Extra        *string  `json:"extra" gorethink:"Extra"`
+}
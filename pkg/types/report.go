@@ -0,0 +1,86 @@
+package types
+
+import (
+	"fmt"
+)
+
+// DigestReport represents the JSON report of a pt-query-digest run and the input it analyzed
+type DigestReport struct {
+	Source    string        `json:"-" gorethink:"Source"`
+	Global    DigestGlobal  `json:"global" gorethink:"Global"`
+	Classes   []DigestClass `json:"classes" gorethink:"Classes"`
+	Timestamp int64         `json:"-" gorethink:"Timestamp"`
+}
+
+// DigestGlobal represents the metrics of every query pt-query-digest analyzed
+type DigestGlobal struct {
+	Files            []DigestFile            `json:"files,omitempty" gorethink:"Files,omitempty"`
+	QueryCount       int64                   `json:"query_count" gorethink:"QueryCount"`
+	UniqueQueryCount int64                   `json:"unique_query_count" gorethink:"UniqueQueryCount"`
+	Metrics          map[string]DigestMetric `json:"metrics" gorethink:"Metrics"`
+}
+
+// DigestFile represents an input file read by pt-query-digest
+type DigestFile struct {
+	Name string `json:"name" gorethink:"Name"`
+	Size int64  `json:"size" gorethink:"Size"`
+}
+
+// DigestClass represents the queries pt-query-digest grouped under a single fingerprint
+type DigestClass struct {
+	Attribute   string                  `json:"attribute" gorethink:"Attribute"`
+	Checksum    string                  `json:"checksum" gorethink:"Checksum"`
+	Fingerprint string                  `json:"fingerprint" gorethink:"Fingerprint"`
+	Distillate  string                  `json:"distillate" gorethink:"Distillate"`
+	QueryCount  int64                   `json:"query_count" gorethink:"QueryCount"`
+	TsMin       string                  `json:"ts_min,omitempty" gorethink:"TsMin,omitempty"`
+	TsMax       string                  `json:"ts_max,omitempty" gorethink:"TsMax,omitempty"`
+	Example     *DigestSample           `json:"example,omitempty" gorethink:"Example,omitempty"`
+	Metrics     map[string]DigestMetric `json:"metrics" gorethink:"Metrics"`
+	Histograms  map[string][]int64      `json:"histograms,omitempty" gorethink:"Histograms,omitempty"`
+	Tables      []DigestTable           `json:"tables,omitempty" gorethink:"Tables,omitempty"`
+}
+
+// DigestSample represents the example query pt-query-digest reports for a class
+type DigestSample struct {
+	Query     string `json:"query" gorethink:"Query"`
+	AsSelect  string `json:"as_select,omitempty" gorethink:"AsSelect,omitempty"`
+	QueryTime Number `json:"Query_time" gorethink:"QueryTime"`
+	Ts        string `json:"ts,omitempty" gorethink:"Ts,omitempty"`
+}
+
+// DigestMetric represents the statistics of a numeric attribute, or the most common value
+// of a string attribute such as the database or user
+type DigestMetric struct {
+	Sum    Number `json:"sum" gorethink:"Sum"`
+	Min    Number `json:"min" gorethink:"Min"`
+	Max    Number `json:"max" gorethink:"Max"`
+	Avg    Number `json:"avg" gorethink:"Avg"`
+	Median Number `json:"median" gorethink:"Median"`
+	Pct95  Number `json:"pct_95" gorethink:"Pct95"`
+	Stddev Number `json:"stddev" gorethink:"Stddev"`
+	Pct    Number `json:"pct" gorethink:"Pct"`
+	Value  string `json:"value,omitempty" gorethink:"Value,omitempty"`
+}
+
+// DigestTable represents the statements pt-query-digest suggests for inspecting a table
+type DigestTable struct {
+	Create string `json:"create" gorethink:"Create"`
+	Status string `json:"status" gorethink:"Status"`
+}
+
+// Number represents a pt-query-digest metric, which is written as either a JSON number or a string
+type Number float64
+
+// UnmarshalJSON parses a metric from either a JSON number or a quoted number
+func (n *Number) UnmarshalJSON(b []byte) error {
+	f, err := parseNumber(b)
+
+	if err != nil {
+		return fmt.Errorf("could not parse the metric %s\n%s", b, err)
+	}
+
+	*n = Number(f)
+
+	return nil
+}
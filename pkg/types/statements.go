@@ -0,0 +1,128 @@
+package types
+
+import (
+	"time"
+)
+
+// DigestCounters are the cumulative counters performance_schema keeps for a statement digest
+type DigestCounters struct {
+	CountStar               uint64 `json:"count_star" gorethink:"CountStar"`
+	SumTimerWait            uint64 `json:"sum_timer_wait" gorethink:"SumTimerWait"`
+	SumLockTime             uint64 `json:"sum_lock_time" gorethink:"SumLockTime"`
+	SumErrors               uint64 `json:"sum_errors" gorethink:"SumErrors"`
+	SumWarnings             uint64 `json:"sum_warnings" gorethink:"SumWarnings"`
+	SumRowsAffected         uint64 `json:"sum_rows_affected" gorethink:"SumRowsAffected"`
+	SumRowsSent             uint64 `json:"sum_rows_sent" gorethink:"SumRowsSent"`
+	SumRowsExamined         uint64 `json:"sum_rows_examined" gorethink:"SumRowsExamined"`
+	SumCreatedTmpDiskTables uint64 `json:"sum_created_tmp_disk_tables" gorethink:"SumCreatedTmpDiskTables"`
+	SumCreatedTmpTables     uint64 `json:"sum_created_tmp_tables" gorethink:"SumCreatedTmpTables"`
+	SumSelectFullJoin       uint64 `json:"sum_select_full_join" gorethink:"SumSelectFullJoin"`
+	SumSelectFullRangeJoin  uint64 `json:"sum_select_full_range_join" gorethink:"SumSelectFullRangeJoin"`
+	SumSelectRange          uint64 `json:"sum_select_range" gorethink:"SumSelectRange"`
+	SumSelectRangeCheck     uint64 `json:"sum_select_range_check" gorethink:"SumSelectRangeCheck"`
+	SumSelectScan           uint64 `json:"sum_select_scan" gorethink:"SumSelectScan"`
+	SumSortMergePasses      uint64 `json:"sum_sort_merge_passes" gorethink:"SumSortMergePasses"`
+	SumSortRange            uint64 `json:"sum_sort_range" gorethink:"SumSortRange"`
+	SumSortRows             uint64 `json:"sum_sort_rows" gorethink:"SumSortRows"`
+	SumSortScan             uint64 `json:"sum_sort_scan" gorethink:"SumSortScan"`
+	SumNoIndexUsed          uint64 `json:"sum_no_index_used" gorethink:"SumNoIndexUsed"`
+	SumNoGoodIndexUsed      uint64 `json:"sum_no_good_index_used" gorethink:"SumNoGoodIndexUsed"`
+}
+
+// StatementDigest represents a row of performance_schema.events_statements_summary_by_digest
+type StatementDigest struct {
+	Schema     string `json:"schema" gorethink:"Schema"`
+	Digest     string `json:"digest" gorethink:"Digest"`
+	DigestText string `json:"digest_text" gorethink:"DigestText"`
+	DigestCounters
+	FirstSeen time.Time `json:"first_seen" gorethink:"FirstSeen"`
+	LastSeen  time.Time `json:"last_seen" gorethink:"LastSeen"`
+}
+
+// DigestSnapshot represents the statement digest summary at a point in time, along with
+// the server's uptime so that restarts can be detected
+type DigestSnapshot struct {
+	Time    time.Time
+	Uptime  int64
+	Digests []StatementDigest
+}
+
+// DigestInterval represents the statements of a digest executed between two snapshots.
+// Reset is set when the digest's counters were cleared during the interval, in which case
+// the counters only cover the statements executed since they were cleared.
+type DigestInterval struct {
+	Schema     string    `json:"schema" gorethink:"Schema"`
+	Digest     string    `json:"digest" gorethink:"Digest"`
+	DigestText string    `json:"digest_text" gorethink:"DigestText"`
+	Start      time.Time `json:"start" gorethink:"Start"`
+	End        time.Time `json:"end" gorethink:"End"`
+	Reset      bool      `json:"reset" gorethink:"Reset"`
+	DigestCounters
+}
+
+// Baseline represents the load of a statement digest over a reference period, such as before a
+// change, saved under a name so later digest intervals can be compared against it
+type Baseline struct {
+	Name       string    `json:"name" gorethink:"Name"`
+	Schema     string    `json:"schema" gorethink:"Schema"`
+	Digest     string    `json:"digest" gorethink:"Digest"`
+	DigestText string    `json:"digest_text" gorethink:"DigestText"`
+	Start      time.Time `json:"start" gorethink:"Start"`
+	End        time.Time `json:"end" gorethink:"End"`
+	Timestamp  int64     `json:"timestamp" gorethink:"Timestamp"`
+	DigestCounters
+}
+
+// Sub returns the difference between the counters and an earlier reading of them
+func (c DigestCounters) Sub(prev DigestCounters) DigestCounters {
+	return DigestCounters{
+		CountStar:               c.CountStar - prev.CountStar,
+		SumTimerWait:            c.SumTimerWait - prev.SumTimerWait,
+		SumLockTime:             c.SumLockTime - prev.SumLockTime,
+		SumErrors:               c.SumErrors - prev.SumErrors,
+		SumWarnings:             c.SumWarnings - prev.SumWarnings,
+		SumRowsAffected:         c.SumRowsAffected - prev.SumRowsAffected,
+		SumRowsSent:             c.SumRowsSent - prev.SumRowsSent,
+		SumRowsExamined:         c.SumRowsExamined - prev.SumRowsExamined,
+		SumCreatedTmpDiskTables: c.SumCreatedTmpDiskTables - prev.SumCreatedTmpDiskTables,
+		SumCreatedTmpTables:     c.SumCreatedTmpTables - prev.SumCreatedTmpTables,
+		SumSelectFullJoin:       c.SumSelectFullJoin - prev.SumSelectFullJoin,
+		SumSelectFullRangeJoin:  c.SumSelectFullRangeJoin - prev.SumSelectFullRangeJoin,
+		SumSelectRange:          c.SumSelectRange - prev.SumSelectRange,
+		SumSelectRangeCheck:     c.SumSelectRangeCheck - prev.SumSelectRangeCheck,
+		SumSelectScan:           c.SumSelectScan - prev.SumSelectScan,
+		SumSortMergePasses:      c.SumSortMergePasses - prev.SumSortMergePasses,
+		SumSortRange:            c.SumSortRange - prev.SumSortRange,
+		SumSortRows:             c.SumSortRows - prev.SumSortRows,
+		SumSortScan:             c.SumSortScan - prev.SumSortScan,
+		SumNoIndexUsed:          c.SumNoIndexUsed - prev.SumNoIndexUsed,
+		SumNoGoodIndexUsed:      c.SumNoGoodIndexUsed - prev.SumNoGoodIndexUsed,
+	}
+}
+
+// Add returns the sum of the counters and those of another reading
+func (c DigestCounters) Add(other DigestCounters) DigestCounters {
+	return DigestCounters{
+		CountStar:               c.CountStar + other.CountStar,
+		SumTimerWait:            c.SumTimerWait + other.SumTimerWait,
+		SumLockTime:             c.SumLockTime + other.SumLockTime,
+		SumErrors:               c.SumErrors + other.SumErrors,
+		SumWarnings:             c.SumWarnings + other.SumWarnings,
+		SumRowsAffected:         c.SumRowsAffected + other.SumRowsAffected,
+		SumRowsSent:             c.SumRowsSent + other.SumRowsSent,
+		SumRowsExamined:         c.SumRowsExamined + other.SumRowsExamined,
+		SumCreatedTmpDiskTables: c.SumCreatedTmpDiskTables + other.SumCreatedTmpDiskTables,
+		SumCreatedTmpTables:     c.SumCreatedTmpTables + other.SumCreatedTmpTables,
+		SumSelectFullJoin:       c.SumSelectFullJoin + other.SumSelectFullJoin,
+		SumSelectFullRangeJoin:  c.SumSelectFullRangeJoin + other.SumSelectFullRangeJoin,
+		SumSelectRange:          c.SumSelectRange + other.SumSelectRange,
+		SumSelectRangeCheck:     c.SumSelectRangeCheck + other.SumSelectRangeCheck,
+		SumSelectScan:           c.SumSelectScan + other.SumSelectScan,
+		SumSortMergePasses:      c.SumSortMergePasses + other.SumSortMergePasses,
+		SumSortRange:            c.SumSortRange + other.SumSortRange,
+		SumSortRows:             c.SumSortRows + other.SumSortRows,
+		SumSortScan:             c.SumSortScan + other.SumSortScan,
+		SumNoIndexUsed:          c.SumNoIndexUsed + other.SumNoIndexUsed,
+		SumNoGoodIndexUsed:      c.SumNoGoodIndexUsed + other.SumNoGoodIndexUsed,
+	}
+}